@@ -49,12 +49,22 @@ func main() {
 	defer cache.Close()
 
 	authService := services.NewAuthService(cfg.JWT)
+	calendar, err := services.NewCalendar(cfg.City)
+	if err != nil {
+		log.Fatalf("Failed to load city timezone: %v", err)
+	}
 
 	authHandler := handlers.NewAuthHandler(db, authService)
-	trafficHandler := handlers.NewTrafficHandler(db, cache)
+	trafficHandler := handlers.NewTrafficHandler(db, cache, calendar)
 	predictionHandler := handlers.NewPredictionHandler(db, cache)
 	rerouteHandler := handlers.NewRerouteHandler(db, cache)
 	roadsHandler := handlers.NewRoadsHandler(db, cache)
+	incidentHandler := handlers.NewIncidentHandler(db, cache)
+	predictorProxy := handlers.NewPredictorProxy(cfg.Predictor.URL)
+	routeProxy := handlers.NewRouteProxy(cfg.Rerouter.URL)
+	modelHandler := handlers.NewModelHandler(db)
+	roadLinkHandler := handlers.NewRoadLinkHandler(db)
+	restrictionHandler := handlers.NewRoadRestrictionHandler(db)
 
 	router := gin.Default()
 
@@ -74,8 +84,35 @@ func main() {
 		api.POST("/auth/logout", authHandler.Logout)
 		api.GET("/roads", roadsHandler.GetRoads)
 		api.GET("/traffic/live", trafficHandler.GetLive)
+		api.GET("/traffic/history", trafficHandler.GetHistory)
 		api.GET("/predictions", predictionHandler.GetPredictions)
+		api.GET("/predictions/:road_id", predictionHandler.GetPrediction)
 		api.GET("/reroutes/recommended", rerouteHandler.GetRecommended)
+		api.GET("/routes", routeProxy.GetRoutes)
+		api.GET("/incidents", incidentHandler.GetIncidents)
+		api.GET("/road-restrictions", restrictionHandler.GetRoadRestrictions)
+	}
+
+	admin := api.Group("/admin")
+	admin.Use(middleware.RequireRole("admin"))
+	{
+		// A refresh recomputes the whole network: admins only.
+		admin.POST("/predictions/refresh", predictorProxy.Refresh)
+		admin.GET("/models", modelHandler.GetModels)
+		admin.POST("/models", modelHandler.RegisterModel)
+		admin.POST("/models/:version/promote", modelHandler.PromoteModel)
+		admin.POST("/models/:version/retire", modelHandler.RetireModel)
+		admin.GET("/road-links", roadLinkHandler.GetRoadLinks)
+		admin.PUT("/road-links", roadLinkHandler.PutRoadLink)
+		admin.DELETE("/road-links/:from/:to", roadLinkHandler.DeleteRoadLink)
+		admin.POST("/road-links/:from/:to/approve", roadLinkHandler.ApproveRoadLink)
+		admin.POST("/road-links/:from/:to/reject", roadLinkHandler.RejectRoadLink)
+		admin.POST("/road-links/approve-candidates", roadLinkHandler.ApproveCandidates)
+		// Restrictions feed live rerouting, and any sign-up gets the operator
+		// role: only admins may change them.
+		admin.POST("/road-restrictions", restrictionHandler.CreateRoadRestriction)
+		admin.PUT("/road-restrictions/:id", restrictionHandler.UpdateRoadRestriction)
+		admin.DELETE("/road-restrictions/:id", restrictionHandler.DeleteRoadRestriction)
 	}
 
 	router.GET("/ws/live", handlers.LiveWebSocket(cache, authService))
@@ -4,15 +4,20 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"time"
+	_ "time/tzdata" // the alpine runtime image ships no zoneinfo
 )
 
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	JWT      JWTConfig
-	Redis    RedisConfig
-	CORS     CORSConfig
-	WS       WSConfig
+	Server    ServerConfig
+	Database  DatabaseConfig
+	JWT       JWTConfig
+	Redis     RedisConfig
+	CORS      CORSConfig
+	WS        WSConfig
+	Predictor PredictorConfig
+	Rerouter  RerouterConfig
+	City      CityConfig
 }
 
 type ServerConfig struct {
@@ -48,6 +53,19 @@ type WSConfig struct {
 	PollIntervalMS int
 }
 
+type PredictorConfig struct {
+	URL string
+}
+
+type RerouterConfig struct {
+	URL string
+}
+
+// CityConfig holds the city's IANA timezone, used for local day boundaries.
+type CityConfig struct {
+	Timezone string
+}
+
 func (d DatabaseConfig) GetDSN() string {
 	return fmt.Sprintf(
 		"host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
@@ -86,6 +104,11 @@ func LoadConfig() (*Config, error) {
 		return nil, fmt.Errorf("invalid WS_POLL_INTERVAL_MS: %w", err)
 	}
 
+	cityTZ := getEnv("CITY_TZ", "Europe/Paris")
+	if _, err := time.LoadLocation(cityTZ); err != nil {
+		return nil, fmt.Errorf("invalid CITY_TZ: %w", err)
+	}
+
 	cfg := &Config{
 		Server: ServerConfig{
 			Port: serverPort,
@@ -114,6 +137,15 @@ func LoadConfig() (*Config, error) {
 		WS: WSConfig{
 			PollIntervalMS: wsPollInterval,
 		},
+		Predictor: PredictorConfig{
+			URL: getEnv("PREDICTOR_URL", "http://predictor:8080"),
+		},
+		Rerouter: RerouterConfig{
+			URL: getEnv("REROUTER_URL", "http://rerouter:8080"),
+		},
+		City: CityConfig{
+			Timezone: cityTZ,
+		},
 	}
 
 	return cfg, nil
@@ -94,7 +94,7 @@ func TestGetIntEnv(t *testing.T) {
 
 func TestLoadConfigDefaults(t *testing.T) {
 	// Clear env vars to get defaults
-	for _, key := range []string{"SERVER_PORT", "DB_HOST", "DB_PORT", "DB_USER", "DB_PASSWORD", "DB_NAME", "DB_SSLMODE", "JWT_SECRET", "JWT_EXPIRY_HOURS", "REDIS_HOST", "REDIS_PORT", "REDIS_PASSWORD", "REDIS_DB", "CORS_ALLOWED_ORIGINS", "WS_POLL_INTERVAL_MS"} {
+	for _, key := range []string{"SERVER_PORT", "DB_HOST", "DB_PORT", "DB_USER", "DB_PASSWORD", "DB_NAME", "DB_SSLMODE", "JWT_SECRET", "JWT_EXPIRY_HOURS", "REDIS_HOST", "REDIS_PORT", "REDIS_PASSWORD", "REDIS_DB", "CORS_ALLOWED_ORIGINS", "WS_POLL_INTERVAL_MS", "PREDICTOR_URL", "REROUTER_URL", "CITY_TZ"} {
 		os.Unsetenv(key)
 	}
 
@@ -121,6 +121,15 @@ func TestLoadConfigDefaults(t *testing.T) {
 	if cfg.CORS.AllowedOrigins != "*" {
 		t.Errorf("CORS.AllowedOrigins = %q, want %q", cfg.CORS.AllowedOrigins, "*")
 	}
+	if cfg.Predictor.URL != "http://predictor:8080" {
+		t.Errorf("Predictor.URL = %q, want %q", cfg.Predictor.URL, "http://predictor:8080")
+	}
+	if cfg.Rerouter.URL != "http://rerouter:8080" {
+		t.Errorf("Rerouter.URL = %q, want %q", cfg.Rerouter.URL, "http://rerouter:8080")
+	}
+	if cfg.City.Timezone != "Europe/Paris" {
+		t.Errorf("City.Timezone = %q, want %q", cfg.City.Timezone, "Europe/Paris")
+	}
 }
 
 func TestLoadConfigCustom(t *testing.T) {
@@ -163,3 +172,13 @@ func TestLoadConfigInvalidPort(t *testing.T) {
 		t.Error("expected error for invalid SERVER_PORT")
 	}
 }
+
+func TestLoadConfigInvalidTimezone(t *testing.T) {
+	os.Setenv("CITY_TZ", "Mars/Olympus")
+	defer os.Unsetenv("CITY_TZ")
+
+	_, err := LoadConfig()
+	if err == nil {
+		t.Error("expected error for invalid CITY_TZ")
+	}
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"traffic-prediction-api/models"
+	"traffic-prediction-api/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var incidentSeverities = map[string]bool{"minor": true, "moderate": true, "major": true}
+
+type IncidentHandler struct {
+	db    *gorm.DB
+	cache *services.CacheService
+}
+
+func NewIncidentHandler(db *gorm.DB, cache *services.CacheService) *IncidentHandler {
+	return &IncidentHandler{db: db, cache: cache}
+}
+
+// GetIncidents lists incidents, newest first. active=true keeps open ones only.
+func (h *IncidentHandler) GetIncidents(c *gin.Context) {
+	p := ParsePagination(c)
+	roadID := c.Query("road_id")
+	severity := c.Query("severity")
+	active := c.Query("active") == "true"
+
+	if severity != "" && !incidentSeverities[severity] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid severity parameter, must be minor, moderate or major"})
+		return
+	}
+
+	beforeStr := ""
+	if p.Before != nil {
+		beforeStr = p.Before.Format(time.RFC3339Nano)
+	}
+	cacheKey := fmt.Sprintf("incidents:%s:%s:%t:%d:%s", roadID, severity, active, p.Limit, beforeStr)
+
+	var cached CursorResponse
+	if err := h.cache.Get(c.Request.Context(), cacheKey, &cached); err == nil && cached.Data != nil {
+		c.JSON(http.StatusOK, cached)
+		return
+	}
+
+	query := h.db.Model(&models.Incident{}).Order("started_at DESC").Limit(p.Limit + 1)
+	if p.Before != nil {
+		query = query.Where("started_at < ?", *p.Before)
+	}
+	if roadID != "" {
+		query = query.Where("road_id = ?", roadID)
+	}
+	if severity != "" {
+		query = query.Where("severity = ?", severity)
+	}
+	if active {
+		query = query.Where("ended_at IS NULL")
+	}
+
+	var rows []models.Incident
+	if err := query.Find(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database query failed"})
+		return
+	}
+
+	hasMore := len(rows) > p.Limit
+	if hasMore {
+		rows = rows[:p.Limit]
+	}
+
+	var nextCursor string
+	if hasMore && len(rows) > 0 {
+		nextCursor = rows[len(rows)-1].StartedAt.Format(time.RFC3339Nano)
+	}
+
+	resp := CursorResponse{Data: rows, NextCursor: nextCursor, HasMore: hasMore}
+	go h.cache.Set(context.Background(), cacheKey, resp, 10*time.Second)
+
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"traffic-prediction-api/models"
+	"traffic-prediction-api/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// modelParamKinds lists the parameter overrides the predictor understands,
+// with the JSON type each expects.
+var modelParamKinds = map[string]string{
+	"alpha": "number", "max_speed": "number", "max_flow": "number",
+	"horizon": "integer", "spatial_up": "number", "spatial_down": "number",
+	"rain_per_mm": "number", "rain_cap": "number", "low_visibility": "number", "freezing": "number",
+	"lookback": "duration",
+}
+
+var modelStatuses = map[string]bool{models.ModelShadow: true, models.ModelChampion: true, models.ModelRetired: true}
+
+var errModelNotFound = errors.New("model not found")
+
+// promotionRefused wraps the reason the accuracy guard refused a promotion.
+type promotionRefused struct{ err error }
+
+func (e promotionRefused) Error() string { return e.err.Error() }
+
+type ModelHandler struct {
+	db *gorm.DB
+}
+
+func NewModelHandler(db *gorm.DB) *ModelHandler {
+	return &ModelHandler{db: db}
+}
+
+type RegisterModelRequest struct {
+	Version     string                     `json:"version" binding:"required,max=64"`
+	Params      map[string]json.RawMessage `json:"params"`
+	Description *string                    `json:"description"`
+}
+
+// GetModels lists the registry, champion first.
+func (h *ModelHandler) GetModels(c *gin.Context) {
+	status := c.Query("status")
+	if status != "" && !modelStatuses[status] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid status parameter, must be shadow, champion or retired"})
+		return
+	}
+
+	query := h.db.Model(&models.PredictionModel{}).
+		Order("CASE status WHEN 'champion' THEN 0 WHEN 'shadow' THEN 1 ELSE 2 END, created_at DESC")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var rows []models.PredictionModel
+	if err := query.Find(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database query failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": rows})
+}
+
+// RegisterModel adds a shadow model; the predictor starts running it on its
+// next cycle.
+func (h *ModelHandler) RegisterModel(c *gin.Context) {
+	var req RegisterModelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateModelParams(req.Params); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	params := json.RawMessage("{}")
+	if len(req.Params) > 0 {
+		var err error
+		if params, err = json.Marshal(req.Params); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid params"})
+			return
+		}
+	}
+	model := models.PredictionModel{
+		Version:     req.Version,
+		Status:      models.ModelShadow,
+		Params:      params,
+		Description: req.Description,
+	}
+	if err := h.db.Create(&model).Error; err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "model version already registered"})
+		return
+	}
+	c.JSON(http.StatusCreated, model)
+}
+
+// PromoteModel makes a shadow model champion and retires the previous
+// champion, provided the challenger's accuracy passes CheckPromotion.
+func (h *ModelHandler) PromoteModel(c *gin.Context) {
+	version := c.Param("version")
+	now := time.Now().UTC()
+
+	var promoted models.PredictionModel
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		// Each query needs its own locking chain: a chained *gorm.DB keeps its
+		// conditions, so reusing one would look the champion up by version.
+		forUpdate := func() *gorm.DB { return tx.Clauses(clause.Locking{Strength: "UPDATE"}) }
+		if err := forUpdate().First(&promoted, "version = ?", version).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errModelNotFound
+			}
+			return err
+		}
+
+		var champion *models.PredictionModel
+		var current models.PredictionModel
+		err := forUpdate().First(&current, "status = ?", models.ModelChampion).Error
+		switch {
+		case err == nil:
+			champion = &current
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			return err
+		}
+
+		if err := services.CheckPromotion(champion, promoted); err != nil {
+			return promotionRefused{err}
+		}
+
+		if champion != nil {
+			if err := tx.Model(champion).Updates(map[string]any{
+				"status": models.ModelRetired, "retired_at": now, "updated_at": now,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&promoted).Updates(map[string]any{
+			"status": models.ModelChampion, "promoted_at": now, "updated_at": now,
+		}).Error
+	})
+
+	var refused promotionRefused
+	switch {
+	case errors.Is(err, errModelNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "model not found"})
+	case errors.As(err, &refused):
+		c.JSON(http.StatusConflict, gin.H{"error": refused.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "promotion failed"})
+	default:
+		c.JSON(http.StatusOK, promoted)
+	}
+}
+
+// RetireModel stops a shadow model. The champion can only be replaced by a
+// promotion, never retired directly.
+func (h *ModelHandler) RetireModel(c *gin.Context) {
+	var model models.PredictionModel
+	if err := h.db.First(&model, "version = ?", c.Param("version")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "model not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database query failed"})
+		return
+	}
+	if model.Status == models.ModelChampion {
+		c.JSON(http.StatusConflict, gin.H{"error": "the champion cannot be retired, promote another model instead"})
+		return
+	}
+
+	now := time.Now().UTC()
+	if err := h.db.Model(&model).Updates(map[string]any{
+		"status": models.ModelRetired, "retired_at": now, "updated_at": now,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database update failed"})
+		return
+	}
+	c.JSON(http.StatusOK, model)
+}
+
+func validateModelParams(params map[string]json.RawMessage) error {
+	for key, raw := range params {
+		kind, ok := modelParamKinds[key]
+		if !ok {
+			return fmt.Errorf("unknown model param %q", key)
+		}
+		switch kind {
+		case "number":
+			var v float64
+			if err := json.Unmarshal(raw, &v); err != nil {
+				return fmt.Errorf("model param %q must be a number", key)
+			}
+		case "integer":
+			var v int
+			if err := json.Unmarshal(raw, &v); err != nil || v <= 0 {
+				return fmt.Errorf("model param %q must be a positive integer", key)
+			}
+		case "duration":
+			var s string
+			if err := json.Unmarshal(raw, &s); err != nil {
+				return fmt.Errorf("model param %q must be a duration string such as \"45m\"", key)
+			}
+			if d, err := time.ParseDuration(s); err != nil || d <= 0 {
+				return fmt.Errorf("model param %q must be a positive duration such as \"45m\"", key)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"traffic-prediction-api/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// fakeModels is an in-memory models table behind a database/sql driver. It
+// answers the queries PromoteModel sends, and enforces the single champion
+// index like Postgres would, so a promotion that forgets to retire the
+// previous champion fails.
+type fakeModels struct {
+	mu       sync.Mutex
+	rows     map[string]map[string]driver.Value
+	snapshot map[string]map[string]driver.Value
+}
+
+var modelColumns = []string{"version", "status", "params", "description", "mae", "evaluated",
+	"evaluated_at", "created_at", "promoted_at", "retired_at", "updated_at"}
+
+var (
+	selectWhere = regexp.MustCompile(`^SELECT \* FROM "models" WHERE (\w+) = \$1 `)
+	updateWhere = regexp.MustCompile(`^UPDATE "models" SET (.+) WHERE "version" = \$(\d+)$`)
+	assignment  = regexp.MustCompile(`"(\w+)"=\$(\d+)`)
+)
+
+func (f *fakeModels) Open(string) (driver.Conn, error) { return f, nil }
+func (f *fakeModels) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements not supported")
+}
+func (f *fakeModels) Close() error { return nil }
+
+func (f *fakeModels) Begin() (driver.Tx, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.snapshot = copyRows(f.rows)
+	return f, nil
+}
+
+func (f *fakeModels) Commit() error { return nil }
+
+func (f *fakeModels) Rollback() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rows = f.snapshot
+	return nil
+}
+
+func copyRows(rows map[string]map[string]driver.Value) map[string]map[string]driver.Value {
+	out := make(map[string]map[string]driver.Value, len(rows))
+	for k, row := range rows {
+		out[k] = make(map[string]driver.Value, len(row))
+		for c, v := range row {
+			out[k][c] = v
+		}
+	}
+	return out
+}
+
+func (f *fakeModels) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	m := selectWhere.FindStringSubmatch(query)
+	if m == nil {
+		return nil, fmt.Errorf("unexpected query %q", query)
+	}
+	rows := &fakeRows{}
+	for _, row := range f.rows {
+		if row[m[1]] == args[0].Value {
+			rows.rows = append(rows.rows, row)
+		}
+	}
+	return rows, nil
+}
+
+func (f *fakeModels) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	m := updateWhere.FindStringSubmatch(query)
+	if m == nil {
+		return nil, fmt.Errorf("unexpected statement %q", query)
+	}
+	arg := func(n string) driver.Value {
+		i, _ := strconv.Atoi(n)
+		return args[i-1].Value
+	}
+	row, ok := f.rows[arg(m[2]).(string)]
+	if !ok {
+		return driver.RowsAffected(0), nil
+	}
+	for _, a := range assignment.FindAllStringSubmatch(m[1], -1) {
+		row[a[1]] = arg(a[2])
+	}
+	champions := 0
+	for _, r := range f.rows {
+		if r["status"] == models.ModelChampion {
+			champions++
+		}
+	}
+	if champions > 1 {
+		return nil, errors.New(`duplicate key value violates unique constraint "idx_models_single_champion"`)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+type fakeRows struct {
+	rows []map[string]driver.Value
+	next int
+}
+
+func (r *fakeRows) Columns() []string { return modelColumns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.rows) {
+		return io.EOF
+	}
+	for i, c := range modelColumns {
+		dest[i] = r.rows[r.next][c]
+	}
+	r.next++
+	return nil
+}
+
+func modelRow(version, status string, mae float64, params string) map[string]driver.Value {
+	created := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	return map[string]driver.Value{
+		"version": version, "status": status, "params": []byte(params), "mae": mae, "evaluated": int64(500),
+		"created_at": created, "updated_at": created,
+	}
+}
+
+func TestPromoteModelRetiresChampion(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	fake := &fakeModels{rows: map[string]map[string]driver.Value{
+		"ewma-lr-v2": modelRow("ewma-lr-v2", models.ModelChampion, 0.08, "{}"),
+		"tuned":      modelRow("tuned", models.ModelShadow, 0.06, `{"alpha": 0.5}`),
+		"worse":      modelRow("worse", models.ModelShadow, 0.09, "{}"),
+	}}
+	name := fmt.Sprintf("fake-models-%p", fake)
+	sql.Register(name, fake)
+	db, err := gorm.Open(postgres.New(postgres.Config{DriverName: name}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	r.POST("/models/:version/promote", NewModelHandler(db).PromoteModel)
+	promote := func(version string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/models/"+version+"/promote", strings.NewReader("")))
+		return w
+	}
+
+	if w := promote("worse"); w.Code != http.StatusConflict {
+		t.Errorf("less accurate challenger: status %d (%s), want 409", w.Code, w.Body)
+	}
+	w := promote("tuned")
+	if w.Code != http.StatusOK {
+		t.Fatalf("promote: status %d (%s), want 200", w.Code, w.Body)
+	}
+	var got models.PredictionModel
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil || got.Status != models.ModelChampion {
+		t.Errorf("promoted = %+v (%v), want champion", got, err)
+	}
+	if s := fake.rows["ewma-lr-v2"]["status"]; s != models.ModelRetired || fake.rows["ewma-lr-v2"]["retired_at"] == nil {
+		t.Errorf("previous champion status = %v, want retired with retired_at", s)
+	}
+	if s := fake.rows["tuned"]["status"]; s != models.ModelChampion {
+		t.Errorf("challenger status = %v, want champion", s)
+	}
+}
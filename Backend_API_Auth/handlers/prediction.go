@@ -47,6 +47,7 @@ func (h *PredictionHandler) GetPredictions(c *gin.Context) {
 	}
 
 	query := h.db.Model(&models.Prediction{}).
+		Omit("components").
 		Where("horizon_min = ?", horizon).
 		Order("ts DESC").
 		Limit(p.Limit + 1)
@@ -79,3 +80,58 @@ func (h *PredictionHandler) GetPredictions(c *gin.Context) {
 
 	c.JSON(http.StatusOK, resp)
 }
+
+// GetPrediction returns one prediction of a road with the components that
+// explain its score: the one at ts, or the latest when ts is omitted.
+func (h *PredictionHandler) GetPrediction(c *gin.Context) {
+	roadID := c.Param("road_id")
+
+	horizon, err := strconv.Atoi(c.DefaultQuery("horizon", "30"))
+	if err != nil || horizon <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid horizon parameter, must be a positive integer"})
+		return
+	}
+
+	var ts *time.Time
+	if tsStr := c.Query("ts"); tsStr != "" {
+		t, err := time.Parse(time.RFC3339Nano, tsStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ts parameter, must be RFC3339"})
+			return
+		}
+		ts = &t
+	}
+
+	tsKey := "latest"
+	if ts != nil {
+		tsKey = ts.UTC().Format(time.RFC3339Nano)
+	}
+	cacheKey := fmt.Sprintf("prediction:%s:%d:%s", roadID, horizon, tsKey)
+
+	var cached models.Prediction
+	if err := h.cache.Get(c.Request.Context(), cacheKey, &cached); err == nil && cached.RoadID != "" {
+		c.JSON(http.StatusOK, gin.H{"data": cached})
+		return
+	}
+
+	query := h.db.Where("road_id = ? AND horizon_min = ?", roadID, horizon)
+	if ts != nil {
+		query = query.Where("ts = ?", *ts)
+	} else {
+		query = query.Order("ts DESC")
+	}
+
+	var row models.Prediction
+	if err := query.Limit(1).Find(&row).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database query failed"})
+		return
+	}
+	if row.RoadID == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "prediction not found"})
+		return
+	}
+
+	go h.cache.Set(context.Background(), cacheKey, row, 30*time.Second)
+
+	c.JSON(http.StatusOK, gin.H{"data": row})
+}
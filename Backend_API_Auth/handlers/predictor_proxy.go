@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PredictorProxy forwards on-demand prediction requests to the predictor
+// service's POST /predict endpoint.
+type PredictorProxy struct {
+	url    string
+	client *http.Client
+}
+
+func NewPredictorProxy(baseURL string) *PredictorProxy {
+	return &PredictorProxy{
+		url:    strings.TrimRight(baseURL, "/") + "/predict",
+		client: &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+func (h *PredictorProxy) Refresh(c *gin.Context) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, 1<<20))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read request body"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 15*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to build predictor request"})
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "predictor unavailable"})
+		return
+	}
+	defer resp.Body.Close()
+
+	c.DataFromReader(resp.StatusCode, resp.ContentLength, "application/json", resp.Body, nil)
+}
@@ -13,6 +13,8 @@ import (
 	"gorm.io/gorm"
 )
 
+var rerouteEvents = map[string]bool{"activated": true, "updated": true, "cleared": true}
+
 type RerouteHandler struct {
 	db    *gorm.DB
 	cache *services.CacheService
@@ -22,15 +24,25 @@ func NewRerouteHandler(db *gorm.DB, cache *services.CacheService) *RerouteHandle
 	return &RerouteHandler{db: db, cache: cache}
 }
 
+// GetRecommended lists reroute events, newest first. active=true keeps the
+// latest event of each road whose recommendation is still active; event
+// filters on the lifecycle event.
 func (h *RerouteHandler) GetRecommended(c *gin.Context) {
 	p := ParsePagination(c)
 	routeID := c.Query("route_id")
+	event := c.Query("event")
+	active := c.Query("active") == "true"
+
+	if event != "" && !rerouteEvents[event] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid event parameter, must be activated, updated or cleared"})
+		return
+	}
 
 	beforeStr := ""
 	if p.Before != nil {
 		beforeStr = p.Before.Format(time.RFC3339Nano)
 	}
-	cacheKey := fmt.Sprintf("reroutes:%s:%d:%s", routeID, p.Limit, beforeStr)
+	cacheKey := fmt.Sprintf("reroutes:%s:%s:%t:%d:%s", routeID, event, active, p.Limit, beforeStr)
 
 	var cached CursorResponse
 	if err := h.cache.Get(c.Request.Context(), cacheKey, &cached); err == nil && cached.Data != nil {
@@ -45,6 +57,14 @@ func (h *RerouteHandler) GetRecommended(c *gin.Context) {
 	if routeID != "" {
 		query = query.Where("route_id = ?", routeID)
 	}
+	if event != "" {
+		query = query.Where("event = ?", event)
+	}
+	if active {
+		query = query.Where(`event IN ('activated', 'updated') AND (route_id, ts) IN (
+			SELECT DISTINCT ON (route_id) route_id, ts FROM reroutes
+			WHERE event IS NOT NULL ORDER BY route_id, ts DESC)`)
+	}
 
 	var rows []models.Reroute
 	if err := query.Find(&rows).Error; err != nil {
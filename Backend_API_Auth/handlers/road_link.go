@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"traffic-prediction-api/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// turnRestrictions are the OSM restriction values accepted on a road link.
+var turnRestrictions = map[string]bool{
+	"no_left_turn": true, "no_right_turn": true, "no_straight_on": true, "no_u_turn": true, "no_entry": true,
+}
+
+// RoadLinkHandler edits the road graph. The rerouter is notified of every
+// change by a trigger on road_links and reloads its graph.
+type RoadLinkHandler struct {
+	db *gorm.DB
+}
+
+func NewRoadLinkHandler(db *gorm.DB) *RoadLinkHandler {
+	return &RoadLinkHandler{db: db}
+}
+
+type RoadLinkRequest struct {
+	FromRoadID  string   `json:"from_road_id" binding:"required"`
+	ToRoadID    string   `json:"to_road_id" binding:"required,nefield=FromRoadID"`
+	LengthM     *float64 `json:"length_m" binding:"omitempty,gt=0"`
+	Restriction *string  `json:"restriction"`
+}
+
+var linkStatuses = map[string]bool{models.LinkCandidate: true, models.LinkApproved: true, models.LinkRejected: true}
+
+// GetRoadLinks lists the links, optionally those touching road_id or in one
+// review status. Candidates are listed best score first.
+func (h *RoadLinkHandler) GetRoadLinks(c *gin.Context) {
+	status := c.Query("status")
+	if status != "" && !linkStatuses[status] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid status parameter, must be candidate, approved or rejected"})
+		return
+	}
+
+	query := h.db.Model(&models.RoadLink{})
+	if status == models.LinkCandidate {
+		query = query.Order("score DESC NULLS LAST, from_road_id, to_road_id")
+	} else {
+		query = query.Order("from_road_id, to_road_id")
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if roadID := c.Query("road_id"); roadID != "" {
+		query = query.Where("(from_road_id = ? OR to_road_id = ?)", roadID, roadID)
+	}
+
+	var links []models.RoadLink
+	if err := query.Find(&links).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database query failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": links})
+}
+
+// PutRoadLink creates or replaces the link between two known roads or
+// imported segments. A link edited by hand is approved.
+func (h *RoadLinkHandler) PutRoadLink(c *gin.Context) {
+	var req RoadLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Restriction != nil && !turnRestrictions[*req.Restriction] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid restriction, must be no_left_turn, no_right_turn, no_straight_on, no_u_turn or no_entry"})
+		return
+	}
+
+	var known int64
+	// Imported segments without a sensor are graph nodes too.
+	if err := h.db.Raw(`
+		SELECT COUNT(DISTINCT road_id) FROM (
+			SELECT road_id FROM roads UNION ALL SELECT road_id FROM road_segments
+		) nodes WHERE road_id IN ?
+	`, []string{req.FromRoadID, req.ToRoadID}).Scan(&known).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database query failed"})
+		return
+	}
+	if known != 2 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown road_id"})
+		return
+	}
+
+	link := models.RoadLink{
+		FromRoadID:  req.FromRoadID,
+		ToRoadID:    req.ToRoadID,
+		LengthM:     req.LengthM,
+		Restriction: req.Restriction,
+		Status:      models.LinkApproved,
+		Source:      "manual",
+		UpdatedAt:   time.Now().UTC(),
+	}
+	if err := h.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "from_road_id"}, {Name: "to_road_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"length_m", "restriction", "status", "source", "updated_at"}),
+	}).Create(&link).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database update failed"})
+		return
+	}
+	c.JSON(http.StatusOK, link)
+}
+
+// DeleteRoadLink removes the link from :from to :to.
+func (h *RoadLinkHandler) DeleteRoadLink(c *gin.Context) {
+	res := h.db.Where("from_road_id = ? AND to_road_id = ?", c.Param("from"), c.Param("to")).Delete(&models.RoadLink{})
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database update failed"})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "road link not found"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ApproveRoadLink makes the link from :from to :to usable by the rerouter.
+func (h *RoadLinkHandler) ApproveRoadLink(c *gin.Context) {
+	h.review(c, models.LinkApproved)
+}
+
+// RejectRoadLink discards the link; derive-links will not propose it again.
+func (h *RoadLinkHandler) RejectRoadLink(c *gin.Context) {
+	h.review(c, models.LinkRejected)
+}
+
+func (h *RoadLinkHandler) review(c *gin.Context, status string) {
+	res := h.db.Model(&models.RoadLink{}).
+		Where("from_road_id = ? AND to_road_id = ?", c.Param("from"), c.Param("to")).
+		Updates(map[string]any{"status": status, "updated_at": time.Now().UTC()})
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database update failed"})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "road link not found"})
+		return
+	}
+
+	var link models.RoadLink
+	h.db.First(&link, "from_road_id = ? AND to_road_id = ?", c.Param("from"), c.Param("to"))
+	c.JSON(http.StatusOK, link)
+}
+
+// ApproveCandidates approves in one go every candidate scoring at least
+// min_score, for operators who trust the closest derived links.
+func (h *RoadLinkHandler) ApproveCandidates(c *gin.Context) {
+	minScore, err := strconv.ParseFloat(c.Query("min_score"), 64)
+	if err != nil || minScore < 0 || minScore > 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "min_score is required and must be between 0 and 1"})
+		return
+	}
+
+	res := h.db.Model(&models.RoadLink{}).
+		Where("status = ? AND score >= ?", models.LinkCandidate, minScore).
+		Updates(map[string]any{"status": models.LinkApproved, "updated_at": time.Now().UTC()})
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database update failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"approved": res.RowsAffected})
+}
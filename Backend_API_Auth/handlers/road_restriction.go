@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"traffic-prediction-api/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var restrictionKinds = map[string]bool{
+	models.RestrictionClosed: true, models.RestrictionReducedLanes: true, models.RestrictionHGVBanned: true,
+}
+
+// RoadRestrictionHandler lets admins enter roadworks, closures and other
+// temporary restrictions, and every user list them. The rerouter and the
+// predictor read them every cycle.
+type RoadRestrictionHandler struct {
+	db *gorm.DB
+}
+
+func NewRoadRestrictionHandler(db *gorm.DB) *RoadRestrictionHandler {
+	return &RoadRestrictionHandler{db: db}
+}
+
+type RoadRestrictionRequest struct {
+	RoadID    string     `json:"road_id" binding:"required"`
+	Kind      string     `json:"kind" binding:"required"`
+	LanesOpen *int       `json:"lanes_open" binding:"omitempty,gt=0"`
+	StartsAt  *time.Time `json:"starts_at"`
+	EndsAt    *time.Time `json:"ends_at"`
+	Reason    *string    `json:"reason"`
+}
+
+// validate checks the request and returns its start, now by default.
+func (req RoadRestrictionRequest) validate(now time.Time) (time.Time, error) {
+	if !restrictionKinds[req.Kind] {
+		return time.Time{}, errors.New("invalid kind, must be closed, reduced_lanes or hgv_banned")
+	}
+	if (req.Kind == models.RestrictionReducedLanes) != (req.LanesOpen != nil) {
+		return time.Time{}, errors.New("lanes_open is required for reduced_lanes, and only for it")
+	}
+	start := now
+	if req.StartsAt != nil {
+		start = req.StartsAt.UTC()
+	}
+	if req.EndsAt != nil && !req.EndsAt.After(start) {
+		return time.Time{}, errors.New("ends_at must be after starts_at")
+	}
+	return start, nil
+}
+
+// GetRoadRestrictions lists restrictions, soonest first. active=true keeps
+// those in force now, current=true those not over yet.
+func (h *RoadRestrictionHandler) GetRoadRestrictions(c *gin.Context) {
+	now := time.Now().UTC()
+	query := h.db.Model(&models.RoadRestriction{}).Order("starts_at, id")
+	if roadID := c.Query("road_id"); roadID != "" {
+		query = query.Where("road_id = ?", roadID)
+	}
+	if kind := c.Query("kind"); kind != "" {
+		if !restrictionKinds[kind] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid kind parameter, must be closed, reduced_lanes or hgv_banned"})
+			return
+		}
+		query = query.Where("kind = ?", kind)
+	}
+	if c.Query("active") == "true" {
+		query = query.Where("starts_at <= ?", now)
+	}
+	if c.Query("active") == "true" || c.Query("current") == "true" {
+		query = query.Where("(ends_at IS NULL OR ends_at > ?)", now)
+	}
+
+	var restrictions []models.RoadRestriction
+	if err := query.Find(&restrictions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database query failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": restrictions})
+}
+
+// CreateRoadRestriction adds a restriction on a known road or imported
+// segment.
+func (h *RoadRestrictionHandler) CreateRoadRestriction(c *gin.Context) {
+	var req RoadRestrictionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	now := time.Now().UTC()
+	start, err := req.validate(now)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if ok, err := h.knownRoad(req.RoadID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database query failed"})
+		return
+	} else if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown road_id"})
+		return
+	}
+
+	r := models.RoadRestriction{
+		RoadID:    req.RoadID,
+		Kind:      req.Kind,
+		LanesOpen: req.LanesOpen,
+		StartsAt:  start,
+		EndsAt:    req.EndsAt,
+		Reason:    req.Reason,
+		CreatedBy: c.GetString("userEmail"),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := h.db.Create(&r).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database update failed"})
+		return
+	}
+	c.JSON(http.StatusCreated, r)
+}
+
+// UpdateRoadRestriction replaces restriction :id, for instance to extend its
+// end.
+func (h *RoadRestrictionHandler) UpdateRoadRestriction(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	var req RoadRestrictionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var r models.RoadRestriction
+	if err := h.db.First(&r, id).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "road restriction not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database query failed"})
+		return
+	}
+	// Without starts_at the restriction keeps its start.
+	if req.StartsAt == nil {
+		req.StartsAt = &r.StartsAt
+	}
+	start, err := req.validate(time.Now().UTC())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.RoadID != r.RoadID {
+		if ok, err := h.knownRoad(req.RoadID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "database query failed"})
+			return
+		} else if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown road_id"})
+			return
+		}
+	}
+
+	r.RoadID, r.Kind, r.LanesOpen = req.RoadID, req.Kind, req.LanesOpen
+	r.StartsAt, r.EndsAt, r.Reason = start, req.EndsAt, req.Reason
+	r.UpdatedAt = time.Now().UTC()
+	if err := h.db.Save(&r).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database update failed"})
+		return
+	}
+	c.JSON(http.StatusOK, r)
+}
+
+// DeleteRoadRestriction removes restriction :id. To lift a restriction while
+// keeping its history, set its ends_at instead.
+func (h *RoadRestrictionHandler) DeleteRoadRestriction(c *gin.Context) {
+	res := h.db.Where("id = ?", c.Param("id")).Delete(&models.RoadRestriction{})
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database update failed"})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "road restriction not found"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// knownRoad reports whether roadID is a sensor road or an imported segment.
+func (h *RoadRestrictionHandler) knownRoad(roadID string) (bool, error) {
+	var known int64
+	err := h.db.Raw(`
+		SELECT COUNT(*) FROM (
+			SELECT road_id FROM roads UNION ALL SELECT road_id FROM road_segments
+		) nodes WHERE road_id = ?
+	`, roadID).Scan(&known).Error
+	return known > 0, err
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRoadRestrictionRequestValidate(t *testing.T) {
+	now := time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)
+	at := func(h int) *time.Time { t := now.Add(time.Duration(h) * time.Hour); return &t }
+	lanes := func(n int) *int { return &n }
+
+	for name, tc := range map[string]struct {
+		req     RoadRestrictionRequest
+		wantErr bool
+	}{
+		"closed":                  {RoadRestrictionRequest{RoadID: "A", Kind: "closed"}, false},
+		"reduced lanes":           {RoadRestrictionRequest{RoadID: "A", Kind: "reduced_lanes", LanesOpen: lanes(1)}, false},
+		"hgv banned with window":  {RoadRestrictionRequest{RoadID: "A", Kind: "hgv_banned", StartsAt: at(1), EndsAt: at(3)}, false},
+		"unknown kind":            {RoadRestrictionRequest{RoadID: "A", Kind: "flooded"}, true},
+		"reduced without lanes":   {RoadRestrictionRequest{RoadID: "A", Kind: "reduced_lanes"}, true},
+		"lanes on a closure":      {RoadRestrictionRequest{RoadID: "A", Kind: "closed", LanesOpen: lanes(1)}, true},
+		"ends before start":       {RoadRestrictionRequest{RoadID: "A", Kind: "closed", StartsAt: at(2), EndsAt: at(1)}, true},
+		"ends at start":           {RoadRestrictionRequest{RoadID: "A", Kind: "closed", StartsAt: at(1), EndsAt: at(1)}, true},
+		"ends before default now": {RoadRestrictionRequest{RoadID: "A", Kind: "closed", EndsAt: at(-1)}, true},
+	} {
+		start, err := tc.req.validate(now)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: err = %v, want error %v", name, err, tc.wantErr)
+		}
+		if err == nil && tc.req.StartsAt == nil && !start.Equal(now) {
+			t.Errorf("%s: start = %v, want now", name, start)
+		}
+	}
+}
+
+func TestCreateRoadRestrictionRejectsInvalidRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	// Invalid requests are rejected before the database is touched.
+	h := NewRoadRestrictionHandler(nil)
+	router := gin.New()
+	router.POST("/road-restrictions", h.CreateRoadRestriction)
+
+	for _, body := range []string{
+		`{"kind":"closed"}`,
+		`{"road_id":"A","kind":"flooded"}`,
+		`{"road_id":"A","kind":"reduced_lanes","lanes_open":0}`,
+		`{"road_id":"A","kind":"reduced_lanes","lanes_open":-1}`,
+		`{"road_id":"A","kind":"closed","starts_at":"2026-03-02T10:00:00Z","ends_at":"2026-03-02T09:00:00Z"}`,
+		`{"road_id":"A","kind":"closed","ends_at":"tomorrow"}`,
+	} {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/road-restrictions", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400: %s", body, rec.Code, rec.Body)
+		}
+	}
+}
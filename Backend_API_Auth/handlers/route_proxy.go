@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// routeQueryParams are the query parameters forwarded to the rerouter.
+var routeQueryParams = []string{"from", "to", "depart_at", "alternatives", "vehicle"}
+
+// RouteProxy forwards origin-destination route queries to the rerouter
+// service's GET /routes endpoint, which validates them and ranks the routes.
+type RouteProxy struct {
+	url    string
+	client *http.Client
+}
+
+func NewRouteProxy(baseURL string) *RouteProxy {
+	return &RouteProxy{
+		url:    strings.TrimRight(baseURL, "/") + "/routes",
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (h *RouteProxy) GetRoutes(c *gin.Context) {
+	if c.Query("from") == "" || c.Query("to") == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from and to are required, as lat,lng"})
+		return
+	}
+	q := url.Values{}
+	for _, key := range routeQueryParams {
+		if v := c.Query(key); v != "" {
+			q.Set(key, v)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.url+"?"+q.Encode(), nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to build rerouter request"})
+		return
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "rerouter unavailable"})
+		return
+	}
+	defer resp.Body.Close()
+
+	c.DataFromReader(resp.StatusCode, resp.ContentLength, "application/json", resp.Body, nil)
+}
@@ -14,12 +14,13 @@ import (
 )
 
 type TrafficHandler struct {
-	db    *gorm.DB
-	cache *services.CacheService
+	db       *gorm.DB
+	cache    *services.CacheService
+	calendar *services.Calendar
 }
 
-func NewTrafficHandler(db *gorm.DB, cache *services.CacheService) *TrafficHandler {
-	return &TrafficHandler{db: db, cache: cache}
+func NewTrafficHandler(db *gorm.DB, cache *services.CacheService, calendar *services.Calendar) *TrafficHandler {
+	return &TrafficHandler{db: db, cache: cache, calendar: calendar}
 }
 
 func (h *TrafficHandler) GetLive(c *gin.Context) {
@@ -67,3 +68,108 @@ func (h *TrafficHandler) GetLive(c *gin.Context) {
 
 	c.JSON(http.StatusOK, resp)
 }
+
+// historyViews maps the resolution query parameter to its continuous aggregate.
+// The 1d resolution is rolled up from traffic_1h on local calendar days.
+var historyViews = map[string]string{
+	"5m": "traffic_5m",
+	"1h": "traffic_1h",
+	"1d": "traffic_1h",
+}
+
+func (h *TrafficHandler) GetHistory(c *gin.Context) {
+	p := ParsePagination(c)
+	roadID := c.Query("road_id")
+	resolution := c.DefaultQuery("resolution", "5m")
+
+	view, ok := historyViews[resolution]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid resolution parameter, must be 5m, 1h or 1d"})
+		return
+	}
+
+	var from *time.Time
+	if fromStr := c.Query("from"); fromStr != "" {
+		t, err := time.Parse(time.RFC3339Nano, fromStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from parameter, must be RFC3339"})
+			return
+		}
+		from = &t
+	}
+
+	// day selects one local calendar day in the city timezone.
+	var to *time.Time
+	day := c.Query("day")
+	if day != "" {
+		start, end, err := h.calendar.DayBounds(day)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid day parameter, must be YYYY-MM-DD"})
+			return
+		}
+		if from == nil || from.Before(start) {
+			from = &start
+		}
+		to = &end
+	}
+
+	beforeStr := ""
+	if p.Before != nil {
+		beforeStr = p.Before.Format(time.RFC3339Nano)
+	}
+	fromKey := ""
+	if from != nil {
+		fromKey = from.Format(time.RFC3339Nano)
+	}
+	cacheKey := fmt.Sprintf("traffic:history:%s:%s:%d:%s:%s:%s", resolution, roadID, p.Limit, beforeStr, fromKey, day)
+
+	var cached CursorResponse
+	if err := h.cache.Get(c.Request.Context(), cacheKey, &cached); err == nil && cached.Data != nil {
+		c.JSON(http.StatusOK, cached)
+		return
+	}
+
+	query := h.db.Table(view).Order("bucket DESC").Limit(p.Limit + 1)
+	if resolution == "1d" {
+		// WHERE filters on the hourly bucket; ORDER BY uses the daily alias.
+		query = query.Select(`time_bucket('1 day', bucket, ?) AS bucket, road_id,
+			SUM(avg_speed * samples) / SUM(samples) AS avg_speed,
+			SUM(avg_occ * samples) / SUM(samples) AS avg_occ,
+			SUM(avg_flow * samples) / SUM(samples) AS avg_flow,
+			SUM(samples) AS samples`, h.calendar.Location().String()).
+			Group("1, road_id")
+	}
+	if p.Before != nil {
+		query = query.Where("bucket < ?", *p.Before)
+	}
+	if from != nil {
+		query = query.Where("bucket >= ?", *from)
+	}
+	if to != nil {
+		query = query.Where("bucket < ?", *to)
+	}
+	if roadID != "" {
+		query = query.Where("road_id = ?", roadID)
+	}
+
+	var rows []models.TrafficAggregate
+	if err := query.Find(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database query failed"})
+		return
+	}
+
+	hasMore := len(rows) > p.Limit
+	if hasMore {
+		rows = rows[:p.Limit]
+	}
+
+	var nextCursor string
+	if hasMore && len(rows) > 0 {
+		nextCursor = rows[len(rows)-1].Bucket.Format(time.RFC3339Nano)
+	}
+
+	resp := CursorResponse{Data: rows, NextCursor: nextCursor, HasMore: hasMore}
+	go h.cache.Set(context.Background(), cacheKey, resp, 30*time.Second)
+
+	c.JSON(http.StatusOK, resp)
+}
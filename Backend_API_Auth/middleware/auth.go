@@ -35,3 +35,15 @@ func JWTAuth(authService *services.AuthService) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// RequireRole rejects requests whose token does not carry role. It must run
+// after JWTAuth.
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("userRole") != role {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "insufficient permissions"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Incident is an abrupt speed collapse or occupancy spike detected by the
+// predictor. EndedAt is nil while the incident is open.
+type Incident struct {
+	ID                int64      `gorm:"column:id;primaryKey" json:"id"`
+	RoadID            string     `gorm:"column:road_id" json:"road_id"`
+	Kind              string     `gorm:"column:kind" json:"kind"`
+	Severity          string     `gorm:"column:severity" json:"severity"`
+	StartedAt         time.Time  `gorm:"column:started_at" json:"started_at"`
+	EndedAt           *time.Time `gorm:"column:ended_at" json:"ended_at"`
+	SpeedKMH          *float64   `gorm:"column:speed_kmh" json:"speed_kmh"`
+	BaselineSpeedKMH  *float64   `gorm:"column:baseline_speed_kmh" json:"baseline_speed_kmh"`
+	Occupancy         *float64   `gorm:"column:occupancy" json:"occupancy"`
+	BaselineOccupancy *float64   `gorm:"column:baseline_occupancy" json:"baseline_occupancy"`
+	UpdatedAt         time.Time  `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (Incident) TableName() string { return "incidents" }
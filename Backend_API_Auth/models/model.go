@@ -0,0 +1,33 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Statuses of a registered prediction model. Exactly one model is champion:
+// its predictions feed the rerouter and the dashboard. Shadow models run
+// alongside it and only write to shadow_predictions.
+const (
+	ModelShadow   = "shadow"
+	ModelChampion = "champion"
+	ModelRetired  = "retired"
+)
+
+// PredictionModel is a row of the model registry. MAE and Evaluated are the
+// accuracy scores maintained by the predictor over the last 24 hours.
+type PredictionModel struct {
+	Version     string          `gorm:"column:version;primaryKey" json:"version"`
+	Status      string          `gorm:"column:status" json:"status"`
+	Params      json.RawMessage `gorm:"column:params;type:jsonb" json:"params"`
+	Description *string         `gorm:"column:description" json:"description"`
+	MAE         *float64        `gorm:"column:mae" json:"mae"`
+	Evaluated   int             `gorm:"column:evaluated" json:"evaluated"`
+	EvaluatedAt *time.Time      `gorm:"column:evaluated_at" json:"evaluated_at"`
+	CreatedAt   time.Time       `gorm:"column:created_at" json:"created_at"`
+	PromotedAt  *time.Time      `gorm:"column:promoted_at" json:"promoted_at"`
+	RetiredAt   *time.Time      `gorm:"column:retired_at" json:"retired_at"`
+	UpdatedAt   time.Time       `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (PredictionModel) TableName() string { return "models" }
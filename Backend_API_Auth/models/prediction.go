@@ -1,14 +1,22 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 type Prediction struct {
 	TS              time.Time `gorm:"column:ts;primaryKey" json:"ts"`
 	RoadID          string    `gorm:"column:road_id;primaryKey" json:"road_id"`
 	HorizonMin      int       `gorm:"column:horizon_min;primaryKey;default:30" json:"horizon_min"`
 	CongestionScore float64   `gorm:"column:congestion_score" json:"congestion_score"`
+	CongestionP10   *float64  `gorm:"column:congestion_p10" json:"congestion_p10"`
+	CongestionP90   *float64  `gorm:"column:congestion_p90" json:"congestion_p90"`
 	Confidence      *float64  `gorm:"column:confidence" json:"confidence"`
 	ModelVersion    string    `gorm:"column:model_version" json:"model_version"`
+	// Components holds the values behind the score (subscores, regression
+	// slope, EWMA blend, rush-hour factor, ...). Only loaded on detail requests.
+	Components json.RawMessage `gorm:"column:components;type:jsonb" json:"components,omitempty"`
 }
 
 func (Prediction) TableName() string { return "predictions" }
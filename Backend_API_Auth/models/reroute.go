@@ -1,7 +1,13 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
+// Reroute is a lifecycle event of a reroute recommendation. EstimatedCO2Gain
+// is the CO2 the diverted volume saves, in kg per hour, and ETAGainMin the
+// time each diverted vehicle saves.
 type Reroute struct {
 	TS               time.Time `gorm:"column:ts;primaryKey" json:"ts"`
 	RouteID          string    `gorm:"column:route_id;primaryKey" json:"route_id"`
@@ -9,6 +15,15 @@ type Reroute struct {
 	Reason           string    `gorm:"column:reason" json:"reason"`
 	EstimatedCO2Gain *float64  `gorm:"column:estimated_co2_gain" json:"estimated_co2_gain"`
 	ETAGainMin       *float64  `gorm:"column:eta_gain_min" json:"eta_gain_min"`
+	// AltPath is the detour's road ids, from the road before RouteID to the
+	// road after it.
+	AltPath json.RawMessage `gorm:"column:alt_path;type:jsonb" json:"alt_path,omitempty"`
+	// DivertedVPH is the volume, in vehicles per hour, assigned to the detour.
+	DivertedVPH *float64 `gorm:"column:diverted_vph" json:"diverted_vph,omitempty"`
+	// Event is the lifecycle event of the row (activated, updated or
+	// cleared), nil for rows written before recommendations had a lifecycle.
+	Event       *string    `gorm:"column:event" json:"event,omitempty"`
+	ActiveSince *time.Time `gorm:"column:active_since" json:"active_since,omitempty"`
 }
 
 func (Reroute) TableName() string { return "reroutes" }
@@ -3,11 +3,15 @@ package models
 import "time"
 
 type Road struct {
-	RoadID    string    `gorm:"column:road_id;primaryKey" json:"road_id"`
-	Label     string    `gorm:"column:label" json:"label"`
-	Lat       *float64  `gorm:"column:lat" json:"lat"`
-	Lng       *float64  `gorm:"column:lng" json:"lng"`
-	UpdatedAt time.Time `gorm:"column:updated_at" json:"updated_at"`
+	RoadID           string    `gorm:"column:road_id;primaryKey" json:"road_id"`
+	Label            string    `gorm:"column:label" json:"label"`
+	Lat              *float64  `gorm:"column:lat" json:"lat"`
+	Lng              *float64  `gorm:"column:lng" json:"lng"`
+	RoadClass        string    `gorm:"column:road_class" json:"road_class"`
+	FreeFlowSpeedKMH *float64  `gorm:"column:free_flow_speed_kmh" json:"free_flow_speed_kmh"`
+	CapacityVPH      *float64  `gorm:"column:capacity_vph" json:"capacity_vph"`
+	Lanes            *int      `gorm:"column:lanes" json:"lanes"`
+	UpdatedAt        time.Time `gorm:"column:updated_at" json:"updated_at"`
 }
 
 func (Road) TableName() string { return "roads" }
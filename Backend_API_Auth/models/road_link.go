@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Review statuses of a road link. Links derived from coordinates start as
+// candidates; only approved links are used for routing and forecasts.
+const (
+	LinkCandidate = "candidate"
+	LinkApproved  = "approved"
+	LinkRejected  = "rejected"
+)
+
+// RoadLink is a directed edge of the road graph: traffic on FromRoadID can
+// turn into ToRoadID unless Restriction forbids the movement. Source is
+// manual, proximity, osm or geojson; Score is the confidence of a derived link.
+type RoadLink struct {
+	FromRoadID  string    `gorm:"column:from_road_id;primaryKey" json:"from_road_id"`
+	ToRoadID    string    `gorm:"column:to_road_id;primaryKey" json:"to_road_id"`
+	LengthM     *float64  `gorm:"column:length_m" json:"length_m"`
+	Restriction *string   `gorm:"column:restriction" json:"restriction"`
+	Status      string    `gorm:"column:status" json:"status"`
+	Source      string    `gorm:"column:source" json:"source"`
+	Score       *float64  `gorm:"column:score" json:"score"`
+	UpdatedAt   time.Time `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (RoadLink) TableName() string { return "road_links" }
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Kinds of road restriction.
+const (
+	RestrictionClosed       = "closed"
+	RestrictionReducedLanes = "reduced_lanes"
+	RestrictionHGVBanned    = "hgv_banned"
+)
+
+// RoadRestriction is a temporary restriction on a road, from StartsAt until
+// EndsAt, or until it is removed when EndsAt is nil. LanesOpen is set for
+// reduced_lanes only.
+type RoadRestriction struct {
+	ID        int64      `gorm:"column:id;primaryKey" json:"id"`
+	RoadID    string     `gorm:"column:road_id" json:"road_id"`
+	Kind      string     `gorm:"column:kind" json:"kind"`
+	LanesOpen *int       `gorm:"column:lanes_open" json:"lanes_open"`
+	StartsAt  time.Time  `gorm:"column:starts_at" json:"starts_at"`
+	EndsAt    *time.Time `gorm:"column:ends_at" json:"ends_at"`
+	Reason    *string    `gorm:"column:reason" json:"reason"`
+	CreatedBy string     `gorm:"column:created_by" json:"created_by"`
+	CreatedAt time.Time  `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt time.Time  `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (RoadRestriction) TableName() string { return "road_restrictions" }
@@ -12,3 +12,14 @@ type TrafficRaw struct {
 }
 
 func (TrafficRaw) TableName() string { return "traffic_raw" }
+
+// TrafficAggregate is a per-road bucket from the traffic_5m or traffic_1h
+// continuous aggregate.
+type TrafficAggregate struct {
+	Bucket   time.Time `gorm:"column:bucket" json:"bucket"`
+	RoadID   string    `gorm:"column:road_id" json:"road_id"`
+	AvgSpeed float64   `gorm:"column:avg_speed" json:"avg_speed"`
+	AvgOcc   float64   `gorm:"column:avg_occ" json:"avg_occ"`
+	AvgFlow  float64   `gorm:"column:avg_flow" json:"avg_flow"`
+	Samples  int64     `gorm:"column:samples" json:"samples"`
+}
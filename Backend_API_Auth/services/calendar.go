@@ -0,0 +1,44 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"traffic-prediction-api/config"
+)
+
+const dayLayout = "2006-01-02"
+
+// Calendar resolves local days in the city timezone. Day boundaries follow
+// DST, so a day can last 23 or 25 hours.
+type Calendar struct {
+	loc *time.Location
+}
+
+func NewCalendar(cfg config.CityConfig) (*Calendar, error) {
+	loc, err := time.LoadLocation(cfg.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("load timezone %q: %w", cfg.Timezone, err)
+	}
+	return &Calendar{loc: loc}, nil
+}
+
+func (c *Calendar) Location() *time.Location {
+	return c.loc
+}
+
+// StartOfDay returns local midnight of the day containing t.
+func (c *Calendar) StartOfDay(t time.Time) time.Time {
+	y, m, d := t.In(c.loc).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, c.loc)
+}
+
+// DayBounds parses a YYYY-MM-DD local date and returns [start, end) of that day.
+func (c *Calendar) DayBounds(day string) (start, end time.Time, err error) {
+	t, err := time.ParseInLocation(dayLayout, day, c.loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	start = c.StartOfDay(t)
+	return start, start.AddDate(0, 0, 1), nil
+}
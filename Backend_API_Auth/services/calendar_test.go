@@ -0,0 +1,71 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"traffic-prediction-api/config"
+)
+
+func newParisCalendar(t *testing.T) *Calendar {
+	t.Helper()
+	cal, err := NewCalendar(config.CityConfig{Timezone: "Europe/Paris"})
+	if err != nil {
+		t.Fatalf("NewCalendar: %v", err)
+	}
+	return cal
+}
+
+func TestDayBoundsAcrossDST(t *testing.T) {
+	cal := newParisCalendar(t)
+
+	tests := []struct {
+		day       string
+		wantStart time.Time
+		wantLen   time.Duration
+	}{
+		{"2026-03-28", time.Date(2026, 3, 27, 23, 0, 0, 0, time.UTC), 24 * time.Hour},
+		{"2026-03-29", time.Date(2026, 3, 28, 23, 0, 0, 0, time.UTC), 23 * time.Hour}, // clocks go forward
+		{"2026-07-14", time.Date(2026, 7, 13, 22, 0, 0, 0, time.UTC), 24 * time.Hour},
+		{"2026-10-25", time.Date(2026, 10, 24, 22, 0, 0, 0, time.UTC), 25 * time.Hour}, // clocks go back
+	}
+	for _, tt := range tests {
+		t.Run(tt.day, func(t *testing.T) {
+			start, end, err := cal.DayBounds(tt.day)
+			if err != nil {
+				t.Fatalf("DayBounds: %v", err)
+			}
+			if !start.Equal(tt.wantStart) {
+				t.Errorf("start = %v, want %v", start.UTC(), tt.wantStart)
+			}
+			if got := end.Sub(start); got != tt.wantLen {
+				t.Errorf("day length = %v, want %v", got, tt.wantLen)
+			}
+		})
+	}
+}
+
+func TestDayBoundsInvalid(t *testing.T) {
+	cal := newParisCalendar(t)
+	for _, day := range []string{"", "2026-13-01", "29/03/2026"} {
+		if _, _, err := cal.DayBounds(day); err == nil {
+			t.Errorf("DayBounds(%q) should fail", day)
+		}
+	}
+}
+
+func TestStartOfDay(t *testing.T) {
+	cal := newParisCalendar(t)
+
+	// 23:30Z on 28 March is already 29 March in Paris.
+	got := cal.StartOfDay(time.Date(2026, 3, 28, 23, 30, 0, 0, time.UTC))
+	if want := time.Date(2026, 3, 28, 23, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("StartOfDay = %v, want %v", got.UTC(), want)
+	}
+}
+
+func TestNewCalendarInvalidTimezone(t *testing.T) {
+	if _, err := NewCalendar(config.CityConfig{Timezone: "Mars/Olympus"}); err == nil {
+		t.Error("expected error for unknown timezone")
+	}
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"traffic-prediction-api/models"
+)
+
+// MinEvaluatedForPromotion is how many realised predictions a shadow model
+// needs before its accuracy is trusted.
+const MinEvaluatedForPromotion = 200
+
+// comparedParams are the model params that change what MAE measures: errors
+// grow with the horizon, and the lookback window changes which predictions
+// are scored. Models differing on them cannot be ranked by MAE.
+var comparedParams = []string{"horizon", "lookback"}
+
+// predictorDefaults are the predictor's values of the compared params
+// (HORIZON_MIN and LOOKBACK_WINDOW_MIN defaults) for models that do not set
+// them, in modelParam's canonical form.
+var predictorDefaults = map[string]string{"horizon": "30", "lookback": "30m0s"}
+
+// CheckPromotion tells whether challenger may replace champion (nil when the
+// registry has no champion). The challenger must be a shadow model with
+// enough scored predictions, scored on the same horizon and lookback as the
+// champion, and must not be less accurate than it.
+func CheckPromotion(champion *models.PredictionModel, challenger models.PredictionModel) error {
+	if challenger.Status != models.ModelShadow {
+		return fmt.Errorf("model %s is %s, only shadow models can be promoted", challenger.Version, challenger.Status)
+	}
+	if challenger.MAE == nil || challenger.Evaluated < MinEvaluatedForPromotion {
+		return fmt.Errorf("model %s has %d scored predictions, at least %d are required",
+			challenger.Version, challenger.Evaluated, MinEvaluatedForPromotion)
+	}
+	if champion == nil || champion.MAE == nil {
+		return nil
+	}
+	for _, key := range comparedParams {
+		want, err := modelParam(*champion, key)
+		if err != nil {
+			return err
+		}
+		got, err := modelParam(challenger, key)
+		if err != nil {
+			return err
+		}
+		if got != want {
+			return fmt.Errorf("model %s has %s %s but champion %s has %s, their MAE are not comparable",
+				challenger.Version, key, got, champion.Version, want)
+		}
+	}
+	if *challenger.MAE > *champion.MAE {
+		return fmt.Errorf("model %s MAE %.4f is worse than champion %s MAE %.4f",
+			challenger.Version, *challenger.MAE, champion.Version, *champion.MAE)
+	}
+	return nil
+}
+
+// modelParam returns a model's value of key in a canonical form, the
+// predictor default when the model does not override it.
+func modelParam(m models.PredictionModel, key string) (string, error) {
+	var params map[string]json.RawMessage
+	if len(m.Params) > 0 {
+		if err := json.Unmarshal(m.Params, &params); err != nil {
+			return "", fmt.Errorf("model %s has unreadable params: %w", m.Version, err)
+		}
+	}
+	raw, ok := params[key]
+	if !ok {
+		return predictorDefaults[key], nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		if d, err := time.ParseDuration(s); err == nil {
+			return d.String(), nil
+		}
+		return s, nil
+	}
+	var n float64
+	if err := json.Unmarshal(raw, &n); err != nil {
+		return "", fmt.Errorf("model %s param %q is neither a string nor a number", m.Version, key)
+	}
+	return fmt.Sprint(n), nil
+}
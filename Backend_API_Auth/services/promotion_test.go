@@ -0,0 +1,50 @@
+package services
+
+import (
+	"encoding/json"
+	"testing"
+
+	"traffic-prediction-api/models"
+)
+
+func scored(version, status string, mae float64, evaluated int) models.PredictionModel {
+	return models.PredictionModel{Version: version, Status: status, MAE: &mae, Evaluated: evaluated}
+}
+
+func withParams(m models.PredictionModel, params string) models.PredictionModel {
+	m.Params = json.RawMessage(params)
+	return m
+}
+
+func TestCheckPromotion(t *testing.T) {
+	champion := scored("ewma-lr-v2", models.ModelChampion, 0.08, 1000)
+	tunedChampion := withParams(scored("ewma-h15", models.ModelChampion, 0.08, 1000), `{"horizon": 15, "lookback": "60m"}`)
+
+	tests := []struct {
+		name       string
+		champion   *models.PredictionModel
+		challenger models.PredictionModel
+		wantErr    bool
+	}{
+		{"more accurate shadow", &champion, scored("tuned", models.ModelShadow, 0.06, 500), false},
+		{"equally accurate shadow", &champion, scored("tuned", models.ModelShadow, 0.08, 500), false},
+		{"less accurate shadow", &champion, scored("tuned", models.ModelShadow, 0.09, 500), true},
+		{"too few scored predictions", &champion, scored("tuned", models.ModelShadow, 0.01, 50), true},
+		{"never scored", &champion, models.PredictionModel{Version: "new", Status: models.ModelShadow}, true},
+		{"retired model", &champion, scored("old", models.ModelRetired, 0.01, 500), true},
+		{"no champion", nil, scored("tuned", models.ModelShadow, 0.2, 500), false},
+		{"unscored champion", &models.PredictionModel{Version: "ewma-lr-v2", Status: models.ModelChampion}, scored("tuned", models.ModelShadow, 0.2, 500), false},
+		{"other horizon", &champion, withParams(scored("h15", models.ModelShadow, 0.03, 500), `{"horizon": 15}`), true},
+		{"other lookback", &champion, withParams(scored("lb45", models.ModelShadow, 0.03, 500), `{"lookback": "45m"}`), true},
+		{"defaults set explicitly", &champion, withParams(scored("explicit", models.ModelShadow, 0.05, 500), `{"horizon": 30, "lookback": "30m"}`), false},
+		{"same overrides", &tunedChampion, withParams(scored("tuned", models.ModelShadow, 0.05, 500), `{"alpha": 0.5, "lookback": "1h0m0s", "horizon": 15}`), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckPromotion(tt.champion, tt.challenger)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CheckPromotion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,2 @@
+ALTER TABLE predictions ADD COLUMN IF NOT EXISTS congestion_p10 DOUBLE PRECISION;
+ALTER TABLE predictions ADD COLUMN IF NOT EXISTS congestion_p90 DOUBLE PRECISION;
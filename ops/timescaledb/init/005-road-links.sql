@@ -0,0 +1,11 @@
+-- Directed road topology: traffic on from_road_id flows into to_road_id.
+CREATE TABLE IF NOT EXISTS road_links (
+    from_road_id TEXT NOT NULL,
+    to_road_id   TEXT NOT NULL,
+    length_m     DOUBLE PRECISION,
+    updated_at   TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (from_road_id, to_road_id),
+    CHECK (from_road_id <> to_road_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_road_links_to ON road_links (to_road_id);
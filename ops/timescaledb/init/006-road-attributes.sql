@@ -0,0 +1,24 @@
+-- Per-class defaults and congestion score weights.
+CREATE TABLE IF NOT EXISTS road_classes (
+    road_class            TEXT PRIMARY KEY,
+    free_flow_speed_kmh   DOUBLE PRECISION NOT NULL,
+    capacity_per_lane_vph DOUBLE PRECISION NOT NULL,
+    default_lanes         INT              NOT NULL DEFAULT 1,
+    speed_weight          DOUBLE PRECISION NOT NULL DEFAULT 0.4,
+    occupancy_weight      DOUBLE PRECISION NOT NULL DEFAULT 0.4,
+    flow_weight           DOUBLE PRECISION NOT NULL DEFAULT 0.2
+);
+
+INSERT INTO road_classes (road_class, free_flow_speed_kmh, capacity_per_lane_vph, default_lanes, speed_weight, occupancy_weight, flow_weight)
+VALUES
+    ('local',    30, 600,  1, 0.5, 0.4, 0.1),
+    ('urban',    50, 900,  2, 0.5, 0.35, 0.15),
+    ('arterial', 70, 1200, 2, 0.4, 0.4, 0.2),
+    ('highway',  90, 1800, 3, 0.4, 0.4, 0.2)
+ON CONFLICT (road_class) DO NOTHING;
+
+-- Per-road attributes; NULLs fall back to the road class defaults.
+ALTER TABLE roads ADD COLUMN IF NOT EXISTS road_class TEXT NOT NULL DEFAULT 'urban';
+ALTER TABLE roads ADD COLUMN IF NOT EXISTS free_flow_speed_kmh DOUBLE PRECISION;
+ALTER TABLE roads ADD COLUMN IF NOT EXISTS capacity_vph DOUBLE PRECISION;
+ALTER TABLE roads ADD COLUMN IF NOT EXISTS lanes INT;
@@ -0,0 +1,45 @@
+-- Per-road traffic aggregates maintained incrementally by TimescaleDB.
+-- Real-time aggregation (materialized_only = false) merges raw rows newer than
+-- the last refresh, so the current bucket is always up to date.
+
+CREATE MATERIALIZED VIEW IF NOT EXISTS traffic_5m
+WITH (timescaledb.continuous, timescaledb.materialized_only = false) AS
+SELECT
+  time_bucket('5 minutes', ts) AS bucket,
+  road_id,
+  AVG(speed_kmh) AS avg_speed,
+  AVG(occupancy) AS avg_occ,
+  AVG(flow_rate) AS avg_flow,
+  COUNT(*)       AS samples
+FROM traffic_raw
+GROUP BY bucket, road_id
+WITH NO DATA;
+
+CREATE INDEX IF NOT EXISTS idx_traffic_5m_road_bucket ON traffic_5m (road_id, bucket DESC);
+
+SELECT add_continuous_aggregate_policy('traffic_5m',
+  start_offset      => INTERVAL '1 day',
+  end_offset        => INTERVAL '5 minutes',
+  schedule_interval => INTERVAL '1 minute',
+  if_not_exists     => TRUE);
+
+CREATE MATERIALIZED VIEW IF NOT EXISTS traffic_1h
+WITH (timescaledb.continuous, timescaledb.materialized_only = false) AS
+SELECT
+  time_bucket('1 hour', ts) AS bucket,
+  road_id,
+  AVG(speed_kmh) AS avg_speed,
+  AVG(occupancy) AS avg_occ,
+  AVG(flow_rate) AS avg_flow,
+  COUNT(*)       AS samples
+FROM traffic_raw
+GROUP BY bucket, road_id
+WITH NO DATA;
+
+CREATE INDEX IF NOT EXISTS idx_traffic_1h_road_bucket ON traffic_1h (road_id, bucket DESC);
+
+SELECT add_continuous_aggregate_policy('traffic_1h',
+  start_offset      => INTERVAL '3 days',
+  end_offset        => INTERVAL '1 hour',
+  schedule_interval => INTERVAL '30 minutes',
+  if_not_exists     => TRUE);
@@ -0,0 +1,3 @@
+-- Intermediate values behind each prediction (subscores, slope, EWMA blend,
+-- spatial pressure, rush-hour factor, samples), written by the predictor.
+ALTER TABLE predictions ADD COLUMN IF NOT EXISTS components JSONB;
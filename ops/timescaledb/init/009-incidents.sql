@@ -0,0 +1,19 @@
+-- Incidents detected by the predictor: abrupt speed collapses or occupancy
+-- spikes on a road that its neighbours do not share. ended_at is NULL while
+-- the incident is open; at most one incident per road is open at a time.
+CREATE TABLE IF NOT EXISTS incidents (
+    id                 BIGSERIAL PRIMARY KEY,
+    road_id            TEXT        NOT NULL,
+    kind               TEXT        NOT NULL CHECK (kind IN ('speed_drop', 'occupancy_spike')),
+    severity           TEXT        NOT NULL CHECK (severity IN ('minor', 'moderate', 'major')),
+    started_at         TIMESTAMPTZ NOT NULL,
+    ended_at           TIMESTAMPTZ,
+    speed_kmh          DOUBLE PRECISION,
+    baseline_speed_kmh DOUBLE PRECISION,
+    occupancy          DOUBLE PRECISION,
+    baseline_occupancy DOUBLE PRECISION,
+    updated_at         TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_incidents_open_road ON incidents (road_id) WHERE ended_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_incidents_started ON incidents (started_at DESC);
@@ -0,0 +1,17 @@
+-- Weather per zone, written by the predictor's weather ingestion (CSV import
+-- or HTTP provider). Temperature and visibility are optional.
+CREATE TABLE IF NOT EXISTS weather_observations (
+    ts                 TIMESTAMPTZ NOT NULL,
+    zone_id            TEXT        NOT NULL,
+    precipitation_mm_h DOUBLE PRECISION NOT NULL DEFAULT 0,
+    temperature_c      DOUBLE PRECISION,
+    visibility_m       DOUBLE PRECISION,
+    source             TEXT        NOT NULL,
+    PRIMARY KEY (ts, zone_id)
+);
+
+SELECT create_hypertable('weather_observations', 'ts', if_not_exists => TRUE);
+CREATE INDEX IF NOT EXISTS idx_weather_zone_ts ON weather_observations (zone_id, ts DESC);
+
+-- Weather zone each road belongs to.
+ALTER TABLE roads ADD COLUMN IF NOT EXISTS weather_zone TEXT NOT NULL DEFAULT 'default';
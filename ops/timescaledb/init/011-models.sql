@@ -0,0 +1,40 @@
+-- Model registry. The champion's predictions go to `predictions`; shadow
+-- models run alongside it and write to `shadow_predictions` only. params holds
+-- overrides of the predictor defaults, with the backtest model spec keys
+-- (alpha, max_speed, lookback, ...). mae/evaluated are refreshed by the
+-- predictor from predictions whose target bucket has been observed.
+CREATE TABLE IF NOT EXISTS models (
+    version      TEXT        PRIMARY KEY,
+    status       TEXT        NOT NULL CHECK (status IN ('shadow', 'champion', 'retired')),
+    params       JSONB       NOT NULL DEFAULT '{}',
+    description  TEXT,
+    mae          DOUBLE PRECISION,
+    evaluated    INT         NOT NULL DEFAULT 0,
+    evaluated_at TIMESTAMPTZ,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    promoted_at  TIMESTAMPTZ,
+    retired_at   TIMESTAMPTZ,
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_models_single_champion ON models (status) WHERE status = 'champion';
+
+INSERT INTO models (version, status, promoted_at)
+VALUES ('ewma-lr-v2', 'champion', NOW())
+ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS shadow_predictions (
+    ts               TIMESTAMPTZ NOT NULL,
+    road_id          TEXT        NOT NULL,
+    horizon_min      INT         NOT NULL,
+    model_version    TEXT        NOT NULL,
+    congestion_score DOUBLE PRECISION NOT NULL,
+    congestion_p10   DOUBLE PRECISION,
+    congestion_p90   DOUBLE PRECISION,
+    confidence       DOUBLE PRECISION,
+    components       JSONB,
+    PRIMARY KEY (ts, road_id, horizon_min, model_version)
+);
+
+SELECT create_hypertable('shadow_predictions', 'ts', if_not_exists => TRUE);
+CREATE INDEX IF NOT EXISTS idx_shadow_predictions_model_ts ON shadow_predictions (model_version, ts DESC);
@@ -0,0 +1,18 @@
+-- Turn restrictions on road_links: a non-NULL restriction forbids the
+-- movement from from_road_id into to_road_id (OSM restriction values).
+ALTER TABLE road_links ADD COLUMN IF NOT EXISTS restriction TEXT
+    CHECK (restriction IN ('no_left_turn', 'no_right_turn', 'no_straight_on', 'no_u_turn', 'no_entry'));
+
+-- Services that cache the graph (rerouter) LISTEN on road_links_changed and
+-- reload it after any edit.
+CREATE OR REPLACE FUNCTION notify_road_links_changed() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('road_links_changed', '');
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS road_links_changed ON road_links;
+CREATE TRIGGER road_links_changed
+    AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON road_links
+    FOR EACH STATEMENT EXECUTE FUNCTION notify_road_links_changed();
@@ -0,0 +1,10 @@
+-- Links proposed by `rerouter derive-links` from road coordinates start as
+-- candidates; only approved links are used by the rerouter and predictor.
+-- Rejected links are kept so that they are not proposed again.
+ALTER TABLE road_links ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'approved'
+    CHECK (status IN ('candidate', 'approved', 'rejected'));
+ALTER TABLE road_links ADD COLUMN IF NOT EXISTS source TEXT NOT NULL DEFAULT 'manual';
+-- Confidence of a derived link in [0, 1]: 1 for touching roads, 0 at the distance cutoff.
+ALTER TABLE road_links ADD COLUMN IF NOT EXISTS score DOUBLE PRECISION;
+
+CREATE INDEX IF NOT EXISTS idx_road_links_status ON road_links (status);
@@ -0,0 +1,25 @@
+-- Road network imported by `rerouter import-network` from an OpenStreetMap
+-- extract or GeoJSON LineStrings. Ways are split at junctions into segments;
+-- road_id is the segment's node in road_links: the sensor road mapped onto it,
+-- or segment_id when no sensor is close enough.
+CREATE TABLE IF NOT EXISTS road_segments (
+    segment_id        TEXT PRIMARY KEY,
+    road_id           TEXT NOT NULL UNIQUE,
+    way_id            TEXT NOT NULL,
+    name              TEXT,
+    highway           TEXT NOT NULL,
+    road_class        TEXT NOT NULL,
+    length_m          DOUBLE PRECISION NOT NULL,
+    speed_limit_kmh   DOUBLE PRECISION NOT NULL,
+    lanes             INT,
+    -- 1: one way along the geometry, -1: against it, 0: both ways.
+    oneway            SMALLINT NOT NULL DEFAULT 0 CHECK (oneway IN (-1, 0, 1)),
+    -- GeoJSON LineString coordinates: [[lng, lat], ...].
+    geometry          JSONB NOT NULL,
+    -- Distance from the mapped sensor to the segment, NULL when unmapped.
+    sensor_distance_m DOUBLE PRECISION,
+    source            TEXT NOT NULL,
+    imported_at       TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_road_segments_way ON road_segments (way_id);
@@ -0,0 +1,4 @@
+-- Multi-hop reroutes: alt_path is the whole detour as a JSON array of road
+-- ids, from the road before the congested one to the road after it.
+-- alt_route_id stays the first road of the detour.
+ALTER TABLE reroutes ADD COLUMN IF NOT EXISTS alt_path JSONB;
@@ -0,0 +1,3 @@
+-- Capacity-aware reroutes: diverted_vph is the volume, in vehicles per hour,
+-- the rerouter assigned to the detour within the headroom of its roads.
+ALTER TABLE reroutes ADD COLUMN IF NOT EXISTS diverted_vph DOUBLE PRECISION;
@@ -0,0 +1,9 @@
+-- Stateful reroutes: the rerouter now writes a row only on a lifecycle event
+-- of a road's recommendation (activated, updated with a new detour, cleared).
+-- active_since is when the recommendation was activated. The latest event of
+-- each road gives its current state; rows written before have no event.
+ALTER TABLE reroutes ADD COLUMN IF NOT EXISTS event TEXT
+    CHECK (event IN ('activated', 'updated', 'cleared'));
+ALTER TABLE reroutes ADD COLUMN IF NOT EXISTS active_since TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_reroutes_events ON reroutes (route_id, ts DESC) WHERE event IS NOT NULL;
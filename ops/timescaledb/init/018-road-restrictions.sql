@@ -0,0 +1,26 @@
+-- Temporary restrictions entered by admins: roadworks, closures, events.
+-- A restriction applies from starts_at until ends_at (open-ended when NULL).
+--   closed         the road cannot be entered
+--   reduced_lanes  only lanes_open lanes remain
+--   hgv_banned     heavy goods vehicles may not enter
+-- The rerouter keeps closed roads out of detours and routes, penalises and
+-- reduces the capacity of roads with fewer lanes, and keeps heavy vehicles off
+-- banned roads; the predictor scales the capacity of roads with fewer lanes
+-- and predicts closed roads fully congested.
+CREATE TABLE IF NOT EXISTS road_restrictions (
+    id         BIGSERIAL PRIMARY KEY,
+    road_id    TEXT        NOT NULL,
+    kind       TEXT        NOT NULL CHECK (kind IN ('closed', 'reduced_lanes', 'hgv_banned')),
+    lanes_open INT         CHECK (lanes_open > 0),
+    starts_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    ends_at    TIMESTAMPTZ,
+    reason     TEXT,
+    created_by TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (ends_at IS NULL OR ends_at > starts_at),
+    CHECK ((kind = 'reduced_lanes') = (lanes_open IS NOT NULL))
+);
+
+CREATE INDEX IF NOT EXISTS idx_road_restrictions_road ON road_restrictions (road_id, starts_at);
+CREATE INDEX IF NOT EXISTS idx_road_restrictions_ends ON road_restrictions (ends_at);
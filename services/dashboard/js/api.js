@@ -90,6 +90,8 @@ export async function getReroutes(options = {}) {
     if (options.limit) p.set('limit', options.limit);
     if (options.before) p.set('before', options.before);
     if (options.route_id) p.set('route_id', options.route_id);
+    if (options.event) p.set('event', options.event);
+    if (options.active) p.set('active', 'true');
     const qs = p.toString();
     return request('GET', `/api/reroutes/recommended${qs ? '?' + qs : ''}`);
 }
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseModelSpec(t *testing.T) {
+	p, err := parseModelSpec("tuned:alpha=0.5,max_speed=50,lookback=45m,horizon=15", defaultModelParams())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.Version != "tuned" || p.EWMAAlpha != 0.5 || p.MaxSpeed != 50 || p.Lookback != 45*time.Minute || p.HorizonMin != 15 {
+		t.Errorf("unexpected params: %+v", p)
+	}
+	if p.MaxFlow != maxFlow {
+		t.Errorf("MaxFlow = %v, want default %v", p.MaxFlow, maxFlow)
+	}
+
+	if p, err := parseModelSpec("baseline", defaultModelParams()); err != nil || p.EWMAAlpha != ewmaAlpha {
+		t.Errorf("version-only spec: got %+v, %v", p, err)
+	}
+
+	for _, bad := range []string{"", ":alpha=0.5", "v:alpha", "v:alpha=x", "v:unknown=1", "v:max_speed=0", "v:max_flow=-5"} {
+		if _, err := parseModelSpec(bad, defaultModelParams()); err == nil {
+			t.Errorf("parseModelSpec(%q) should fail", bad)
+		}
+	}
+}
+
+// syntheticSeries builds one road with a bucket every 5 minutes over [from, to).
+func syntheticSeries(from, to time.Time, speed func(i int) float64) map[string][]bucketData {
+	var buckets []bucketData
+	i := 0
+	for ts := from; ts.Before(to); ts = ts.Add(bucketWidth) {
+		buckets = append(buckets, bucketData{ts: ts, avgSpeed: speed(i), avgOcc: 0.2, avgFlow: 40, samples: 10})
+		i++
+	}
+	return map[string][]bucketData{"PARIS-1": buckets}
+}
+
+func TestSliceWindow(t *testing.T) {
+	from := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	series := syntheticSeries(from, from.Add(time.Hour), func(int) float64 { return 50 })
+
+	start := from.Add(10 * time.Minute)
+	window := sliceWindow(series, start, start.Add(30*time.Minute))
+	buckets := window["PARIS-1"]
+	if len(buckets) != 6 {
+		t.Fatalf("got %d buckets, want 6", len(buckets))
+	}
+	if buckets[0].offsetMin != 0 || buckets[5].offsetMin != 25 {
+		t.Errorf("offsets = %v..%v, want 0..25", buckets[0].offsetMin, buckets[5].offsetMin)
+	}
+	if series["PARIS-1"][2].offsetMin != 0 {
+		t.Error("sliceWindow must not mutate the input series")
+	}
+}
+
+func TestBacktestSteadyTraffic(t *testing.T) {
+	from := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	to := from.Add(time.Hour)
+	series := syntheticSeries(from.Add(-30*time.Minute), to.Add(time.Hour), func(int) float64 { return 50 })
+
+	models := []modelParams{defaultModelParams()}
+	reports := backtest(series, nil, nil, cycleInput{}, from, to, bucketWidth, models, 0.5)
+	if len(reports) != 1 {
+		t.Fatalf("got %d reports, want 1", len(reports))
+	}
+	r := reports[0]
+	if r.Cycles != 12 || r.Predictions != 12 || r.Evaluated != 12 {
+		t.Errorf("cycles=%d predictions=%d evaluated=%d, want 12 each", r.Cycles, r.Predictions, r.Evaluated)
+	}
+	// Flat traffic at noon: the model should reproduce the observed score.
+	if r.MAE > 0.001 || r.Accuracy != 1 {
+		t.Errorf("MAE=%v accuracy=%v, want ~0 and 1", r.MAE, r.Accuracy)
+	}
+}
+
+func TestBacktestComparesVariants(t *testing.T) {
+	from := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	to := from.Add(time.Hour)
+	// Speed oscillates, so the trend extrapolation matters.
+	series := syntheticSeries(from.Add(-time.Hour), to.Add(time.Hour), func(i int) float64 {
+		if i%4 < 2 {
+			return 20
+		}
+		return 70
+	})
+
+	base := defaultModelParams()
+	damped := base
+	damped.Version = "damped"
+	damped.EWMAAlpha = 0.0
+
+	reports := backtest(series, nil, nil, cycleInput{}, from, to, bucketWidth, []modelParams{base, damped}, 0.5)
+	if len(reports) != 2 || reports[1].ModelVersion != "damped" {
+		t.Fatalf("unexpected reports: %+v", reports)
+	}
+	if reports[0].MAE == reports[1].MAE {
+		t.Errorf("variants should score differently, both MAE=%v", reports[0].MAE)
+	}
+}
+
+func TestBacktestLimitsScaleRoadProfiles(t *testing.T) {
+	from := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	to := from.Add(time.Hour)
+	series := syntheticSeries(from.Add(-30*time.Minute), to.Add(time.Hour), func(int) float64 { return 40 })
+	// PARIS-1 has a class profile, as every road in the roads table does.
+	static := cycleInput{Profiles: map[string]roadProfile{"PARIS-1": urbanProfile}}
+
+	base := defaultModelParams()
+	variants := []modelParams{base}
+	for _, spec := range []string{"slow:max_speed=45", "narrow:max_flow=60"} {
+		p, err := parseModelSpec(spec, base)
+		if err != nil {
+			t.Fatal(err)
+		}
+		variants = append(variants, p)
+	}
+
+	reports := backtest(series, nil, nil, static, from, to, bucketWidth, variants, 0.5)
+	if len(reports) != 3 {
+		t.Fatalf("unexpected reports: %+v", reports)
+	}
+	// The default limits reproduce the actuals; halving either limit moves
+	// the predictions away from them.
+	if reports[0].MAE > 0.001 {
+		t.Errorf("default MAE = %v, want ~0", reports[0].MAE)
+	}
+	for _, r := range reports[1:] {
+		if r.MAE <= 0.001 {
+			t.Errorf("%s: MAE=%v, want the limit to change predictions", r.ModelVersion, r.MAE)
+		}
+	}
+}
+
+func TestBacktestAppliesRestrictions(t *testing.T) {
+	from := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	to := from.Add(time.Hour)
+	series := syntheticSeries(from.Add(-30*time.Minute), to.Add(time.Hour), func(int) float64 { return 40 })
+	static := cycleInput{Profiles: map[string]roadProfile{"PARIS-1": urbanProfile}}
+	models := []modelParams{defaultModelParams()}
+
+	// Closed over the second half of the replay only: those cycles predict
+	// the closure while the actuals, from the sensors, stay clear.
+	closure := []capacityWindow{{RoadID: "PARIS-1", Factor: 0, Start: from.Add(30 * time.Minute)}}
+	closed := backtest(series, nil, closure, static, from, to, bucketWidth, models, 0.5)[0]
+	if closed.MAE <= 0.001 || closed.Accuracy >= 1 {
+		t.Errorf("MAE=%v accuracy=%v, want the closure to change predictions", closed.MAE, closed.Accuracy)
+	}
+	if static.Profiles["PARIS-1"].Closed {
+		t.Error("backtest modified the static profiles")
+	}
+
+	// A closure lifted before the replay starts changes nothing.
+	lifted := []capacityWindow{{RoadID: "PARIS-1", Factor: 0, Start: from.Add(-2 * time.Hour), End: from}}
+	if r := backtest(series, nil, lifted, static, from, to, bucketWidth, models, 0.5)[0]; r.MAE > 0.001 {
+		t.Errorf("MAE=%v with a lifted closure, want ~0", r.MAE)
+	}
+}
+
+func TestWriteBacktestReports(t *testing.T) {
+	reports := []backtestReport{{ModelVersion: "ewma-lr-v2", EWMAAlpha: 0.7, HorizonMin: 30, Evaluated: 3, MAE: 0.05}}
+
+	var buf bytes.Buffer
+	if err := writeBacktestJSON(&buf, reports); err != nil {
+		t.Fatal(err)
+	}
+	var decoded []backtestReport
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil || len(decoded) != 1 || decoded[0].MAE != 0.05 {
+		t.Errorf("JSON round-trip failed: %v %+v", err, decoded)
+	}
+
+	buf.Reset()
+	if err := writeBacktestCSV(&buf, reports); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "model_version,") || !strings.HasPrefix(lines[1], "ewma-lr-v2,0.7,") {
+		t.Errorf("unexpected CSV:\n%s", buf.String())
+	}
+}
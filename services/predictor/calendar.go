@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"time"
+	_ "time/tzdata" // the alpine runtime image ships no zoneinfo
+)
+
+const defaultCityTZ = "Europe/Paris"
+
+// cityLocation returns the city timezone from CITY_TZ. Calendar features such
+// as rush hours follow local wall-clock time, so they shift with DST.
+func cityLocation() (*time.Location, error) {
+	name := getEnv("CITY_TZ", defaultCityTZ)
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CITY_TZ %q: %w", name, err)
+	}
+	return loc, nil
+}
+
+// localHour is the wall-clock hour of t in the model's city timezone.
+func (p modelParams) localHour(t time.Time) int {
+	if p.Location == nil {
+		return t.UTC().Hour()
+	}
+	return t.In(p.Location).Hour()
+}
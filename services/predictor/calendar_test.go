@@ -0,0 +1,84 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRushHourFollowsCityDST(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := defaultModelParams()
+	params.Location = paris
+
+	tests := []struct {
+		name string
+		now  time.Time
+		hour int
+		rush float64
+	}{
+		// Winter time (UTC+1), the day before clocks go forward on 2026-03-29.
+		{"winter 05:30Z", time.Date(2026, 3, 28, 5, 30, 0, 0, time.UTC), 6, 1.0},
+		{"winter 06:30Z", time.Date(2026, 3, 28, 6, 30, 0, 0, time.UTC), 7, 1.15},
+		{"winter 16:30Z", time.Date(2026, 3, 28, 16, 30, 0, 0, time.UTC), 17, 1.15},
+		// Summer time (UTC+2): the same UTC instants land an hour later.
+		{"summer 05:30Z", time.Date(2026, 3, 29, 5, 30, 0, 0, time.UTC), 7, 1.15},
+		{"summer 16:30Z", time.Date(2026, 3, 29, 16, 30, 0, 0, time.UTC), 18, 1.15},
+		{"summer 17:30Z", time.Date(2026, 3, 29, 17, 30, 0, 0, time.UTC), 19, 1.0},
+		// Clocks go back at 03:00 local on 2026-10-25: 00:30Z and 01:30Z are both 02:30 local.
+		{"fall back first 02:30", time.Date(2026, 10, 25, 0, 30, 0, 0, time.UTC), 2, 0.85},
+		{"fall back second 02:30", time.Date(2026, 10, 25, 1, 30, 0, 0, time.UTC), 2, 0.85},
+		{"after fall back 06:30Z", time.Date(2026, 10, 25, 6, 30, 0, 0, time.UTC), 7, 1.15},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hour := params.localHour(tt.now)
+			if hour != tt.hour {
+				t.Errorf("localHour = %d, want %d", hour, tt.hour)
+			}
+			if got := rushHourFactor(hour); got != tt.rush {
+				t.Errorf("rushHourFactor = %v, want %v", got, tt.rush)
+			}
+		})
+	}
+}
+
+func TestLocalHourDefaultsToUTC(t *testing.T) {
+	now := time.Date(2026, 7, 1, 8, 0, 0, 0, time.FixedZone("X", 3*3600))
+	if got := defaultModelParams().localHour(now); got != 5 {
+		t.Errorf("localHour = %d, want 5 (UTC)", got)
+	}
+}
+
+func TestCityLocation(t *testing.T) {
+	t.Setenv("CITY_TZ", "")
+	loc, err := cityLocation()
+	if err != nil || loc.String() != defaultCityTZ {
+		t.Errorf("default: got %v, %v", loc, err)
+	}
+
+	t.Setenv("CITY_TZ", "America/New_York")
+	if loc, err := cityLocation(); err != nil || loc.String() != "America/New_York" {
+		t.Errorf("override: got %v, %v", loc, err)
+	}
+
+	t.Setenv("CITY_TZ", "Mars/Olympus")
+	if _, err := cityLocation(); err == nil {
+		t.Error("expected error for unknown timezone")
+	}
+}
+
+func TestPredictRoadsUsesCityTime(t *testing.T) {
+	paris, _ := time.LoadLocation("Europe/Paris")
+	params := defaultModelParams()
+	params.Location = paris
+	// 05:30Z is 07:30 in Paris on a summer day: rush hour, not early morning.
+	in := cycleInput{Now: time.Date(2026, 6, 15, 5, 30, 0, 0, time.UTC), Buckets: map[string][]bucketData{"A": flatBuckets(30, 0.5)}}
+
+	preds := predictRoads(in, params)
+	if len(preds) != 1 || preds[0].Components.RushHourFactor != 1.15 {
+		t.Errorf("unexpected predictions: %+v", preds)
+	}
+}
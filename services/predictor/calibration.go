@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// scoreWeights are the weights of the speed, occupancy and flow subscores.
+type scoreWeights struct {
+	Speed     float64 `json:"speed"`
+	Occupancy float64 `json:"occupancy"`
+	Flow      float64 `json:"flow"`
+}
+
+var defaultWeights = scoreWeights{Speed: 0.4, Occupancy: 0.4, Flow: 0.2}
+
+// roadProfile calibrates the congestion score of one road: speed is measured
+// against its own free-flow speed and flow against its own capacity. Closed
+// roads, with no capacity left, are predicted fully congested.
+type roadProfile struct {
+	Class         string
+	FreeFlowSpeed float64 // km/h
+	Capacity      float64 // vehicles/h
+	Weights       scoreWeights
+	Closed        bool
+}
+
+func (r roadProfile) score(avgSpeed, avgOccupancy, avgFlow float64) float64 {
+	return r.subscores(avgSpeed, avgOccupancy, avgFlow).weighted(r.Weights)
+}
+
+func (r roadProfile) subscores(avgSpeed, avgOccupancy, avgFlow float64) subscores {
+	return newSubscores(avgSpeed, avgOccupancy, avgFlow, r.FreeFlowSpeed, r.Capacity)
+}
+
+// fallbackProfile scores roads missing from the roads table with the global limits.
+func (p modelParams) fallbackProfile() roadProfile {
+	return roadProfile{FreeFlowSpeed: p.MaxSpeed, Capacity: p.MaxFlow, Weights: defaultWeights}
+}
+
+// profile returns the calibration for roadID, or the model's global fallback.
+// MaxSpeed and MaxFlow also scale the free-flow speed and capacity of every
+// road profile relative to their defaults, so that a model can tune them for
+// calibrated roads too.
+func (in cycleInput) profile(roadID string, params modelParams) roadProfile {
+	if prof, ok := in.Profiles[roadID]; ok {
+		prof.FreeFlowSpeed *= params.MaxSpeed / maxSpeed
+		prof.Capacity *= params.MaxFlow / maxFlow
+		return prof
+	}
+	return params.fallbackProfile()
+}
+
+// actualScore scores an observed bucket of roadID. Observations are always
+// scored with the default limits, so that models with other MaxSpeed and
+// MaxFlow are judged against the same actuals.
+func (in cycleInput) actualScore(roadID string, avgSpeed, avgOccupancy, avgFlow float64) float64 {
+	return in.profile(roadID, defaultModelParams()).score(avgSpeed, avgOccupancy, avgFlow)
+}
+
+// subscores are the unweighted speed, occupancy and flow terms of a score.
+type subscores struct {
+	Speed     float64 `json:"speed"`
+	Occupancy float64 `json:"occupancy"`
+	Flow      float64 `json:"flow"`
+}
+
+// newSubscores normalises raw metrics; speeds above free-flow count as zero
+// congestion rather than negative, and a road without capacity is saturated.
+func newSubscores(avgSpeed, avgOccupancy, avgFlow, freeFlowSpeed, capacity float64) subscores {
+	flow := 1.0
+	if capacity > 0 {
+		flow = avgFlow / capacity
+	}
+	return subscores{
+		Speed:     math.Max(0.0, 1.0-(avgSpeed/freeFlowSpeed)),
+		Occupancy: avgOccupancy,
+		Flow:      flow,
+	}
+}
+
+func (s subscores) weighted(w scoreWeights) float64 {
+	score := w.Speed*s.Speed + w.Occupancy*s.Occupancy + w.Flow*s.Flow
+	return math.Max(0.0, math.Min(1.0, score))
+}
+
+// weightedScore combines the subscores of raw metrics into a score in [0, 1].
+func weightedScore(avgSpeed, avgOccupancy, avgFlow, freeFlowSpeed, capacity float64, w scoreWeights) float64 {
+	return newSubscores(avgSpeed, avgOccupancy, avgFlow, freeFlowSpeed, capacity).weighted(w)
+}
+
+// loadRoadProfiles reads per-road attributes, filling gaps from the road class
+// defaults. Roads whose class is unknown are left out and use the fallback.
+func loadRoadProfiles(ctx context.Context, dbPool *pgxpool.Pool) (map[string]roadProfile, error) {
+	rows, err := dbPool.Query(ctx, `
+		SELECT r.road_id, r.road_class,
+			COALESCE(r.free_flow_speed_kmh, c.free_flow_speed_kmh),
+			COALESCE(r.capacity_vph, c.capacity_per_lane_vph * COALESCE(r.lanes, c.default_lanes)),
+			c.speed_weight, c.occupancy_weight, c.flow_weight
+		FROM roads r
+		JOIN road_classes c ON c.road_class = r.road_class
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	profiles := make(map[string]roadProfile)
+	for rows.Next() {
+		var roadID string
+		var prof roadProfile
+		if err := rows.Scan(&roadID, &prof.Class, &prof.FreeFlowSpeed, &prof.Capacity,
+			&prof.Weights.Speed, &prof.Weights.Occupancy, &prof.Weights.Flow); err != nil {
+			return nil, err
+		}
+		if prof.FreeFlowSpeed <= 0 || prof.Capacity <= 0 {
+			continue
+		}
+		profiles[roadID] = prof
+	}
+	return profiles, rows.Err()
+}
+
+// capacityWindow is a closed or reduced_lanes restriction: the share of the
+// road's capacity left (the share of its lanes still open, 0 for closed) from
+// Start until End, or with no end when End is zero.
+type capacityWindow struct {
+	RoadID     string
+	Factor     float64
+	Start, End time.Time
+}
+
+// loadCapacityFactors returns, for each road with a restriction in force at
+// now, the share of its capacity left. Overlapping restrictions keep the
+// tightest.
+func loadCapacityFactors(ctx context.Context, dbPool *pgxpool.Pool, now time.Time) (map[string]float64, error) {
+	windows, err := loadCapacityWindows(ctx, dbPool, now, now)
+	if err != nil {
+		return nil, err
+	}
+	return capacityFactorsAt(windows, now), nil
+}
+
+// loadCapacityWindows returns the restrictions in force at some point of
+// [from, to].
+func loadCapacityWindows(ctx context.Context, dbPool *pgxpool.Pool, from, to time.Time) ([]capacityWindow, error) {
+	rows, err := dbPool.Query(ctx, `
+		SELECT x.road_id, CASE WHEN x.kind = 'closed' THEN 0.0
+			ELSE LEAST(1.0, x.lanes_open::float8 / COALESCE(r.lanes, c.default_lanes, x.lanes_open)) END,
+			x.starts_at, x.ends_at
+		FROM road_restrictions x
+		JOIN roads r ON r.road_id = x.road_id
+		LEFT JOIN road_classes c ON c.road_class = r.road_class
+		WHERE x.kind IN ('closed', 'reduced_lanes') AND x.starts_at <= $2 AND (x.ends_at IS NULL OR x.ends_at > $1)
+	`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var windows []capacityWindow
+	for rows.Next() {
+		var w capacityWindow
+		var end *time.Time
+		if err := rows.Scan(&w.RoadID, &w.Factor, &w.Start, &end); err != nil {
+			return nil, err
+		}
+		if end != nil {
+			w.End = *end
+		}
+		windows = append(windows, w)
+	}
+	return windows, rows.Err()
+}
+
+// capacityFactorsAt returns the tightest factor of each road among the
+// windows in force at now.
+func capacityFactorsAt(windows []capacityWindow, now time.Time) map[string]float64 {
+	factors := make(map[string]float64)
+	for _, w := range windows {
+		if now.Before(w.Start) || (!w.End.IsZero() && !now.Before(w.End)) {
+			continue
+		}
+		if f, ok := factors[w.RoadID]; !ok || w.Factor < f {
+			factors[w.RoadID] = w.Factor
+		}
+	}
+	return factors
+}
+
+// withCapacityFactors returns a copy of profiles with the capacity of each
+// road scaled by its factor, so that the flow it can take while lanes are
+// closed counts as congestion. A factor of 0 marks the road Closed. Roads
+// without a profile keep the fallback.
+func withCapacityFactors(profiles map[string]roadProfile, factors map[string]float64) map[string]roadProfile {
+	if len(factors) == 0 {
+		return profiles
+	}
+	out := make(map[string]roadProfile, len(profiles))
+	for roadID, prof := range profiles {
+		if f, ok := factors[roadID]; ok {
+			prof.Capacity *= math.Max(0, f)
+			prof.Closed = prof.Capacity == 0
+		}
+		out[roadID] = prof
+	}
+	return out
+}
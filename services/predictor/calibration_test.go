@@ -0,0 +1,138 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+var urbanProfile = roadProfile{
+	Class:         "urban",
+	FreeFlowSpeed: 50,
+	Capacity:      1800,
+	Weights:       scoreWeights{Speed: 0.5, Occupancy: 0.35, Flow: 0.15},
+}
+
+func TestRoadProfileScore(t *testing.T) {
+	// A 50 km/h boulevard at 45 km/h with light occupancy is barely congested.
+	got := urbanProfile.score(45, 0.05, 300)
+	want := 0.5*0.1 + 0.35*0.05 + 0.15*(300.0/1800)
+	if math.Abs(got-want) > 1e-9 {
+		t.Errorf("score = %v, want %v", got, want)
+	}
+	if got >= 0.1 {
+		t.Errorf("boulevard near free-flow scored %v, want < 0.1", got)
+	}
+
+	// The same reading against the global 90 km/h limit looks much worse.
+	if global := computeCongestionScore(45, 0.05, 300); global <= got {
+		t.Errorf("global score %v should exceed calibrated %v", global, got)
+	}
+}
+
+func TestRoadProfileScoreAboveFreeFlow(t *testing.T) {
+	// Speeding past free-flow must not offset occupancy.
+	fast := urbanProfile.score(70, 0.4, 0)
+	atLimit := urbanProfile.score(50, 0.4, 0)
+	if fast != atLimit {
+		t.Errorf("score above free-flow = %v, want %v", fast, atLimit)
+	}
+}
+
+func TestCycleInputProfileFallback(t *testing.T) {
+	params := defaultModelParams()
+	in := cycleInput{Profiles: map[string]roadProfile{"PARIS-1": urbanProfile}}
+
+	if got := in.profile("PARIS-1", params); got.Class != "urban" {
+		t.Errorf("profile(PARIS-1) = %+v, want urban", got)
+	}
+	fallback := in.profile("UNKNOWN", params)
+	if fallback.FreeFlowSpeed != maxSpeed || fallback.Capacity != maxFlow || fallback.Weights != defaultWeights {
+		t.Errorf("fallback = %+v, want global limits", fallback)
+	}
+	if got, want := fallback.score(45, 0.5, 60), computeCongestionScore(45, 0.5, 60); got != want {
+		t.Errorf("fallback score = %v, want %v", got, want)
+	}
+}
+
+func TestPredictRoadsUsesProfiles(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	buckets := map[string][]bucketData{"PARIS-1": flatBuckets(45, 0.05)}
+
+	uncalibrated := predictRoads(cycleInput{Now: now, Buckets: buckets}, defaultModelParams())
+	calibrated := predictRoads(cycleInput{
+		Now:      now,
+		Buckets:  buckets,
+		Profiles: map[string]roadProfile{"PARIS-1": urbanProfile},
+	}, defaultModelParams())
+
+	if calibrated[0].CongestionScore >= uncalibrated[0].CongestionScore {
+		t.Errorf("calibrated %v should be below uncalibrated %v", calibrated[0].CongestionScore, uncalibrated[0].CongestionScore)
+	}
+}
+
+func TestWithCapacityFactors(t *testing.T) {
+	profiles := map[string]roadProfile{"QUAI-1": urbanProfile, "QUAI-2": urbanProfile}
+	got := withCapacityFactors(profiles, map[string]float64{"QUAI-1": 0.5, "PONT-6": 0.5})
+
+	if got["QUAI-1"].Capacity != 900 || got["QUAI-2"].Capacity != 1800 {
+		t.Errorf("capacities = %v, %v, want 900, 1800", got["QUAI-1"].Capacity, got["QUAI-2"].Capacity)
+	}
+	if profiles["QUAI-1"].Capacity != 1800 {
+		t.Error("withCapacityFactors modified its input")
+	}
+	if _, ok := got["PONT-6"]; ok {
+		t.Error("a restriction on a road without a profile added one")
+	}
+	// The same flow scores higher with half the lanes closed.
+	if got["QUAI-1"].score(45, 0.05, 600) <= urbanProfile.score(45, 0.05, 600) {
+		t.Error("halving capacity did not raise the score")
+	}
+	if got["QUAI-1"].Closed {
+		t.Error("a lane reduction closed the road")
+	}
+
+	closed := withCapacityFactors(profiles, map[string]float64{"QUAI-2": 0})["QUAI-2"]
+	if !closed.Closed || closed.Capacity != 0 {
+		t.Errorf("closed profile = %+v, want no capacity", closed)
+	}
+	if s := closed.subscores(50, 0, 0); s.Flow != 1 {
+		t.Errorf("flow subscore without capacity = %v, want 1", s.Flow)
+	}
+}
+
+func TestCapacityFactorsAt(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	windows := []capacityWindow{
+		{RoadID: "QUAI-1", Factor: 0.5, Start: now.Add(-time.Hour)},
+		{RoadID: "QUAI-1", Factor: 0, Start: now.Add(-time.Hour), End: now.Add(time.Hour)},
+		{RoadID: "QUAI-2", Factor: 0, Start: now.Add(time.Minute)},
+		{RoadID: "PONT-6", Factor: 0.5, Start: now.Add(-time.Hour), End: now},
+	}
+
+	got := capacityFactorsAt(windows, now)
+	if len(got) != 1 || got["QUAI-1"] != 0 {
+		t.Errorf("factors at now = %v, want only QUAI-1 closed", got)
+	}
+	if got := capacityFactorsAt(windows, now.Add(2*time.Hour)); len(got) != 2 || got["QUAI-1"] != 0.5 || got["QUAI-2"] != 0 {
+		t.Errorf("factors later = %v, want QUAI-1 at 0.5 and QUAI-2 closed", got)
+	}
+}
+
+func TestPredictRoadsClosedRoad(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	// Free-flowing until the closure: the sensors still look clear.
+	buckets := map[string][]bucketData{"QUAI-1": flatBuckets(50, 0.02), "QUAI-2": flatBuckets(50, 0.02)}
+	profiles := withCapacityFactors(map[string]roadProfile{"QUAI-1": urbanProfile, "QUAI-2": urbanProfile},
+		map[string]float64{"QUAI-1": 0})
+
+	for _, p := range predictRoads(cycleInput{Now: now, Buckets: buckets, Profiles: profiles}, defaultModelParams()) {
+		closed := p.RoadID == "QUAI-1"
+		if closed && (p.CongestionScore != 1 || p.CongestionP10 != 1 || !p.Components.Closed) {
+			t.Errorf("closed road = %+v, %+v, want pinned to 1", p, p.Components)
+		}
+		if !closed && (p.CongestionScore >= 0.5 || p.Components.Closed) {
+			t.Errorf("open road = %+v, want free-flowing", p)
+		}
+	}
+}
@@ -0,0 +1,459 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/redis/go-redis/v9"
+)
+
+// Alert kinds published on cityflow:alerts.
+const (
+	alertSpeedShift    = "speed_shift"    // mean speed far from the road's baseline
+	alertSampleRate    = "sample_rate"    // sensor reporting much more or less often
+	alertStale         = "stale"          // no data at all in the recent window
+	alertErrorDegraded = "error_degraded" // prediction error well above baseline
+)
+
+var (
+	driftAlertsFired = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cityflow_predictor_drift_alerts_total",
+		Help: "Total number of drift and data-quality alerts fired, by kind.",
+	}, []string{"kind"})
+	driftAlertsActive = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cityflow_predictor_drift_alerts_active",
+		Help: "Number of roads with an active drift or data-quality alert, by kind.",
+	}, []string{"kind"})
+	predictionMAE = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cityflow_predictor_mae",
+		Help: "Mean absolute error of realised predictions over the recent and baseline windows.",
+	}, []string{"window"})
+)
+
+// driftParams configures the drift checks. Inputs of the recent window are
+// compared with the trailing baseline, which excludes the recent window.
+type driftParams struct {
+	Baseline    time.Duration
+	Recent      time.Duration
+	ErrorWindow time.Duration
+	// Hours of baseline data a road needs before it is checked.
+	MinBaselineHours int
+	// Speed shift threshold in baseline standard deviations, with a floor on
+	// the deviation so very steady roads do not alert on small changes.
+	SpeedZ        float64
+	MinSpeedStdKM float64
+	// Sample rate alerts below 1/SampleRatio or above SampleRatio times baseline.
+	SampleRatio float64
+	// Error alerts when recent MAE exceeds ErrorRatio times the baseline MAE
+	// and the baseline by at least ErrorFloor, over MinErrorSamples points.
+	ErrorRatio      float64
+	ErrorFloor      float64
+	MinErrorSamples int
+}
+
+func defaultDriftParams() driftParams {
+	return driftParams{
+		Baseline:         14 * 24 * time.Hour,
+		Recent:           time.Hour,
+		ErrorWindow:      6 * time.Hour,
+		MinBaselineHours: 72,
+		SpeedZ:           3,
+		MinSpeedStdKM:    2,
+		SampleRatio:      2,
+		ErrorRatio:       1.5,
+		ErrorFloor:       0.05,
+		MinErrorSamples:  4,
+	}
+}
+
+// driftAlert is the payload published on cityflow:alerts. An alert is
+// published once when it starts firing and once when it resolves.
+type driftAlert struct {
+	TS       time.Time `json:"ts"`
+	Source   string    `json:"source"`
+	Kind     string    `json:"kind"`
+	State    string    `json:"state"` // firing | resolved
+	RoadID   string    `json:"road_id,omitempty"`
+	Value    float64   `json:"value"`
+	Baseline float64   `json:"baseline"`
+	Message  string    `json:"message"`
+}
+
+func (a driftAlert) key() string {
+	return a.Kind + "/" + a.RoadID
+}
+
+// inputStats summarises one road's measurements over a window.
+type inputStats struct {
+	MeanSpeed      float64
+	StdSpeed       float64
+	SamplesPerHour float64
+	Hours          int
+}
+
+// detectInputDrift compares each road's recent inputs with its baseline.
+func detectInputDrift(baseline, recent map[string]inputStats, p driftParams, now time.Time) []driftAlert {
+	var alerts []driftAlert
+	for roadID, base := range baseline {
+		if base.Hours < p.MinBaselineHours {
+			continue
+		}
+		cur, ok := recent[roadID]
+		if !ok || cur.SamplesPerHour == 0 {
+			alerts = append(alerts, driftAlert{
+				TS: now, Kind: alertStale, RoadID: roadID, Baseline: round4(base.SamplesPerHour),
+				Message: fmt.Sprintf("no data for %s in the last %s", roadID, p.Recent),
+			})
+			continue
+		}
+
+		std := math.Max(base.StdSpeed, p.MinSpeedStdKM)
+		if z := (cur.MeanSpeed - base.MeanSpeed) / std; math.Abs(z) > p.SpeedZ {
+			alerts = append(alerts, driftAlert{
+				TS: now, Kind: alertSpeedShift, RoadID: roadID,
+				Value: round4(cur.MeanSpeed), Baseline: round4(base.MeanSpeed),
+				Message: fmt.Sprintf("mean speed %.1f km/h is %.1f sd from baseline %.1f km/h", cur.MeanSpeed, z, base.MeanSpeed),
+			})
+		}
+
+		if base.SamplesPerHour > 0 {
+			ratio := cur.SamplesPerHour / base.SamplesPerHour
+			if ratio > p.SampleRatio || ratio < 1/p.SampleRatio {
+				alerts = append(alerts, driftAlert{
+					TS: now, Kind: alertSampleRate, RoadID: roadID,
+					Value: round4(cur.SamplesPerHour), Baseline: round4(base.SamplesPerHour),
+					Message: fmt.Sprintf("%.0f samples/h vs %.0f baseline", cur.SamplesPerHour, base.SamplesPerHour),
+				})
+			}
+		}
+	}
+	return alerts
+}
+
+// errorSample is a realised prediction: what was predicted and what the
+// road's score turned out to be at the target time.
+type errorSample struct {
+	RoadID    string
+	Recent    bool
+	Predicted float64
+	Actual    float64
+}
+
+type maeStats struct {
+	sum float64
+	n   int
+}
+
+func (m *maeStats) add(err float64) {
+	m.sum += math.Abs(err)
+	m.n++
+}
+
+func (m maeStats) mae() float64 {
+	if m.n == 0 {
+		return 0
+	}
+	return m.sum / float64(m.n)
+}
+
+// detectErrorDrift flags roads whose recent prediction error degraded versus
+// their baseline, and returns the network-wide recent and baseline MAE.
+func detectErrorDrift(samples []errorSample, p driftParams, now time.Time) (alerts []driftAlert, recentMAE, baselineMAE float64) {
+	type pair struct{ recent, baseline maeStats }
+	byRoad := make(map[string]*pair)
+	var all pair
+	for _, s := range samples {
+		st, ok := byRoad[s.RoadID]
+		if !ok {
+			st = &pair{}
+			byRoad[s.RoadID] = st
+		}
+		if s.Recent {
+			st.recent.add(s.Predicted - s.Actual)
+			all.recent.add(s.Predicted - s.Actual)
+		} else {
+			st.baseline.add(s.Predicted - s.Actual)
+			all.baseline.add(s.Predicted - s.Actual)
+		}
+	}
+
+	for roadID, st := range byRoad {
+		if st.recent.n < p.MinErrorSamples || st.baseline.n < p.MinErrorSamples {
+			continue
+		}
+		recent, base := st.recent.mae(), st.baseline.mae()
+		if recent > p.ErrorRatio*base && recent-base > p.ErrorFloor {
+			alerts = append(alerts, driftAlert{
+				TS: now, Kind: alertErrorDegraded, RoadID: roadID,
+				Value: round4(recent), Baseline: round4(base),
+				Message: fmt.Sprintf("MAE %.3f over the last %s vs %.3f baseline", recent, p.ErrorWindow, base),
+			})
+		}
+	}
+	return alerts, all.recent.mae(), all.baseline.mae()
+}
+
+// activeAlertsKey is the Redis hash of firing alerts, keyed by driftAlert.key.
+// Keeping it in Redis rather than in memory lets a replica that takes over a
+// shard resolve the alerts its previous owner fired instead of firing them again.
+const activeAlertsKey = "cityflow:alerts:active"
+
+// driftMonitor remembers which alerts are firing so each one is published
+// when it starts and when it resolves rather than on every check.
+type driftMonitor struct {
+	params driftParams
+	active map[string]driftAlert
+}
+
+func newDriftMonitor(params driftParams) *driftMonitor {
+	return &driftMonitor{params: params, active: make(map[string]driftAlert)}
+}
+
+// update returns the alerts that started or resolved. Only active alerts of
+// the checked kinds on roads this replica owns can resolve, so a failed check
+// or a shard handover does not clear them.
+func (m *driftMonitor) update(detected []driftAlert, checked map[string]bool, owns func(string) bool, now time.Time) []driftAlert {
+	var changes []driftAlert
+	seen := make(map[string]bool, len(detected))
+	for _, a := range detected {
+		if owns != nil && !owns(a.RoadID) {
+			continue
+		}
+		seen[a.key()] = true
+		if _, ok := m.active[a.key()]; ok {
+			continue
+		}
+		a.Source = "predictor"
+		a.State = "firing"
+		m.active[a.key()] = a
+		changes = append(changes, a)
+		driftAlertsFired.WithLabelValues(a.Kind).Inc()
+	}
+	for key, a := range m.active {
+		if seen[key] || !checked[a.Kind] || (owns != nil && !owns(a.RoadID)) {
+			continue
+		}
+		delete(m.active, key)
+		a.TS = now
+		a.State = "resolved"
+		a.Message = "resolved: " + a.Message
+		changes = append(changes, a)
+	}
+
+	counts := map[string]int{alertSpeedShift: 0, alertSampleRate: 0, alertStale: 0, alertErrorDegraded: 0}
+	for _, a := range m.active {
+		counts[a.Kind]++
+	}
+	for kind, n := range counts {
+		driftAlertsActive.WithLabelValues(kind).Set(float64(n))
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].key() < changes[j].key() })
+	return changes
+}
+
+// check runs the input and error checks for the roads this replica owns and
+// publishes alert transitions. The firing alerts are reloaded from Redis
+// first; without them a transition cannot be told from a repeat, so the check
+// is skipped when Redis is unavailable.
+func (m *driftMonitor) check(ctx context.Context, dbPool *pgxpool.Pool, redisClient *redis.Client, modelParams modelParams, owns func(string) bool) {
+	now := time.Now().UTC().Truncate(time.Second)
+	p := m.params
+
+	active, err := loadActiveAlerts(ctx, redisClient)
+	if err != nil {
+		log.Printf("drift check skipped: load active alerts failed: %v", err)
+		return
+	}
+	m.active = active
+	checked := make(map[string]bool)
+	var detected []driftAlert
+
+	recentFrom := now.Add(-p.Recent)
+	baseline, errB := loadBaselineStats(ctx, dbPool, now.Add(-p.Baseline), recentFrom.Truncate(time.Hour))
+	recent, errR := loadRecentStats(ctx, dbPool, recentFrom, now)
+	if errB != nil || errR != nil {
+		log.Printf("drift check: load input stats failed: %v", errors.Join(errB, errR))
+	} else {
+		detected = append(detected, detectInputDrift(baseline, recent, p, now)...)
+		checked[alertSpeedShift], checked[alertSampleRate], checked[alertStale] = true, true, true
+	}
+
+	profiles, err := loadRoadProfiles(ctx, dbPool)
+	if err != nil {
+		log.Printf("drift check: load road profiles failed, using fallback: %v", err)
+	}
+	samples, err := loadErrorSamples(ctx, dbPool, modelParams, cycleInput{Profiles: profiles}, now, p)
+	if err != nil {
+		log.Printf("drift check: load error samples failed: %v", err)
+	} else {
+		alerts, recentMAE, baselineMAE := detectErrorDrift(samples, p, now)
+		detected = append(detected, alerts...)
+		checked[alertErrorDegraded] = true
+		predictionMAE.WithLabelValues("recent").Set(recentMAE)
+		predictionMAE.WithLabelValues("baseline").Set(baselineMAE)
+	}
+
+	changes := m.update(detected, checked, owns, now)
+	for _, a := range changes {
+		log.Printf("drift alert %s: %s %s: %s", a.State, a.Kind, a.RoadID, a.Message)
+	}
+	// Publish only what was recorded, so a failed write is retried as a
+	// transition on the next check rather than published twice.
+	if err := saveAlertChanges(ctx, redisClient, changes); err != nil {
+		log.Printf("drift check: save active alerts failed: %v", err)
+		return
+	}
+	publishAlerts(ctx, redisClient, changes)
+}
+
+func loadActiveAlerts(ctx context.Context, redisClient *redis.Client) (map[string]driftAlert, error) {
+	fields, err := redisClient.HGetAll(ctx, activeAlertsKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	active := make(map[string]driftAlert, len(fields))
+	for key, data := range fields {
+		var a driftAlert
+		if err := json.Unmarshal([]byte(data), &a); err != nil {
+			log.Printf("drop unreadable active alert %s: %v", key, err)
+			continue
+		}
+		active[key] = a
+	}
+	return active, nil
+}
+
+// saveAlertChanges records firing alerts and removes resolved ones. Replicas
+// own disjoint roads, so their field-level writes never overlap.
+func saveAlertChanges(ctx context.Context, redisClient *redis.Client, changes []driftAlert) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	pipe := redisClient.TxPipeline()
+	for _, a := range changes {
+		if a.State == "resolved" {
+			pipe.HDel(ctx, activeAlertsKey, a.key())
+			continue
+		}
+		data, err := json.Marshal(a)
+		if err != nil {
+			return fmt.Errorf("marshal alert %s: %w", a.key(), err)
+		}
+		pipe.HSet(ctx, activeAlertsKey, a.key(), data)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func loadBaselineStats(ctx context.Context, dbPool *pgxpool.Pool, from, to time.Time) (map[string]inputStats, error) {
+	rows, err := dbPool.Query(ctx, `
+		SELECT road_id, AVG(avg_speed), COALESCE(STDDEV_SAMP(avg_speed), 0), AVG(samples), COUNT(*)
+		FROM traffic_1h
+		WHERE bucket >= $1 AND bucket < $2
+		GROUP BY road_id
+	`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := make(map[string]inputStats)
+	for rows.Next() {
+		var roadID string
+		var s inputStats
+		if err := rows.Scan(&roadID, &s.MeanSpeed, &s.StdSpeed, &s.SamplesPerHour, &s.Hours); err != nil {
+			return nil, err
+		}
+		stats[roadID] = s
+	}
+	return stats, rows.Err()
+}
+
+func loadRecentStats(ctx context.Context, dbPool *pgxpool.Pool, from, to time.Time) (map[string]inputStats, error) {
+	rows, err := dbPool.Query(ctx, `
+		SELECT road_id, SUM(avg_speed * samples) / NULLIF(SUM(samples), 0), SUM(samples)
+		FROM traffic_5m
+		WHERE bucket >= $1 AND bucket < $2
+		GROUP BY road_id
+	`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hours := to.Sub(from).Hours()
+	stats := make(map[string]inputStats)
+	for rows.Next() {
+		var roadID string
+		var meanSpeed *float64
+		var samples int64
+		if err := rows.Scan(&roadID, &meanSpeed, &samples); err != nil {
+			return nil, err
+		}
+		if meanSpeed == nil {
+			continue
+		}
+		stats[roadID] = inputStats{MeanSpeed: *meanSpeed, SamplesPerHour: float64(samples) / hours}
+	}
+	return stats, rows.Err()
+}
+
+// loadErrorSamples pairs predictions whose target bucket is complete with the
+// observed score of that bucket. One prediction per road and hour is enough
+// for the baseline and keeps the scan small.
+func loadErrorSamples(ctx context.Context, dbPool *pgxpool.Pool, params modelParams, static cycleInput, now time.Time, p driftParams) ([]errorSample, error) {
+	to := now.Add(-time.Duration(params.HorizonMin)*time.Minute - bucketWidth)
+	recentFrom := to.Add(-p.ErrorWindow)
+
+	rows, err := dbPool.Query(ctx, `
+		SELECT DISTINCT ON (p.road_id, time_bucket('1 hour', p.ts))
+			p.road_id, p.ts, p.congestion_score, t.avg_speed, t.avg_occ, t.avg_flow
+		FROM predictions p
+		JOIN traffic_5m t ON t.road_id = p.road_id
+			AND t.bucket = time_bucket('5 minutes', p.ts + make_interval(mins => p.horizon_min))
+		WHERE p.model_version = $1 AND p.horizon_min = $2
+			AND p.ts >= $3 AND p.ts < $4
+		ORDER BY p.road_id, time_bucket('1 hour', p.ts), p.ts
+	`, params.Version, params.HorizonMin, now.Add(-p.Baseline), to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var samples []errorSample
+	for rows.Next() {
+		var s errorSample
+		var ts time.Time
+		var speed, occ, flow float64
+		if err := rows.Scan(&s.RoadID, &ts, &s.Predicted, &speed, &occ, &flow); err != nil {
+			return nil, err
+		}
+		s.Recent = !ts.Before(recentFrom)
+		s.Actual = static.actualScore(s.RoadID, speed, occ, flow)
+		samples = append(samples, s)
+	}
+	return samples, rows.Err()
+}
+
+func publishAlerts(ctx context.Context, redisClient *redis.Client, alerts []driftAlert) {
+	for _, a := range alerts {
+		data, err := json.Marshal(a)
+		if err != nil {
+			log.Printf("json marshal failed for alert %s: %v", a.key(), err)
+			continue
+		}
+		if err := redisClient.Publish(ctx, "cityflow:alerts", data).Err(); err != nil {
+			log.Printf("redis publish alert failed: %v", err)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDetectInputDrift(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	p := defaultDriftParams()
+	steady := inputStats{MeanSpeed: 40, StdSpeed: 5, SamplesPerHour: 60, Hours: 300}
+	baseline := map[string]inputStats{
+		"OK":         steady,
+		"RECAL":      steady,
+		"SPARSE":     steady,
+		"SILENT":     steady,
+		"NEW":        {MeanSpeed: 40, StdSpeed: 5, SamplesPerHour: 60, Hours: 10},
+		"STEADY-STD": {MeanSpeed: 40, StdSpeed: 0.1, SamplesPerHour: 60, Hours: 300},
+	}
+	recent := map[string]inputStats{
+		"OK":         {MeanSpeed: 45, SamplesPerHour: 55},
+		"RECAL":      {MeanSpeed: 70, SamplesPerHour: 60}, // 6 sd above baseline
+		"SPARSE":     {MeanSpeed: 40, SamplesPerHour: 12},
+		"NEW":        {MeanSpeed: 90, SamplesPerHour: 1},
+		"STEADY-STD": {MeanSpeed: 44, SamplesPerHour: 60}, // 2 km/h floor: z = 2
+	}
+
+	got := make(map[string]string)
+	for _, a := range detectInputDrift(baseline, recent, p, now) {
+		got[a.RoadID] += a.Kind
+	}
+	want := map[string]string{"RECAL": alertSpeedShift, "SPARSE": alertSampleRate, "SILENT": alertStale}
+	if len(got) != len(want) {
+		t.Errorf("alerts = %v, want %v", got, want)
+	}
+	for road, kind := range want {
+		if got[road] != kind {
+			t.Errorf("%s: got %q, want %q", road, got[road], kind)
+		}
+	}
+}
+
+func TestDetectErrorDrift(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	var samples []errorSample
+	for i := 0; i < 10; i++ {
+		samples = append(samples,
+			errorSample{RoadID: "A", Predicted: 0.5, Actual: 0.45},
+			errorSample{RoadID: "B", Predicted: 0.5, Actual: 0.45},
+		)
+	}
+	for i := 0; i < 5; i++ {
+		samples = append(samples,
+			errorSample{RoadID: "A", Recent: true, Predicted: 0.5, Actual: 0.2}, // degraded
+			errorSample{RoadID: "B", Recent: true, Predicted: 0.5, Actual: 0.48},
+		)
+	}
+
+	alerts, recentMAE, baselineMAE := detectErrorDrift(samples, defaultDriftParams(), now)
+	if len(alerts) != 1 || alerts[0].RoadID != "A" || alerts[0].Kind != alertErrorDegraded {
+		t.Errorf("alerts = %+v, want one error_degraded on A", alerts)
+	}
+	if round4(baselineMAE) != 0.05 || round4(recentMAE) != 0.16 {
+		t.Errorf("MAE recent=%v baseline=%v, want 0.16 and 0.05", recentMAE, baselineMAE)
+	}
+}
+
+func TestDriftMonitorPublishesTransitionsOnly(t *testing.T) {
+	t0 := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	m := newDriftMonitor(defaultDriftParams())
+	inputKinds := map[string]bool{alertSpeedShift: true, alertSampleRate: true, alertStale: true}
+	recal := driftAlert{Kind: alertSpeedShift, RoadID: "A"}
+	wrongShard := driftAlert{Kind: alertStale, RoadID: "B"}
+	ownsA := func(roadID string) bool { return roadID == "A" }
+
+	changes := m.update([]driftAlert{recal, wrongShard}, inputKinds, ownsA, t0)
+	if len(changes) != 1 || changes[0].State != "firing" || changes[0].Source != "predictor" {
+		t.Fatalf("first check: %+v, want A firing", changes)
+	}
+	if changes := m.update([]driftAlert{recal}, inputKinds, ownsA, t0.Add(time.Minute)); len(changes) != 0 {
+		t.Errorf("still firing should publish nothing, got %+v", changes)
+	}
+	// A failed input check must not resolve input alerts.
+	if changes := m.update(nil, map[string]bool{alertErrorDegraded: true}, ownsA, t0.Add(2*time.Minute)); len(changes) != 0 {
+		t.Errorf("unchecked kind resolved: %+v", changes)
+	}
+	changes = m.update(nil, inputKinds, ownsA, t0.Add(3*time.Minute))
+	if len(changes) != 1 || changes[0].State != "resolved" || !changes[0].TS.Equal(t0.Add(3*time.Minute)) {
+		t.Errorf("recovery: %+v, want A resolved", changes)
+	}
+}
+
+func TestDriftMonitorResolvesInheritedAlerts(t *testing.T) {
+	t0 := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	inputKinds := map[string]bool{alertSpeedShift: true, alertSampleRate: true, alertStale: true}
+	fired := driftAlert{TS: t0, Source: "predictor", Kind: alertStale, RoadID: "A", State: "firing", Message: "no data"}
+
+	// A replica taking over the shard starts from the alerts stored by the
+	// previous owner, as check loads them from Redis.
+	m := newDriftMonitor(defaultDriftParams())
+	m.active = map[string]driftAlert{fired.key(): fired}
+	if changes := m.update([]driftAlert{{Kind: alertStale, RoadID: "A"}}, inputKinds, nil, t0.Add(time.Minute)); len(changes) != 0 {
+		t.Errorf("inherited alert fired again: %+v", changes)
+	}
+	changes := m.update(nil, inputKinds, nil, t0.Add(2*time.Minute))
+	if len(changes) != 1 || changes[0].State != "resolved" || changes[0].RoadID != "A" {
+		t.Errorf("recovery: %+v, want inherited A resolved", changes)
+	}
+}
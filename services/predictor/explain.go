@@ -0,0 +1,53 @@
+package main
+
+// predictionComponents records the intermediate values behind a prediction so
+// traffic engineers can see why a road got its score. It is stored as JSONB in
+// predictions.components and follows the order of the model steps.
+type predictionComponents struct {
+	RoadClass       string       `json:"road_class,omitempty"`
+	Subscores       subscores    `json:"subscores"` // latest bucket
+	Weights         scoreWeights `json:"weights"`
+	CurrentScore    float64      `json:"current_score"`
+	Slope           float64      `json:"slope_per_min"`
+	ProjectedScore  float64      `json:"projected_score"`
+	EWMAAlpha       float64      `json:"ewma_alpha"`
+	BlendedScore    float64      `json:"blended_score"`
+	SpatialPressure float64      `json:"spatial_pressure"`
+	RushHourFactor  float64      `json:"rush_hour_factor"`
+	// WeatherFactor is 1 when no recent weather is known for the road's zone.
+	WeatherFactor  float64             `json:"weather_factor"`
+	Weather        *weatherObservation `json:"weather,omitempty"`
+	TrendStability float64             `json:"trend_stability"`
+	Samples        int64               `json:"samples"`
+	Buckets        int                 `json:"buckets"`
+	ImputedBuckets int                 `json:"imputed_buckets"`
+	ImputedShare   float64             `json:"imputed_share"`
+	// Closed is set when an operator closed the road: its score is pinned to 1.
+	Closed bool `json:"closed,omitempty"`
+}
+
+func explain(st roadState, prof roadProfile, params modelParams, pressure, rush float64) *predictionComponents {
+	return &predictionComponents{
+		RoadClass: prof.Class,
+		Subscores: subscores{
+			Speed:     round4(st.latest.Speed),
+			Occupancy: round4(st.latest.Occupancy),
+			Flow:      round4(st.latest.Flow),
+		},
+		Weights:         prof.Weights,
+		CurrentScore:    round4(st.current),
+		Slope:           round4(st.slope),
+		ProjectedScore:  round4(st.projected),
+		EWMAAlpha:       params.EWMAAlpha,
+		BlendedScore:    round4(st.blended),
+		SpatialPressure: round4(pressure),
+		RushHourFactor:  rush,
+		WeatherFactor:   1,
+		TrendStability:  round4(st.trendStability),
+		Samples:         st.samples,
+		Buckets:         st.buckets,
+		ImputedBuckets:  st.imputed,
+		ImputedShare:    round4(st.imputedShare()),
+		Closed:          prof.Closed,
+	}
+}
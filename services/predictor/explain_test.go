@@ -0,0 +1,46 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestPredictionComponentsExplainScore(t *testing.T) {
+	now := time.Date(2026, 3, 10, 8, 0, 0, 0, time.UTC) // morning rush
+	in := cycleInput{
+		Now: now,
+		Buckets: map[string][]bucketData{
+			"A": flatBuckets(30, 0.5),
+			"B": flatBuckets(10, 0.9),
+		},
+		Graph: newRoadGraph([]roadLink{{"A", "B"}}),
+	}
+	params := defaultModelParams()
+
+	var got Prediction
+	for _, p := range predictRoads(in, params) {
+		if p.RoadID == "A" {
+			got = p
+		}
+	}
+	c := got.Components
+	if c == nil {
+		t.Fatal("prediction has no components")
+	}
+
+	if c.Buckets != 6 || c.Samples != 60 || c.RushHourFactor != 1.15 || c.EWMAAlpha != params.EWMAAlpha {
+		t.Errorf("unexpected components: %+v", c)
+	}
+	if current := c.Subscores.weighted(c.Weights); math.Abs(current-c.CurrentScore) > 1e-3 {
+		t.Errorf("weighted subscores = %v, want current score %v", current, c.CurrentScore)
+	}
+	// B is downstream and more congested, so it pushes A up.
+	if c.SpatialPressure <= 0 {
+		t.Errorf("spatial pressure = %v, want > 0", c.SpatialPressure)
+	}
+	want := math.Min(1, (c.BlendedScore+c.SpatialPressure)*c.RushHourFactor)
+	if math.Abs(want-got.CongestionScore) > 1e-3 {
+		t.Errorf("components give %v, score is %v", want, got.CongestionScore)
+	}
+}
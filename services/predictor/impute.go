@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Imputation methods recorded on bucketData.imputed; observed buckets leave it empty.
+const (
+	imputedLinear    = "linear"    // interpolated between observed buckets
+	imputedNeighbour = "neighbour" // scaled by the change seen on neighbouring roads
+	imputedSeasonal  = "seasonal"  // same hour in previous weeks
+)
+
+const (
+	// imputationPenalty is how much confidence a fully imputed window loses.
+	imputationPenalty = 0.5
+	seasonalWeeks     = 4
+)
+
+var bucketsImputed = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "cityflow_predictor_buckets_imputed_total",
+	Help: "Total number of missing 5-minute buckets filled in, by method.",
+}, []string{"method"})
+
+// offsetKey identifies a bucket within a lookback window by its offset.
+func offsetKey(offsetMin float64) int {
+	return int(math.Round(offsetMin))
+}
+
+// fillGaps returns roadID's lookback buckets with missing complete buckets
+// filled in. Gaps between observations are interpolated; leading and trailing
+// gaps follow the relative change on neighbouring roads, or fall back to the
+// seasonal baseline. Gaps no method can fill are left out, and a road without
+// any observation is not imputed at all.
+func (in cycleInput) fillGaps(roadID string, params modelParams) []bucketData {
+	observed := in.Buckets[roadID]
+	if len(observed) == 0 {
+		return observed
+	}
+
+	width := bucketWidth.Minutes()
+	phase := math.Mod(observed[0].offsetMin, width)
+	if phase < 0 {
+		phase += width
+	}
+	from := in.Now.Add(-params.Lookback)
+	lookbackMin := params.Lookback.Minutes()
+
+	byOffset := make(map[int]bucketData, len(observed))
+	for _, b := range observed {
+		byOffset[offsetKey(b.offsetMin)] = b
+	}
+
+	var filled []bucketData
+	prev := -1 // index in observed of the last observation before offset
+	for off := phase; off+width <= lookbackMin; off += width {
+		if b, ok := byOffset[offsetKey(off)]; ok {
+			filled = append(filled, b)
+			prev++
+			continue
+		}
+		// Observations are sorted, so prev+1 is the next one after off.
+		var before, after *bucketData
+		if prev >= 0 {
+			before = &observed[prev]
+		}
+		if prev+1 < len(observed) {
+			after = &observed[prev+1]
+		}
+
+		ts := from.Add(time.Duration(off * float64(time.Minute)))
+		b, ok := in.imputeBucket(roadID, off, ts, before, after)
+		if !ok {
+			continue
+		}
+		b.offsetMin = off
+		b.ts = ts
+		bucketsImputed.WithLabelValues(b.imputed).Inc()
+		filled = append(filled, b)
+	}
+	// Keep observations outside the complete-bucket grid, e.g. the current
+	// partial bucket.
+	for _, b := range observed {
+		if b.offsetMin+width > lookbackMin {
+			filled = append(filled, b)
+		}
+	}
+	return filled
+}
+
+func (in cycleInput) imputeBucket(roadID string, off float64, ts time.Time, before, after *bucketData) (bucketData, bool) {
+	if before != nil && after != nil {
+		w := (off - before.offsetMin) / (after.offsetMin - before.offsetMin)
+		return bucketData{
+			avgSpeed: before.avgSpeed + w*(after.avgSpeed-before.avgSpeed),
+			avgOcc:   before.avgOcc + w*(after.avgOcc-before.avgOcc),
+			avgFlow:  before.avgFlow + w*(after.avgFlow-before.avgFlow),
+			imputed:  imputedLinear,
+		}, true
+	}
+
+	anchor := before
+	if anchor == nil {
+		anchor = after
+	}
+	if b, ok := in.neighbourImpute(roadID, off, *anchor); ok {
+		return b, true
+	}
+	if b, ok := in.Seasonal[roadID][ts.Truncate(time.Hour).Unix()]; ok {
+		b.imputed = imputedSeasonal
+		b.samples = 0
+		return b, true
+	}
+	return bucketData{}, false
+}
+
+// neighbourImpute applies to the anchor bucket the mean change that the
+// road's observed neighbours went through between the anchor and off.
+func (in cycleInput) neighbourImpute(roadID string, off float64, anchor bucketData) (bucketData, bool) {
+	var speedRatio, occDelta, flowRatio float64
+	n := 0
+	for _, neighbours := range [][]string{in.Graph.upstream[roadID], in.Graph.downstream[roadID]} {
+		for _, nb := range neighbours {
+			var at, ref *bucketData
+			for i := range in.Buckets[nb] {
+				b := &in.Buckets[nb][i]
+				switch offsetKey(b.offsetMin) {
+				case offsetKey(off):
+					at = b
+				case offsetKey(anchor.offsetMin):
+					ref = b
+				}
+			}
+			if at == nil || ref == nil || ref.avgSpeed <= 0 || ref.avgFlow <= 0 {
+				continue
+			}
+			speedRatio += at.avgSpeed / ref.avgSpeed
+			flowRatio += at.avgFlow / ref.avgFlow
+			occDelta += at.avgOcc - ref.avgOcc
+			n++
+		}
+	}
+	if n == 0 {
+		return bucketData{}, false
+	}
+	k := float64(n)
+	return bucketData{
+		avgSpeed: anchor.avgSpeed * speedRatio / k,
+		avgOcc:   math.Max(0, math.Min(1, anchor.avgOcc+occDelta/k)),
+		avgFlow:  anchor.avgFlow * flowRatio / k,
+		imputed:  imputedNeighbour,
+	}, true
+}
+
+// loadSeasonalBaseline averages, for each hour overlapping [from, to), the
+// same local hour over the previous weeks from traffic_1h. The result is
+// keyed by road and by the Unix time of the hour it stands in for.
+func loadSeasonalBaseline(ctx context.Context, dbPool *pgxpool.Pool, from, to time.Time, loc *time.Location) (map[string]map[int64]bucketData, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	target := make(map[int64]int64) // past hour -> hour it stands in for
+	var past []time.Time
+	for h := from.Truncate(time.Hour); h.Before(to); h = h.Add(time.Hour) {
+		for w := 1; w <= seasonalWeeks; w++ {
+			// AddDate in local time keeps the wall-clock hour across DST.
+			p := h.In(loc).AddDate(0, 0, -7*w).UTC()
+			target[p.Unix()] = h.Unix()
+			past = append(past, p)
+		}
+	}
+
+	rows, err := dbPool.Query(ctx, `
+		SELECT road_id, bucket, avg_speed, avg_occ, avg_flow
+		FROM traffic_1h
+		WHERE bucket = ANY($1)
+	`, past)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	type sum struct {
+		speed, occ, flow float64
+		n                int
+	}
+	sums := make(map[string]map[int64]*sum)
+	for rows.Next() {
+		var roadID string
+		var bucket time.Time
+		var speed, occ, flow float64
+		if err := rows.Scan(&roadID, &bucket, &speed, &occ, &flow); err != nil {
+			return nil, err
+		}
+		hour, ok := target[bucket.Unix()]
+		if !ok {
+			continue
+		}
+		if sums[roadID] == nil {
+			sums[roadID] = make(map[int64]*sum)
+		}
+		s := sums[roadID][hour]
+		if s == nil {
+			s = &sum{}
+			sums[roadID][hour] = s
+		}
+		s.speed += speed
+		s.occ += occ
+		s.flow += flow
+		s.n++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	seasonal := make(map[string]map[int64]bucketData, len(sums))
+	for roadID, hours := range sums {
+		seasonal[roadID] = make(map[int64]bucketData, len(hours))
+		for hour, s := range hours {
+			k := float64(s.n)
+			seasonal[roadID][hour] = bucketData{avgSpeed: s.speed / k, avgOcc: s.occ / k, avgFlow: s.flow / k}
+		}
+	}
+	return seasonal, nil
+}
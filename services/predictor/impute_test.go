@@ -0,0 +1,153 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// withGaps drops the buckets at the given indexes of a six-bucket window.
+func withGaps(buckets []bucketData, drop ...int) []bucketData {
+	skip := make(map[int]bool, len(drop))
+	for _, i := range drop {
+		skip[i] = true
+	}
+	var kept []bucketData
+	for i, b := range buckets {
+		if !skip[i] {
+			kept = append(kept, b)
+		}
+	}
+	return kept
+}
+
+func rampBuckets() []bucketData {
+	buckets := flatBuckets(0, 0.2)
+	for i := range buckets {
+		buckets[i].avgSpeed = 60 - 5*float64(i) // 60, 55, ..., 35
+	}
+	return buckets
+}
+
+func TestFillGapsInterpolatesInteriorGaps(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	in := cycleInput{Now: now, Buckets: map[string][]bucketData{"A": withGaps(rampBuckets(), 2, 3)}}
+
+	filled := in.fillGaps("A", defaultModelParams())
+	if len(filled) != 6 {
+		t.Fatalf("got %d buckets, want 6", len(filled))
+	}
+	for i, b := range filled {
+		if b.offsetMin != float64(i*5) {
+			t.Errorf("bucket %d offset = %v, want %d", i, b.offsetMin, i*5)
+		}
+	}
+	if filled[2].imputed != imputedLinear || filled[2].avgSpeed != 50 || filled[3].avgSpeed != 45 {
+		t.Errorf("interpolated buckets = %+v, %+v", filled[2], filled[3])
+	}
+	if !filled[2].ts.Equal(now.Add(-20 * time.Minute)) {
+		t.Errorf("imputed ts = %v, want now-20m", filled[2].ts)
+	}
+	if filled[1].imputed != "" || filled[4].imputed != "" {
+		t.Error("observed buckets must not be marked imputed")
+	}
+}
+
+func TestFillGapsTrailingGapFromNeighbours(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	nb := flatBuckets(50, 0.2)
+	nb[5].avgSpeed, nb[5].avgOcc = 25, 0.5 // neighbour halves its speed in the last bucket
+	in := cycleInput{
+		Now:     now,
+		Buckets: map[string][]bucketData{"A": withGaps(flatBuckets(40, 0.1), 5), "B": nb},
+		Graph:   newRoadGraph([]roadLink{{"A", "B"}}),
+	}
+
+	filled := in.fillGaps("A", defaultModelParams())
+	last := filled[len(filled)-1]
+	if len(filled) != 6 || last.imputed != imputedNeighbour {
+		t.Fatalf("trailing bucket = %+v (of %d), want neighbour-imputed", last, len(filled))
+	}
+	if last.avgSpeed != 20 || math.Abs(last.avgOcc-0.4) > 1e-9 || last.avgFlow != 40 {
+		t.Errorf("neighbour-imputed bucket = %+v, want speed 20, occ 0.4, flow 40", last)
+	}
+}
+
+func TestFillGapsTrailingGapFromSeasonal(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	hour := now.Add(-5 * time.Minute).Truncate(time.Hour).Unix()
+	in := cycleInput{
+		Now:      now,
+		Buckets:  map[string][]bucketData{"A": withGaps(flatBuckets(40, 0.1), 5), "NO-HISTORY": withGaps(flatBuckets(40, 0.1), 5)},
+		Seasonal: map[string]map[int64]bucketData{"A": {hour: {avgSpeed: 30, avgOcc: 0.3, avgFlow: 80, samples: 600}}},
+	}
+
+	filled := in.fillGaps("A", defaultModelParams())
+	last := filled[len(filled)-1]
+	if len(filled) != 6 || last.imputed != imputedSeasonal || last.avgSpeed != 30 || last.samples != 0 {
+		t.Errorf("trailing bucket = %+v, want seasonal speed 30 without samples", last)
+	}
+
+	// Nothing to impute from: the gap is left out.
+	if got := in.fillGaps("NO-HISTORY", defaultModelParams()); len(got) != 5 {
+		t.Errorf("got %d buckets, want the 5 observed", len(got))
+	}
+	if got := in.fillGaps("MISSING", defaultModelParams()); len(got) != 0 {
+		t.Errorf("road without observations got %d buckets", len(got))
+	}
+}
+
+func TestFillGapsKeepsPartialBucket(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 2, 0, 0, time.UTC) // 2 minutes into a bucket
+	var buckets []bucketData
+	for _, off := range []float64{3, 13, 18, 28} { // 11:35, 11:45, 11:50 and the partial 12:00
+		buckets = append(buckets, bucketData{offsetMin: off, avgSpeed: 50, avgOcc: 0.2, avgFlow: 40, samples: 10})
+	}
+	in := cycleInput{Now: now, Buckets: map[string][]bucketData{"A": buckets}}
+
+	filled := in.fillGaps("A", defaultModelParams())
+	var offsets []float64
+	for _, b := range filled {
+		offsets = append(offsets, b.offsetMin)
+	}
+	want := []float64{3, 8, 13, 18, 23, 28}
+	if len(offsets) != len(want) {
+		t.Fatalf("offsets = %v, want %v", offsets, want)
+	}
+	for i := range want {
+		if offsets[i] != want[i] {
+			t.Fatalf("offsets = %v, want %v", offsets, want)
+		}
+	}
+	// 11:55 is a trailing gap of the complete buckets but lies before the
+	// partial 12:00 bucket, so it is interpolated.
+	if filled[4].imputed != imputedLinear {
+		t.Errorf("11:55 bucket = %+v, want linear", filled[4])
+	}
+}
+
+func TestImputationLowersConfidence(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	in := cycleInput{Now: now, Buckets: map[string][]bucketData{
+		"FULL": flatBuckets(50, 0.2),
+		"GAPS": withGaps(flatBuckets(50, 0.2), 1, 2, 3),
+	}}
+
+	byRoad := make(map[string]Prediction)
+	for _, p := range predictRoads(in, defaultModelParams()) {
+		byRoad[p.RoadID] = p
+	}
+	full, gaps := byRoad["FULL"], byRoad["GAPS"]
+	if gaps.Components.ImputedBuckets != 3 || gaps.Components.ImputedShare != 0.5 || gaps.Components.Buckets != 6 {
+		t.Errorf("GAPS components = %+v, want 3 of 6 imputed", gaps.Components)
+	}
+	if full.Components.ImputedBuckets != 0 {
+		t.Errorf("FULL has %d imputed buckets", full.Components.ImputedBuckets)
+	}
+	if gaps.Confidence >= full.Confidence {
+		t.Errorf("confidence with gaps %v should be below %v", gaps.Confidence, full.Confidence)
+	}
+	if gaps.CongestionScore != full.CongestionScore {
+		t.Errorf("flat traffic should score the same: %v vs %v", gaps.CongestionScore, full.CongestionScore)
+	}
+}
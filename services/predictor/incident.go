@@ -0,0 +1,308 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	incidentSpeedDrop      = "speed_drop"
+	incidentOccupancySpike = "occupancy_spike"
+)
+
+var severityRank = map[string]int{"minor": 1, "moderate": 2, "major": 3}
+
+var (
+	incidentsOpened = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cityflow_predictor_incidents_opened_total",
+		Help: "Total number of incidents detected, by severity.",
+	}, []string{"severity"})
+	incidentsOpen = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "cityflow_predictor_incidents_open",
+		Help: "Number of open incidents on roads owned by this replica.",
+	})
+)
+
+// incidentParams are the thresholds of the incident detector. A road's latest
+// bucket is compared with the earlier buckets of the lookback window.
+type incidentParams struct {
+	// Speed collapse: latest speed below DropRatio x baseline (MajorRatio for
+	// a major incident), on roads whose baseline is at least MinBaselineSpeed.
+	DropRatio        float64
+	MajorRatio       float64
+	MinBaselineSpeed float64
+	// Occupancy spike: latest occupancy above baseline by OccupancySpike.
+	OccupancySpike float64
+	// Neighbours must be at least this much less affected, otherwise the drop
+	// is area-wide congestion rather than an incident.
+	NeighbourMargin float64
+	// An incident closes once speed recovers to ClearRatio x the baseline
+	// recorded when it opened.
+	ClearRatio float64
+	MinBuckets int
+}
+
+func defaultIncidentParams() incidentParams {
+	return incidentParams{
+		DropRatio:        0.5,
+		MajorRatio:       0.25,
+		MinBaselineSpeed: 20,
+		OccupancySpike:   0.3,
+		NeighbourMargin:  0.3,
+		ClearRatio:       0.8,
+		MinBuckets:       3,
+	}
+}
+
+// incident is a row of the incidents table and the payload published on
+// cityflow:incidents, with Event set to opened, updated or closed.
+type incident struct {
+	ID                int64      `json:"id"`
+	Event             string     `json:"event,omitempty"`
+	RoadID            string     `json:"road_id"`
+	Kind              string     `json:"kind"`
+	Severity          string     `json:"severity"`
+	StartedAt         time.Time  `json:"started_at"`
+	EndedAt           *time.Time `json:"ended_at"`
+	SpeedKMH          float64    `json:"speed_kmh"`
+	BaselineSpeedKMH  float64    `json:"baseline_speed_kmh"`
+	Occupancy         float64    `json:"occupancy"`
+	BaselineOccupancy float64    `json:"baseline_occupancy"`
+}
+
+// roadChange compares a road's latest bucket with the mean of its earlier ones.
+type roadChange struct {
+	speed, baseSpeed float64
+	occ, baseOcc     float64
+}
+
+func (c roadChange) speedRatio() float64 {
+	if c.baseSpeed <= 0 {
+		return 1
+	}
+	return c.speed / c.baseSpeed
+}
+
+func (c roadChange) occDelta() float64 {
+	return c.occ - c.baseOcc
+}
+
+func latestChange(buckets []bucketData, minBuckets int) (roadChange, bool) {
+	if len(buckets) < minBuckets {
+		return roadChange{}, false
+	}
+	last := buckets[len(buckets)-1]
+	earlier := buckets[:len(buckets)-1]
+	var c roadChange
+	for _, b := range earlier {
+		c.baseSpeed += b.avgSpeed
+		c.baseOcc += b.avgOcc
+	}
+	c.baseSpeed /= float64(len(earlier))
+	c.baseOcc /= float64(len(earlier))
+	c.speed, c.occ = last.avgSpeed, last.avgOcc
+	return c, true
+}
+
+// detectIncidents returns the owned roads whose latest bucket shows a speed
+// collapse or occupancy spike that their neighbours in the road graph do not.
+func detectIncidents(in cycleInput, p incidentParams) map[string]incident {
+	changes := make(map[string]roadChange, len(in.Buckets))
+	for roadID, buckets := range in.Buckets {
+		if c, ok := latestChange(buckets, p.MinBuckets); ok {
+			changes[roadID] = c
+		}
+	}
+
+	found := make(map[string]incident)
+	for roadID, c := range changes {
+		if in.Owns != nil && !in.Owns(roadID) {
+			continue
+		}
+
+		var nbRatio, nbDelta float64
+		n := 0
+		for _, neighbours := range [][]string{in.Graph.upstream[roadID], in.Graph.downstream[roadID]} {
+			for _, nb := range neighbours {
+				if nc, ok := changes[nb]; ok {
+					nbRatio += nc.speedRatio()
+					nbDelta += nc.occDelta()
+					n++
+				}
+			}
+		}
+		if n > 0 {
+			nbRatio /= float64(n)
+			nbDelta /= float64(n)
+		} else {
+			nbRatio, nbDelta = 1, 0
+		}
+
+		inc := incident{
+			RoadID: roadID, StartedAt: in.Now,
+			SpeedKMH: c.speed, BaselineSpeedKMH: c.baseSpeed,
+			Occupancy: c.occ, BaselineOccupancy: c.baseOcc,
+		}
+		ratio := c.speedRatio()
+		switch {
+		case c.baseSpeed >= p.MinBaselineSpeed && ratio < p.DropRatio && nbRatio-ratio >= p.NeighbourMargin:
+			inc.Kind, inc.Severity = incidentSpeedDrop, "moderate"
+			if ratio < p.MajorRatio {
+				inc.Severity = "major"
+			}
+		case c.occDelta() > p.OccupancySpike && c.occDelta()-nbDelta >= p.NeighbourMargin:
+			inc.Kind, inc.Severity = incidentOccupancySpike, "minor"
+		default:
+			continue
+		}
+		found[roadID] = inc
+	}
+	return found
+}
+
+// reconcileIncidents merges this cycle's detections with the open incidents.
+// New detections open an incident, a worse severity updates it, and an open
+// incident closes once the road's speed is back near the baseline recorded at
+// opening, or when the road has no data left in the lookback window.
+func reconcileIncidents(open map[string]incident, detected map[string]incident, in cycleInput, p incidentParams) (opened, updated, closed []incident) {
+	for roadID, d := range detected {
+		cur, ok := open[roadID]
+		if !ok {
+			d.Event = "opened"
+			opened = append(opened, d)
+			continue
+		}
+		if severityRank[d.Severity] > severityRank[cur.Severity] {
+			cur.Event, cur.Severity = "updated", d.Severity
+			cur.SpeedKMH, cur.Occupancy = d.SpeedKMH, d.Occupancy
+			updated = append(updated, cur)
+		}
+	}
+
+	for roadID, cur := range open {
+		if _, ok := detected[roadID]; ok {
+			continue
+		}
+		if in.Owns != nil && !in.Owns(roadID) {
+			continue
+		}
+		buckets := in.Buckets[roadID]
+		if len(buckets) > 0 {
+			last := buckets[len(buckets)-1]
+			recovered := last.avgSpeed >= p.ClearRatio*cur.BaselineSpeedKMH
+			if cur.Kind == incidentOccupancySpike {
+				recovered = last.avgOcc-cur.BaselineOccupancy <= p.OccupancySpike/2
+			}
+			if !recovered {
+				continue
+			}
+			cur.SpeedKMH, cur.Occupancy = last.avgSpeed, last.avgOcc
+		}
+		end := in.Now
+		cur.Event, cur.EndedAt = "closed", &end
+		closed = append(closed, cur)
+	}
+	return opened, updated, closed
+}
+
+// handleIncidents runs the detector on a cycle's input and persists and
+// publishes the resulting incident changes.
+func handleIncidents(ctx context.Context, dbPool *pgxpool.Pool, redisClient *redis.Client, in cycleInput, p incidentParams) {
+	open, err := loadOpenIncidents(ctx, dbPool)
+	if err != nil {
+		log.Printf("load open incidents failed: %v", err)
+		return
+	}
+
+	opened, updated, closed := reconcileIncidents(open, detectIncidents(in, p), in, p)
+
+	var changes []incident
+	for _, inc := range opened {
+		err := dbPool.QueryRow(ctx, `
+			INSERT INTO incidents (road_id, kind, severity, started_at, speed_kmh, baseline_speed_kmh, occupancy, baseline_occupancy)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			ON CONFLICT (road_id) WHERE ended_at IS NULL DO NOTHING
+			RETURNING id
+		`, inc.RoadID, inc.Kind, inc.Severity, inc.StartedAt, inc.SpeedKMH, inc.BaselineSpeedKMH, inc.Occupancy, inc.BaselineOccupancy).Scan(&inc.ID)
+		if err != nil {
+			// ErrNoRows: another replica opened it first.
+			log.Printf("insert incident for road=%s skipped: %v", inc.RoadID, err)
+			continue
+		}
+		incidentsOpened.WithLabelValues(inc.Severity).Inc()
+		changes = append(changes, inc)
+	}
+	for _, inc := range updated {
+		if _, err := dbPool.Exec(ctx, `
+			UPDATE incidents SET severity = $2, speed_kmh = $3, occupancy = $4, updated_at = NOW()
+			WHERE id = $1
+		`, inc.ID, inc.Severity, inc.SpeedKMH, inc.Occupancy); err != nil {
+			log.Printf("update incident %d failed: %v", inc.ID, err)
+			continue
+		}
+		changes = append(changes, inc)
+	}
+	for _, inc := range closed {
+		if _, err := dbPool.Exec(ctx, `
+			UPDATE incidents SET ended_at = $2, speed_kmh = $3, occupancy = $4, updated_at = NOW()
+			WHERE id = $1
+		`, inc.ID, inc.EndedAt, inc.SpeedKMH, inc.Occupancy); err != nil {
+			log.Printf("close incident %d failed: %v", inc.ID, err)
+			continue
+		}
+		changes = append(changes, inc)
+	}
+
+	owned := 0
+	for roadID := range open {
+		if in.Owns == nil || in.Owns(roadID) {
+			owned++
+		}
+	}
+	incidentsOpen.Set(float64(owned + len(opened) - len(closed)))
+
+	for _, inc := range changes {
+		log.Printf("incident %s: road=%s kind=%s severity=%s speed=%.1f baseline=%.1f",
+			inc.Event, inc.RoadID, inc.Kind, inc.Severity, inc.SpeedKMH, inc.BaselineSpeedKMH)
+		data, err := json.Marshal(inc)
+		if err != nil {
+			log.Printf("json marshal failed for incident %d: %v", inc.ID, err)
+			continue
+		}
+		if err := redisClient.Publish(ctx, "cityflow:incidents", data).Err(); err != nil {
+			log.Printf("redis publish incident failed: %v", err)
+		}
+	}
+}
+
+func loadOpenIncidents(ctx context.Context, dbPool *pgxpool.Pool) (map[string]incident, error) {
+	rows, err := dbPool.Query(ctx, `
+		SELECT id, road_id, kind, severity, started_at,
+			COALESCE(speed_kmh, 0), COALESCE(baseline_speed_kmh, 0),
+			COALESCE(occupancy, 0), COALESCE(baseline_occupancy, 0)
+		FROM incidents
+		WHERE ended_at IS NULL
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	open := make(map[string]incident)
+	for rows.Next() {
+		var inc incident
+		if err := rows.Scan(&inc.ID, &inc.RoadID, &inc.Kind, &inc.Severity, &inc.StartedAt,
+			&inc.SpeedKMH, &inc.BaselineSpeedKMH, &inc.Occupancy, &inc.BaselineOccupancy); err != nil {
+			return nil, err
+		}
+		open[inc.RoadID] = inc
+	}
+	return open, rows.Err()
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// collapsing returns six buckets at speed/occ whose last bucket is replaced.
+func collapsing(speed, occ, lastSpeed, lastOcc float64) []bucketData {
+	buckets := flatBuckets(speed, occ)
+	buckets[len(buckets)-1].avgSpeed = lastSpeed
+	buckets[len(buckets)-1].avgOcc = lastOcc
+	return buckets
+}
+
+func TestDetectIncidents(t *testing.T) {
+	in := cycleInput{
+		Now: time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC),
+		Buckets: map[string][]bucketData{
+			"CRASH":    collapsing(50, 0.2, 10, 0.7), // 80% drop, neighbours fine
+			"SLOW":     collapsing(50, 0.2, 20, 0.3), // 60% drop
+			"BLOCKED":  collapsing(40, 0.1, 38, 0.6), // occupancy only
+			"JAM-1":    collapsing(50, 0.2, 15, 0.5), // area-wide slowdown
+			"JAM-2":    collapsing(50, 0.2, 15, 0.5),
+			"CRAWL":    collapsing(15, 0.5, 5, 0.6), // already congested
+			"UP":       flatBuckets(50, 0.2),
+			"DOWN":     flatBuckets(50, 0.2),
+			"TOO-FEW":  flatBuckets(50, 0.2)[:2],
+			"NOT-MINE": collapsing(50, 0.2, 10, 0.7),
+		},
+		Graph: newRoadGraph([]roadLink{
+			{"UP", "CRASH"}, {"CRASH", "DOWN"},
+			{"JAM-1", "JAM-2"},
+		}),
+		cycleOptions: cycleOptions{Owns: func(roadID string) bool { return roadID != "NOT-MINE" }},
+	}
+
+	found := detectIncidents(in, defaultIncidentParams())
+	want := map[string]string{
+		"CRASH":   incidentSpeedDrop + "/major",
+		"SLOW":    incidentSpeedDrop + "/moderate",
+		"BLOCKED": incidentOccupancySpike + "/minor",
+	}
+	if len(found) != len(want) {
+		t.Errorf("found %d incidents, want %d: %+v", len(found), len(want), found)
+	}
+	for road, kind := range want {
+		inc, ok := found[road]
+		if !ok || inc.Kind+"/"+inc.Severity != kind {
+			t.Errorf("%s: got %+v, want %s", road, inc, kind)
+		}
+	}
+	if c := found["CRASH"]; c.BaselineSpeedKMH != 50 || c.SpeedKMH != 10 || !c.StartedAt.Equal(in.Now) {
+		t.Errorf("CRASH incident = %+v", c)
+	}
+}
+
+func TestReconcileIncidents(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	p := defaultIncidentParams()
+	open := map[string]incident{
+		"WORSE":     {ID: 1, RoadID: "WORSE", Kind: incidentSpeedDrop, Severity: "moderate", BaselineSpeedKMH: 50},
+		"ONGOING":   {ID: 2, RoadID: "ONGOING", Kind: incidentSpeedDrop, Severity: "major", BaselineSpeedKMH: 50},
+		"RECOVERED": {ID: 3, RoadID: "RECOVERED", Kind: incidentSpeedDrop, Severity: "major", BaselineSpeedKMH: 50},
+		"SILENT":    {ID: 4, RoadID: "SILENT", Kind: incidentSpeedDrop, Severity: "major", BaselineSpeedKMH: 50},
+		"OTHER":     {ID: 5, RoadID: "OTHER", Kind: incidentSpeedDrop, Severity: "major", BaselineSpeedKMH: 50},
+	}
+	detected := map[string]incident{
+		"NEW":   {RoadID: "NEW", Kind: incidentSpeedDrop, Severity: "moderate"},
+		"WORSE": {RoadID: "WORSE", Kind: incidentSpeedDrop, Severity: "major", SpeedKMH: 8},
+	}
+	in := cycleInput{
+		Now: now,
+		Buckets: map[string][]bucketData{
+			// Still slow: its own window no longer shows a drop, but speed is
+			// far below the baseline recorded at opening.
+			"ONGOING":   flatBuckets(12, 0.6),
+			"RECOVERED": flatBuckets(45, 0.2),
+		},
+		cycleOptions: cycleOptions{Owns: func(roadID string) bool { return roadID != "OTHER" }},
+	}
+
+	opened, updated, closed := reconcileIncidents(open, detected, in, p)
+	if len(opened) != 1 || opened[0].RoadID != "NEW" || opened[0].Event != "opened" {
+		t.Errorf("opened = %+v, want NEW", opened)
+	}
+	if len(updated) != 1 || updated[0].ID != 1 || updated[0].Severity != "major" || updated[0].SpeedKMH != 8 {
+		t.Errorf("updated = %+v, want WORSE escalated to major", updated)
+	}
+	closedIDs := map[int64]bool{}
+	for _, inc := range closed {
+		if inc.EndedAt == nil || !inc.EndedAt.Equal(now) || inc.Event != "closed" {
+			t.Errorf("closed incident %+v must end now", inc)
+		}
+		closedIDs[inc.ID] = true
+	}
+	if len(closed) != 2 || !closedIDs[3] || !closedIDs[4] {
+		t.Errorf("closed = %+v, want RECOVERED and SILENT", closed)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"hash/fnv"
+	"log"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// leaderElector lets one replica out of many run cycles. The leader
+// holds a session-level Postgres advisory lock on a dedicated connection;
+// Postgres drops the lock as soon as that session ends, so a standby polling
+// ensure() takes over within one retry interval of the leader dying.
+//
+// This file is kept identical in services/predictor and services/rerouter:
+// each service is its own module built from its own directory, and a shared
+// module for one small type is not worth the replace directives and wider
+// Docker build contexts. Change both copies together.
+type leaderElector struct {
+	pool  *pgxpool.Pool
+	key   int64
+	conn  *pgxpool.Conn
+	gauge prometheus.Gauge // optional
+}
+
+func newLeaderElector(pool *pgxpool.Pool, name string, gauge prometheus.Gauge) *leaderElector {
+	return &leaderElector{pool: pool, key: advisoryLockKey(name), gauge: gauge}
+}
+
+// advisoryLockKey derives a stable advisory lock key from a service name.
+func advisoryLockKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return int64(h.Sum64())
+}
+
+func (e *leaderElector) isLeader() bool {
+	return e.conn != nil
+}
+
+// ensure reports whether this replica is the leader, first checking that a
+// held lock's session is still alive and otherwise trying to acquire it.
+func (e *leaderElector) ensure(ctx context.Context) bool {
+	if e.conn != nil {
+		err := e.conn.Ping(ctx)
+		if err == nil {
+			return true
+		}
+		log.Printf("leader session lost, stepping down: %v", err)
+		e.conn.Release()
+		e.conn = nil
+		e.setGauge(0)
+	}
+
+	conn, err := e.pool.Acquire(ctx)
+	if err != nil {
+		log.Printf("leader election: acquire connection failed: %v", err)
+		return false
+	}
+
+	var acquired bool
+	if err := conn.QueryRow(ctx, `SELECT pg_try_advisory_lock($1)`, e.key).Scan(&acquired); err != nil {
+		log.Printf("leader election: try lock failed: %v", err)
+		conn.Release()
+		return false
+	}
+	if !acquired {
+		conn.Release()
+		return false
+	}
+
+	log.Printf("acquired leadership (advisory lock %d)", e.key)
+	e.conn = conn
+	e.setGauge(1)
+	return true
+}
+
+// release gives up leadership so a standby can take over without waiting for
+// the session to time out.
+func (e *leaderElector) release(ctx context.Context) {
+	if e.conn == nil {
+		return
+	}
+	if _, err := e.conn.Exec(ctx, `SELECT pg_advisory_unlock($1)`, e.key); err != nil {
+		log.Printf("leader election: unlock failed: %v", err)
+	}
+	e.conn.Release()
+	e.conn = nil
+	e.setGauge(0)
+}
+
+func (e *leaderElector) setGauge(v float64) {
+	if e.gauge != nil {
+		e.gauge.Set(v)
+	}
+}
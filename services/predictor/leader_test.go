@@ -0,0 +1,19 @@
+package main
+
+import "testing"
+
+func TestAdvisoryLockKey(t *testing.T) {
+	if advisoryLockKey("cityflow-predictor") != advisoryLockKey("cityflow-predictor") {
+		t.Error("lock key must be stable across replicas")
+	}
+	if advisoryLockKey("cityflow-predictor") == advisoryLockKey("cityflow-rerouter") {
+		t.Error("services must not share a lock key")
+	}
+}
+
+func TestLeaderElectorStartsAsStandby(t *testing.T) {
+	e := newLeaderElector(nil, "cityflow-predictor", leaderGauge)
+	if e.isLeader() {
+		t.Error("new elector should not be leader before ensure()")
+	}
+}
@@ -3,16 +3,24 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"math"
 	"net/http"
 	"os"
 	"os/signal"
+	"runtime"
+	"slices"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
@@ -25,24 +33,80 @@ const (
 	maxSpeed  = 90.0
 	maxFlow   = 120.0
 	ewmaAlpha = 0.7 // EWMA blending factor (higher = more weight on predicted)
+
+	z90                  = 1.2816 // standard normal quantile for P10/P90
+	minIntervalHalfWidth = 0.05   // floor for sensor noise when the fit is perfect
+	fallbackHalfWidth    = 0.2    // used when too few buckets to estimate residuals
+
+	pgUndefinedTable = "42P01" // SQLSTATE undefined_table
 )
 
+// rawBucketFallback records that traffic_5m is missing so the warning is logged once.
+var rawBucketFallback atomic.Bool
+
 type Prediction struct {
 	TS              time.Time `json:"ts"`
 	RoadID          string    `json:"road_id"`
 	HorizonMin      int       `json:"horizon_min"`
 	CongestionScore float64   `json:"congestion_score"`
+	CongestionP10   float64   `json:"congestion_p10"`
+	CongestionP90   float64   `json:"congestion_p90"`
 	Confidence      float64   `json:"confidence"`
 	ModelVersion    string    `json:"model_version"`
+	// Components explains the score; nil for rows written before it existed.
+	Components *predictionComponents `json:"components,omitempty"`
+}
+
+// modelParams holds the tunable knobs of the EWMA + linear regression model.
+type modelParams struct {
+	Version    string
+	Lookback   time.Duration
+	HorizonMin int
+	EWMAAlpha  float64
+	// Score normalisers for roads without a roadProfile.
+	MaxSpeed float64
+	MaxFlow  float64
+	// Weights of neighbour pressure added to the blended score.
+	SpatialUpstream   float64
+	SpatialDownstream float64
+	// City timezone for time-of-day features; nil means UTC.
+	Location *time.Location
+	// Weather multiplier: +RainPerMM per mm/h of rain up to +RainCap, plus
+	// LowVisibility in fog and Freezing for precipitation at or below 0°C.
+	RainPerMM     float64
+	RainCap       float64
+	LowVisibility float64
+	Freezing      float64
+}
+
+func defaultModelParams() modelParams {
+	return modelParams{
+		Version:    "ewma-lr-v2",
+		Lookback:   30 * time.Minute,
+		HorizonMin: 30,
+		EWMAAlpha:  ewmaAlpha,
+		MaxSpeed:   maxSpeed,
+		MaxFlow:    maxFlow,
+
+		SpatialUpstream:   0.3,
+		SpatialDownstream: 0.15,
+
+		RainPerMM:     0.04,
+		RainCap:       0.2,
+		LowVisibility: 0.05,
+		Freezing:      0.1,
+	}
 }
 
 // bucketData holds aggregated traffic metrics for a single time bucket + road.
 type bucketData struct {
+	ts        time.Time
 	offsetMin float64
 	avgSpeed  float64
 	avgOcc    float64
 	avgFlow   float64
 	samples   int64
+	imputed   string // imputation method, empty for observed buckets
 }
 
 var (
@@ -67,9 +131,20 @@ var (
 		Help:    "Duration of a full prediction cycle.",
 		Buckets: []float64{0.1, 0.5, 1.0, 2.5, 5.0, 10.0},
 	})
+	leaderGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "cityflow_predictor_is_leader",
+		Help: "1 if this replica holds the leader lock and runs prediction cycles.",
+	})
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "backtest" {
+		os.Exit(runBacktest(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "weather-import" {
+		os.Exit(runWeatherImport(os.Args[2:]))
+	}
+
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
@@ -77,11 +152,54 @@ func main() {
 	redisURL := getEnv("REDIS_URL", "redis://localhost:6379/0")
 	metricsAddr := getEnv("METRICS_ADDR", ":8080")
 	intervalSec := getEnvInt("PREDICTION_INTERVAL_SEC", 60)
+	leaderRetrySec := getEnvInt("LEADER_RETRY_SEC", 5)
+	if leaderRetrySec <= 0 {
+		log.Fatalf("invalid LEADER_RETRY_SEC=%d: must be > 0", leaderRetrySec)
+	}
+	leaderRetry := time.Duration(leaderRetrySec) * time.Second
+	workers := getEnvInt("PREDICTOR_WORKERS", runtime.NumCPU())
+	shardCount := getEnvInt("PREDICTOR_SHARDS", 1)
+	routingHorizons := getEnvInts("ROUTING_HORIZONS_MIN", []int{5, 15})
+	if shardCount < 1 {
+		shardCount = 1
+	}
+	maxOwnedShards, err := shardsPerReplica(shardCount, getEnvInt("PREDICTOR_REPLICAS", 0), getEnvInt("PREDICTOR_MAX_SHARDS_PER_REPLICA", 0))
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
 	lookbackMin := getEnvInt("LOOKBACK_WINDOW_MIN", 30)
-	horizonMin := getEnvInt("HORIZON_MIN", 30)
-	modelVersion := getEnv("MODEL_VERSION", "ewma-lr-v2")
+	driftIntervalMin := getEnvInt("DRIFT_CHECK_INTERVAL_MIN", 15)
+	if driftIntervalMin <= 0 {
+		log.Fatalf("invalid DRIFT_CHECK_INTERVAL_MIN=%d: must be > 0", driftIntervalMin)
+	}
+	driftInterval := time.Duration(driftIntervalMin) * time.Minute
+	weather, err := weatherProviderFromEnv()
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	weatherPollMin := getEnvInt("WEATHER_POLL_MIN", 10)
+	if weather != nil && weatherPollMin <= 0 {
+		log.Fatalf("invalid WEATHER_POLL_MIN=%d: must be > 0", weatherPollMin)
+	}
 
-	dbPool, err := pgxpool.New(ctx, dbDSN)
+	params := defaultModelParams()
+	params.Version = getEnv("MODEL_VERSION", params.Version)
+	params.Lookback = time.Duration(lookbackMin) * time.Minute
+	params.HorizonMin = getEnvInt("HORIZON_MIN", params.HorizonMin)
+	loc, err := cityLocation()
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	params.Location = loc
+
+	poolConfig, err := pgxpool.ParseConfig(dbDSN)
+	if err != nil {
+		log.Fatalf("invalid DB_DSN: %v", err)
+	}
+	// Each owned shard pins one connection for its advisory lock.
+	poolConfig.MaxConns += int32(maxOwnedShards)
+
+	dbPool, err := pgxpool.NewWithConfig(ctx, poolConfig)
 	if err != nil {
 		log.Fatalf("db pool init failed: %v", err)
 	}
@@ -104,23 +222,108 @@ func main() {
 	}
 	log.Printf("redis connected: %s", redisURL)
 
-	go serveHTTP(metricsAddr)
+	registry := newModelRegistry(params)
+	if err := registerBaseModel(ctx, dbPool, params); err != nil {
+		log.Printf("register model %s failed: %v", params.Version, err)
+	}
+	if err := registry.refresh(ctx, dbPool); err != nil {
+		log.Printf("model registry load failed: %v", err)
+	}
+
+	go serveHTTP(metricsAddr, newPredictHandler(dbPool, registry, workers))
 
 	interval := time.Duration(intervalSec) * time.Second
-	lookback := time.Duration(lookbackMin) * time.Minute
 
-	log.Printf("predictor running: interval=%s lookback=%s horizon=%dm model=%s",
-		interval, lookback, horizonMin, modelVersion)
+	champion, shadows := registry.current()
+	log.Printf("predictor running: interval=%s lookback=%s horizon=%dm model=%s shadows=%d workers=%d shards=%d tz=%s",
+		interval, champion.Lookback, champion.HorizonMin, champion.Version, len(shadows), workers, shardCount, params.Location)
+
+	shards := newShardSet(dbPool, "cityflow-predictor", shardCount, maxOwnedShards)
+	defer func() {
+		releaseCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		shards.release(releaseCtx)
+	}()
+
+	feed := &weatherFeed{}
+	cycle := func(owned []int) {
+		if err := registry.refresh(ctx, dbPool); err != nil {
+			log.Printf("model registry refresh failed: %v", err)
+		}
+		champion, shadows := registry.current()
+		runCycle(ctx, dbPool, redisClient, champion, shadows, cycleOptions{Workers: workers, Owns: shards.owns(owned), RoutingHorizons: routingHorizons, Weather: feed})
+	}
 
-	runCycle(ctx, dbPool, redisClient, lookback, horizonMin, modelVersion)
+	if owned := shards.ensure(ctx); len(owned) > 0 {
+		cycle(owned)
+	} else {
+		log.Printf("standing by: all shards owned by other replicas")
+	}
 
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
+	leaderTicker := time.NewTicker(leaderRetry)
+	defer leaderTicker.Stop()
+	drift := newDriftMonitor(defaultDriftParams())
+	var driftRunning atomic.Bool
+	driftTicker := time.NewTicker(driftInterval)
+	defer driftTicker.Stop()
+	// Without a provider the weather case never fires.
+	var weatherTick <-chan time.Time
+	if weather != nil {
+		weatherTicker := time.NewTicker(time.Duration(weatherPollMin) * time.Minute)
+		defer weatherTicker.Stop()
+		weatherTick = weatherTicker.C
+	}
+	// The provider call can take its full timeout, so it runs beside the
+	// cycles, which read the result from the feed; a slow poll skips a tick.
+	var weatherPolling atomic.Bool
+	pollWeather := func() {
+		if weather == nil || len(shards.owned()) == 0 || !weatherPolling.CompareAndSwap(false, true) {
+			return
+		}
+		go func() {
+			defer weatherPolling.Store(false)
+			// Weather is per zone, not per shard: every owner polls, the upsert is idempotent.
+			if n, err := ingestWeather(ctx, dbPool, weather, feed); err != nil {
+				log.Printf("weather ingestion from %s failed: %v", weather.Name(), err)
+			} else {
+				log.Printf("weather ingestion from %s: %d observations", weather.Name(), n)
+			}
+		}()
+	}
+	pollWeather()
 
 	for {
 		select {
 		case <-ticker.C:
-			runCycle(ctx, dbPool, redisClient, lookback, horizonMin, modelVersion)
+			if owned := shards.ensure(ctx); len(owned) > 0 {
+				cycle(owned)
+			}
+		case <-leaderTicker.C:
+			// Standbys poll the locks so failover does not wait a full interval.
+			before := len(shards.owned())
+			if owned := shards.ensure(ctx); len(owned) > before {
+				cycle(owned)
+			}
+		case <-weatherTick:
+			pollWeather()
+		case <-driftTicker.C:
+			// The checks scan weeks of aggregates, so they run beside the
+			// cycles rather than delaying them; a slow check skips a tick.
+			owned := shards.owned()
+			if len(owned) == 0 || !driftRunning.CompareAndSwap(false, true) {
+				continue
+			}
+			owns, scores := shards.owns(owned), slices.Contains(owned, 0)
+			go func() {
+				defer driftRunning.Store(false)
+				champion, shadows := registry.current()
+				drift.check(ctx, dbPool, redisClient, champion, owns)
+				if scores {
+					scoreModels(ctx, dbPool, champion, shadows, time.Now().UTC())
+				}
+			}()
 		case <-ctx.Done():
 			log.Printf("predictor shutting down")
 			return
@@ -128,39 +331,121 @@ func main() {
 	}
 }
 
-func runCycle(ctx context.Context, dbPool *pgxpool.Pool, redisClient *redis.Client, lookback time.Duration, horizonMin int, modelVersion string) {
+func runCycle(ctx context.Context, dbPool *pgxpool.Pool, redisClient *redis.Client, params modelParams, shadows []modelParams, opts cycleOptions) {
 	start := time.Now()
 	defer func() {
 		cycleDuration.Observe(time.Since(start).Seconds())
 	}()
 
 	now := time.Now().UTC().Truncate(time.Second)
-	windowStart := now.Add(-lookback)
 
-	// Query time-bucketed data using TimescaleDB time_bucket()
-	rows, err := dbPool.Query(ctx, `
-		SELECT
-			time_bucket('5 minutes', ts) AS bucket,
-			road_id,
-			AVG(speed_kmh)  AS avg_speed,
-			AVG(occupancy)  AS avg_occ,
-			AVG(flow_rate)  AS avg_flow,
-			COUNT(*)        AS samples
-		FROM traffic_raw
-		WHERE ts >= $1
-		GROUP BY bucket, road_id
-		ORDER BY road_id, bucket
-	`, windowStart)
+	in, err := loadCycleInput(ctx, dbPool, now, params)
 	if err != nil {
 		predictionsFailed.Inc()
-		log.Printf("query traffic_raw failed: %v", err)
+		log.Printf("query traffic buckets failed: %v", err)
+		return
+	}
+
+	if len(in.Buckets) == 0 {
+		log.Printf("no traffic data in lookback window, skipping")
+		return
+	}
+
+	in.cycleOptions = opts
+	in.Weather = opts.Weather.fill(in.Weather, now)
+	predictions := predictRoads(in, params)
+	predictionsGenerated.Add(float64(len(predictions)))
+	handleIncidents(ctx, dbPool, redisClient, in, defaultIncidentParams())
+
+	if len(predictions) == 0 {
+		log.Printf("no predictions generated")
 		return
 	}
+
+	stored := storePredictions(ctx, dbPool, predictions)
+	published := publishPredictions(ctx, redisClient, predictions)
+
+	log.Printf("prediction cycle [%s]: %d roads, %d stored, %d published (%.2fs)",
+		params.Version, len(predictions), stored, published, time.Since(start).Seconds())
+
+	storeRoutingHorizons(ctx, dbPool, in, params)
+
+	runShadows(ctx, dbPool, params, in, shadows)
+}
+
+// loadCycleInput reads the lookback window ending at now together with the
+// road graph and profiles. Only a failed bucket query is an error; the graph
+// and profiles degrade to empty with a log line.
+func loadCycleInput(ctx context.Context, dbPool *pgxpool.Pool, now time.Time, params modelParams) (cycleInput, error) {
+	in := cycleInput{Now: now}
+
+	var err error
+	in.Buckets, err = fetchRoadBuckets(ctx, dbPool, now.Add(-params.Lookback), now)
+	if err != nil {
+		return in, err
+	}
+
+	if in.Graph, err = loadRoadGraph(ctx, dbPool); err != nil {
+		log.Printf("load road graph failed, predicting without neighbours: %v", err)
+	}
+	if in.Profiles, err = loadRoadProfiles(ctx, dbPool); err != nil {
+		log.Printf("load road profiles failed, using global score limits: %v", err)
+	}
+	if factors, err := loadCapacityFactors(ctx, dbPool, now); err != nil {
+		log.Printf("load road restrictions failed, using full capacities: %v", err)
+	} else {
+		in.Profiles = withCapacityFactors(in.Profiles, factors)
+	}
+	if in.Seasonal, err = loadSeasonalBaseline(ctx, dbPool, now.Add(-params.Lookback), now, params.Location); err != nil {
+		log.Printf("load seasonal baseline failed, imputing from neighbours only: %v", err)
+	}
+	if in.RoadZones, err = loadRoadZones(ctx, dbPool); err != nil {
+		log.Printf("load road weather zones failed, using the default zone: %v", err)
+	}
+	if obs, err := loadWeather(ctx, dbPool, now.Add(-maxWeatherAge), now); err != nil {
+		log.Printf("load weather failed, predicting without it: %v", err)
+	} else {
+		in.Weather = pickWeather(obs, now)
+	}
+	return in, nil
+}
+
+// fetchRoadBuckets reads 5-minute buckets per road starting within [from, to)
+// from the traffic_5m continuous aggregate. Bucket offsets are relative to from.
+// Databases without the aggregate fall back to bucketing traffic_raw directly.
+func fetchRoadBuckets(ctx context.Context, dbPool *pgxpool.Pool, from, to time.Time) (map[string][]bucketData, error) {
+	rows, err := dbPool.Query(ctx, `
+		SELECT bucket, road_id, avg_speed, avg_occ, avg_flow, samples
+		FROM traffic_5m
+		WHERE bucket >= $1 AND bucket < $2
+		ORDER BY road_id, bucket
+	`, from, to)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgUndefinedTable {
+		if !rawBucketFallback.Swap(true) {
+			log.Printf("traffic_5m continuous aggregate missing, scanning traffic_raw instead")
+		}
+		rows, err = dbPool.Query(ctx, `
+			SELECT
+				time_bucket('5 minutes', ts) AS bucket,
+				road_id,
+				AVG(speed_kmh)  AS avg_speed,
+				AVG(occupancy)  AS avg_occ,
+				AVG(flow_rate)  AS avg_flow,
+				COUNT(*)        AS samples
+			FROM traffic_raw
+			WHERE ts >= $1 AND ts < $2
+			GROUP BY bucket, road_id
+			ORDER BY road_id, bucket
+		`, from, to)
+	}
+	if err != nil {
+		return nil, err
+	}
 	defer rows.Close()
 
 	// Group buckets by road
 	roadBuckets := make(map[string][]bucketData)
-	totalSamples := make(map[string]int64)
 
 	for rows.Next() {
 		var bucketTS time.Time
@@ -174,110 +459,260 @@ func runCycle(ctx context.Context, dbPool *pgxpool.Pool, redisClient *redis.Clie
 			continue
 		}
 
-		offsetMin := bucketTS.Sub(windowStart).Minutes()
 		roadBuckets[roadID] = append(roadBuckets[roadID], bucketData{
-			offsetMin: offsetMin,
+			ts:        bucketTS,
+			offsetMin: bucketTS.Sub(from).Minutes(),
 			avgSpeed:  avgSpeed,
 			avgOcc:    avgOcc,
 			avgFlow:   avgFlow,
 			samples:   samples,
 		})
-		totalSamples[roadID] += samples
 	}
-	if rows.Err() != nil {
-		predictionsFailed.Inc()
-		log.Printf("rows iteration error: %v", rows.Err())
-		return
-	}
-
-	if len(roadBuckets) == 0 {
-		log.Printf("no traffic data in lookback window, skipping")
-		return
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration: %w", err)
 	}
+	return roadBuckets, nil
+}
 
-	// Generate predictions per road
-	var predictions []Prediction
-	lbMin := lookback.Minutes()
-	futureOffset := lbMin + float64(horizonMin)
-	hour := now.Hour()
+// cycleOptions controls how a cycle is executed rather than what it predicts.
+type cycleOptions struct {
+	Workers int                      // goroutines forecasting roads; <= 1 runs inline
+	Owns    func(roadID string) bool // roads this replica predicts; nil means all
+	// RoutingHorizons are extra horizons, in minutes, stored for the
+	// rerouter's time-dependent routing but never published.
+	RoutingHorizons []int
+	// Weather holds the latest polled observations; nil uses only the
+	// stored ones.
+	Weather *weatherFeed
+}
 
-	for roadID, buckets := range roadBuckets {
-		if len(buckets) == 0 {
+// storeRoutingHorizons predicts and stores the routing horizons of the cycle
+// with the champion, on the same input as the main horizon.
+func storeRoutingHorizons(ctx context.Context, dbPool *pgxpool.Pool, in cycleInput, params modelParams) {
+	for _, h := range in.RoutingHorizons {
+		if h == params.HorizonMin {
 			continue
 		}
+		hp := params
+		hp.HorizonMin = h
+		predictions := predictRoads(in, hp)
+		stored := storePredictions(ctx, dbPool, predictions)
+		log.Printf("prediction cycle [%s]: horizon %dm for routing, %d stored", params.Version, h, stored)
+	}
+}
 
-		// Compute congestion score for each time bucket
-		xs := make([]float64, len(buckets))
-		ys := make([]float64, len(buckets))
-		for i, b := range buckets {
-			xs[i] = b.offsetMin
-			ys[i] = computeCongestionScore(b.avgSpeed, b.avgOcc, b.avgFlow)
-		}
-
-		currentScore := ys[len(ys)-1]
-
-		var finalScore float64
-		var trendStability float64
+// cycleInput is everything predictRoads needs besides the model parameters.
+type cycleInput struct {
+	Now      time.Time
+	Buckets  map[string][]bucketData
+	Graph    roadGraph
+	Profiles map[string]roadProfile
+	// Seasonal holds per-road hourly baselines keyed by hour (Unix seconds),
+	// used to impute gaps; see loadSeasonalBaseline.
+	Seasonal map[string]map[int64]bucketData
+	// Weather is the latest observation per zone and RoadZones maps roads to
+	// zones; roads missing from it use the default zone.
+	Weather   map[string]weatherObservation
+	RoadZones map[string]string
+	cycleOptions
+}
 
-		if len(buckets) >= 2 {
-			// Fit linear regression on the time-series of congestion scores
-			slope, intercept := fitLinearRegression(xs, ys)
+// roadState is a road's forecast from its own history, before neighbour and
+// time-of-day adjustments.
+type roadState struct {
+	current        float64 // score of the latest bucket
+	projected      float64 // regression extrapolation to now + horizon, clamped
+	blended        float64 // EWMA of projected and current
+	trendStability float64
+	halfWidth      float64 // P10/P90 half-width before the rush-hour factor
+	fitted         bool    // halfWidth comes from regression residuals
+	samples        int64
+	buckets        int
+	slope          float64   // regression slope, score per minute
+	latest         subscores // subscores of the latest bucket
+	imputed        int       // buckets filled in by fillGaps
+}
 
-			// Extrapolate to now + horizon
-			predicted := slope*futureOffset + intercept
+// imputedShare is the fraction of the road's buckets that were imputed.
+func (st roadState) imputedShare() float64 {
+	if st.buckets == 0 {
+		return 0
+	}
+	return float64(st.imputed) / float64(st.buckets)
+}
 
-			// EWMA blend: weight predicted vs current
-			blended := ewma(predicted, currentScore, ewmaAlpha)
+// predictRoads runs the EWMA + linear regression model over each road's
+// lookback buckets, nudged by neighbouring roads, and returns one prediction
+// per road at now + horizon.
+func predictRoads(in cycleInput, params modelParams) []Prediction {
+	futureOffset := params.Lookback.Minutes() + float64(params.HorizonMin)
+	rush := rushHourFactor(params.localHour(in.Now))
+
+	var owned []string
+	for roadID, buckets := range in.Buckets {
+		if len(buckets) > 0 && (in.Owns == nil || in.Owns(roadID)) {
+			owned = append(owned, roadID)
+		}
+	}
+	// Neighbours of owned roads may belong to other shards, so they get a
+	// state too; only owned roads get a prediction.
+	roadIDs := owned
+	if in.Owns != nil {
+		roadIDs = nil
+		for roadID := range in.Graph.neighbourhood(owned) {
+			if len(in.Buckets[roadID]) > 0 {
+				roadIDs = append(roadIDs, roadID)
+			}
+		}
+	}
+	stateList := make([]roadState, len(roadIDs))
+	parallelFor(len(roadIDs), in.Workers, func(i int) {
+		roadID := roadIDs[i]
+		stateList[i] = forecastRoad(in.fillGaps(roadID, params), futureOffset, params, in.profile(roadID, params))
+	})
+	states := make(map[string]roadState, len(roadIDs))
+	for i, roadID := range roadIDs {
+		states[roadID] = stateList[i]
+	}
 
-			// Rush hour adjustment
-			finalScore = blended * rushHourFactor(hour)
-			finalScore = math.Max(0.0, math.Min(1.0, finalScore))
+	predictions := make([]Prediction, len(owned))
+	parallelFor(len(owned), in.Workers, func(i int) {
+		roadID := owned[i]
+		st := states[roadID]
+		pressure := spatialPressure(roadID, states, in.Graph, params)
+		blended := st.blended + pressure
+
+		// Rush hour and weather adjustments
+		factor := rush
+		weather, hasWeather := in.weatherFor(roadID)
+		if hasWeather {
+			factor *= params.weatherFactor(weather)
+		}
+		finalScore := math.Max(0.0, math.Min(1.0, blended*factor))
 
-			// Trend stability: lower confidence when slope is steep (volatile data)
-			trendStability = math.Max(0.3, 1.0-math.Abs(slope)*10)
-		} else {
-			// Single bucket fallback
-			finalScore = currentScore * rushHourFactor(hour)
-			finalScore = math.Max(0.0, math.Min(1.0, finalScore))
-			trendStability = 0.5
+		halfWidth := st.halfWidth
+		if st.fitted {
+			halfWidth = math.Max(minIntervalHalfWidth, halfWidth*factor)
 		}
+		// Whatever its sensors report, a closed road lets no traffic through.
+		prof := in.profile(roadID, params)
+		if prof.Closed {
+			finalScore, halfWidth = 1, 0
+		}
+
+		sampleConfidence := math.Min(1.0, float64(st.samples)/50.0)
+		confidence := sampleConfidence * st.trendStability * (1 - imputationPenalty*st.imputedShare())
 
-		sampleConfidence := math.Min(1.0, float64(totalSamples[roadID])/50.0)
-		confidence := sampleConfidence * trendStability
+		p10 := math.Max(0.0, finalScore-halfWidth)
+		p90 := math.Min(1.0, finalScore+halfWidth)
 
-		predictions = append(predictions, Prediction{
-			TS:              now,
+		predictions[i] = Prediction{
+			TS:              in.Now,
 			RoadID:          roadID,
-			HorizonMin:      horizonMin,
+			HorizonMin:      params.HorizonMin,
 			CongestionScore: math.Round(finalScore*1000) / 1000,
+			CongestionP10:   math.Round(p10*1000) / 1000,
+			CongestionP90:   math.Round(p90*1000) / 1000,
 			Confidence:      math.Round(confidence*100) / 100,
-			ModelVersion:    modelVersion,
-		})
-		predictionsGenerated.Inc()
-	}
+			ModelVersion:    params.Version,
+			Components:      explain(st, prof, params, pressure, rush),
+		}
+		if hasWeather {
+			predictions[i].Components.WeatherFactor = round4(params.weatherFactor(weather))
+			predictions[i].Components.Weather = &weather
+		}
+	})
+	return predictions
+}
 
-	if len(predictions) == 0 {
-		log.Printf("no predictions generated")
+// parallelFor calls fn(i) for every i in [0, n) on a bounded pool of workers.
+// fn must only write to state owned by index i.
+func parallelFor(n, workers int, fn func(i int)) {
+	if workers <= 1 || n <= 1 {
+		for i := 0; i < n; i++ {
+			fn(i)
+		}
 		return
 	}
+	workers = min(workers, n)
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+}
 
-	stored := storePredictions(ctx, dbPool, predictions)
-	published := publishPredictions(ctx, redisClient, predictions)
+// forecastRoad fits the trend of one road's bucket scores and extrapolates it
+// to futureOffset minutes after the start of the lookback window.
+func forecastRoad(buckets []bucketData, futureOffset float64, params modelParams, prof roadProfile) roadState {
+	// Compute congestion score for each time bucket
+	xs := make([]float64, len(buckets))
+	ys := make([]float64, len(buckets))
+	var st roadState
+	for i, b := range buckets {
+		xs[i] = b.offsetMin
+		ys[i] = prof.score(b.avgSpeed, b.avgOcc, b.avgFlow)
+		st.samples += b.samples
+		if b.imputed != "" {
+			st.imputed++
+		}
+	}
 
-	log.Printf("prediction cycle [%s]: %d roads, %d stored, %d published (%.2fs)",
-		modelVersion, len(predictions), stored, published, time.Since(start).Seconds())
+	st.current = ys[len(ys)-1]
+	st.buckets = len(buckets)
+	last := buckets[len(buckets)-1]
+	st.latest = prof.subscores(last.avgSpeed, last.avgOcc, last.avgFlow)
+
+	if len(buckets) < 2 {
+		// Single bucket fallback
+		st.projected = st.current
+		st.blended = st.current
+		st.trendStability = 0.5
+		st.halfWidth = fallbackHalfWidth
+		return st
+	}
+
+	// Fit linear regression on the time-series of congestion scores
+	slope, intercept := fitLinearRegression(xs, ys)
+	st.slope = slope
+
+	// Extrapolate to now + horizon
+	predicted := slope*futureOffset + intercept
+	st.projected = math.Max(0.0, math.Min(1.0, predicted))
+
+	// EWMA blend: weight predicted vs current
+	st.blended = ewma(predicted, st.current, params.EWMAAlpha)
+
+	// Interval: residual forecast error, scaled like the blended term
+	st.halfWidth = fallbackHalfWidth
+	if se, ok := forecastStdErr(xs, ys, slope, intercept, futureOffset); ok {
+		st.halfWidth = z90 * se * params.EWMAAlpha
+		st.fitted = true
+	}
+
+	// Trend stability: lower confidence when slope is steep (volatile data)
+	st.trendStability = math.Max(0.3, 1.0-math.Abs(slope)*10)
+	return st
 }
 
 // ── ML Functions ──
 
-// computeCongestionScore computes a weighted congestion score from traffic metrics.
+// computeCongestionScore computes a weighted congestion score from traffic
+// metrics using the global limits; see roadProfile for per-road calibration.
 func computeCongestionScore(avgSpeed, avgOccupancy, avgFlow float64) float64 {
-	speedScore := 1.0 - (avgSpeed / maxSpeed)
-	occupancyScore := avgOccupancy
-	flowScore := avgFlow / maxFlow
-
-	score := 0.4*speedScore + 0.4*occupancyScore + 0.2*flowScore
-	return math.Max(0.0, math.Min(1.0, score))
+	return weightedScore(avgSpeed, avgOccupancy, avgFlow, maxSpeed, maxFlow, defaultWeights)
 }
 
 // ewma computes Exponential Weighted Moving Average.
@@ -285,7 +720,8 @@ func ewma(predicted, current, alpha float64) float64 {
 	return alpha*predicted + (1-alpha)*current
 }
 
-// rushHourFactor returns a time-of-day multiplier for congestion prediction.
+// rushHourFactor returns a time-of-day multiplier for congestion prediction,
+// given the local hour in the city timezone.
 func rushHourFactor(hour int) float64 {
 	switch {
 	case (hour >= 7 && hour < 9) || (hour >= 17 && hour < 19):
@@ -306,40 +742,109 @@ func fitLinearRegression(xs, ys []float64) (slope, intercept float64) {
 	return slope, intercept
 }
 
+// forecastStdErr returns the standard error of a linear-regression forecast at
+// x0, derived from the fit residuals. ok is false when fewer than three points
+// or no spread in xs leave the residual variance undefined.
+func forecastStdErr(xs, ys []float64, slope, intercept, x0 float64) (se float64, ok bool) {
+	n := len(xs)
+	if n < 3 {
+		return 0, false
+	}
+	meanX := stat.Mean(xs, nil)
+	var sse, sxx float64
+	for i := range xs {
+		r := ys[i] - (slope*xs[i] + intercept)
+		sse += r * r
+		d := xs[i] - meanX
+		sxx += d * d
+	}
+	if sxx == 0 {
+		return 0, false
+	}
+	s := math.Sqrt(sse / float64(n-2))
+	return s * math.Sqrt(1+1/float64(n)+(x0-meanX)*(x0-meanX)/sxx), true
+}
+
 // ── Storage & Publishing ──
 
+// storeBatchSize bounds how many upserts are pipelined in one round trip.
+const storeBatchSize = 500
+
+// execBatch pipelines one statement over rows and returns each row's error.
+// pgx runs a batch as one implicit transaction, so a single failing row rolls
+// back the whole batch; it is then retried row by row so only the bad rows
+// are lost.
+func execBatch(ctx context.Context, dbPool *pgxpool.Pool, sql string, rows [][]any) []error {
+	errs := make([]error, len(rows))
+	batch := &pgx.Batch{}
+	for _, args := range rows {
+		batch.Queue(sql, args...)
+	}
+	err := dbPool.SendBatch(ctx, batch).Close()
+	if err == nil {
+		return errs
+	}
+	log.Printf("db batch of %d rows failed, retrying row by row: %v", len(rows), err)
+	for i, args := range rows {
+		_, errs[i] = dbPool.Exec(ctx, sql, args...)
+	}
+	return errs
+}
+
 func storePredictions(ctx context.Context, dbPool *pgxpool.Pool, predictions []Prediction) int {
 	stored := 0
-	for _, p := range predictions {
-		_, err := dbPool.Exec(ctx, `
-			INSERT INTO predictions (ts, road_id, horizon_min, congestion_score, confidence, model_version)
-			VALUES ($1, $2, $3, $4, $5, $6)
+	for start := 0; start < len(predictions); start += storeBatchSize {
+		chunk := predictions[start:min(start+storeBatchSize, len(predictions))]
+
+		rows := make([][]any, len(chunk))
+		for i, p := range chunk {
+			rows[i] = []any{p.TS, p.RoadID, p.HorizonMin, p.CongestionScore, p.CongestionP10, p.CongestionP90, p.Confidence, p.ModelVersion, p.Components}
+		}
+		errs := execBatch(ctx, dbPool, `
+			INSERT INTO predictions (ts, road_id, horizon_min, congestion_score, congestion_p10, congestion_p90, confidence, model_version, components)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 			ON CONFLICT (ts, road_id, horizon_min) DO UPDATE SET
 				congestion_score = EXCLUDED.congestion_score,
+				congestion_p10 = EXCLUDED.congestion_p10,
+				congestion_p90 = EXCLUDED.congestion_p90,
 				confidence = EXCLUDED.confidence,
-				model_version = EXCLUDED.model_version
-		`, p.TS, p.RoadID, p.HorizonMin, p.CongestionScore, p.Confidence, p.ModelVersion)
-		if err != nil {
-			predictionsFailed.Inc()
-			log.Printf("db insert failed for road=%s: %v", p.RoadID, err)
-			continue
+				model_version = EXCLUDED.model_version,
+				components = EXCLUDED.components
+		`, rows)
+		for i, err := range errs {
+			if err != nil {
+				predictionsFailed.Inc()
+				log.Printf("db insert failed for road=%s: %v", chunk[i].RoadID, err)
+				continue
+			}
+			predictionsStored.Inc()
+			stored++
 		}
-		predictionsStored.Inc()
-		stored++
 	}
 	return stored
 }
 
 func publishPredictions(ctx context.Context, redisClient *redis.Client, predictions []Prediction) int {
-	published := 0
-	for _, p := range predictions {
+	pipe := redisClient.Pipeline()
+	cmds := make([]*redis.IntCmd, len(predictions))
+	for i, p := range predictions {
 		data, err := json.Marshal(p)
 		if err != nil {
 			log.Printf("json marshal failed for road=%s: %v", p.RoadID, err)
 			continue
 		}
-		if err := redisClient.Publish(ctx, "cityflow:predictions", data).Err(); err != nil {
-			log.Printf("redis publish failed for road=%s: %v", p.RoadID, err)
+		cmds[i] = pipe.Publish(ctx, "cityflow:predictions", data)
+	}
+	// Per-command errors are reported below.
+	_, _ = pipe.Exec(ctx)
+
+	published := 0
+	for i, cmd := range cmds {
+		if cmd == nil {
+			continue
+		}
+		if err := cmd.Err(); err != nil {
+			log.Printf("redis publish failed for road=%s: %v", predictions[i].RoadID, err)
 			continue
 		}
 		predictionsPublished.Inc()
@@ -348,9 +853,10 @@ func publishPredictions(ctx context.Context, redisClient *redis.Client, predicti
 	return published
 }
 
-func serveHTTP(addr string) {
+func serveHTTP(addr string, predict http.Handler) {
 	mux := http.NewServeMux()
 	mux.Handle("/metrics", promhttp.Handler())
+	mux.Handle("/predict", predict)
 	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		fmt.Fprint(w, "ok")
@@ -376,6 +882,24 @@ func getEnv(key, fallback string) string {
 	return value
 }
 
+// getEnvInts parses a comma-separated list of positive integers.
+func getEnvInts(key string, fallback []int) []int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	var ns []int
+	for _, field := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || n <= 0 {
+			log.Printf("invalid %s=%q, using default %v", key, value, fallback)
+			return fallback
+		}
+		ns = append(ns, n)
+	}
+	return ns
+}
+
 func getEnvInt(key string, fallback int) int {
 	value := os.Getenv(key)
 	if value == "" {
@@ -3,7 +3,9 @@ package main
 import (
 	"math"
 	"os"
+	"reflect"
 	"testing"
+	"time"
 )
 
 // ── computeCongestionScore tests (unchanged) ──
@@ -167,6 +169,62 @@ func TestFitLinearRegression(t *testing.T) {
 	})
 }
 
+// ── Prediction interval tests ──
+
+func TestForecastStdErr(t *testing.T) {
+	xs := []float64{0, 5, 10, 15, 20, 25}
+
+	t.Run("perfect fit has zero error", func(t *testing.T) {
+		ys := []float64{0.1, 0.2, 0.3, 0.4, 0.5, 0.6}
+		slope, intercept := fitLinearRegression(xs, ys)
+		se, ok := forecastStdErr(xs, ys, slope, intercept, 60)
+		if !ok || se > 1e-9 {
+			t.Errorf("se = %v, ok = %v, want ~0 and true", se, ok)
+		}
+	})
+
+	t.Run("noisy data widens with distance", func(t *testing.T) {
+		ys := []float64{0.3, 0.5, 0.35, 0.55, 0.4, 0.6}
+		slope, intercept := fitLinearRegression(xs, ys)
+		near, _ := forecastStdErr(xs, ys, slope, intercept, 30)
+		far, ok := forecastStdErr(xs, ys, slope, intercept, 90)
+		if !ok || near <= 0 || far <= near {
+			t.Errorf("near = %v, far = %v, want 0 < near < far", near, far)
+		}
+	})
+
+	t.Run("too few points", func(t *testing.T) {
+		if _, ok := forecastStdErr([]float64{0, 5}, []float64{0.2, 0.4}, 0.04, 0.2, 60); ok {
+			t.Error("expected ok = false for two points")
+		}
+	})
+}
+
+func TestPredictRoadsIntervals(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	noisy := []float64{50, 30, 55, 25, 45, 35}
+	var buckets []bucketData
+	for i, speed := range noisy {
+		buckets = append(buckets, bucketData{offsetMin: float64(i * 5), avgSpeed: speed, avgOcc: 0.3, avgFlow: 50, samples: 10})
+	}
+	roads := map[string][]bucketData{
+		"NOISY":  buckets,
+		"SINGLE": {{offsetMin: 25, avgSpeed: 40, avgOcc: 0.3, avgFlow: 50, samples: 10}},
+	}
+
+	for _, p := range predictRoads(cycleInput{Now: now, Buckets: roads}, defaultModelParams()) {
+		if p.CongestionP10 > p.CongestionScore || p.CongestionP90 < p.CongestionScore {
+			t.Errorf("%s: score %v outside [%v, %v]", p.RoadID, p.CongestionScore, p.CongestionP10, p.CongestionP90)
+		}
+		if p.CongestionP10 < 0 || p.CongestionP90 > 1 {
+			t.Errorf("%s: interval [%v, %v] not clamped", p.RoadID, p.CongestionP10, p.CongestionP90)
+		}
+		if width := p.CongestionP90 - p.CongestionP10; width < 2*minIntervalHalfWidth-0.001 {
+			t.Errorf("%s: interval width %v below floor", p.RoadID, width)
+		}
+	}
+}
+
 // ── End-to-end prediction pipeline test ──
 
 func TestPredictionPipeline(t *testing.T) {
@@ -261,3 +319,15 @@ func TestGetEnvInt(t *testing.T) {
 		t.Errorf("getEnvInt() = %d, want %d", got, 100)
 	}
 }
+
+func TestGetEnvInts(t *testing.T) {
+	os.Setenv("TEST_INTS_VAR", "5, 15")
+	defer os.Unsetenv("TEST_INTS_VAR")
+	if got := getEnvInts("TEST_INTS_VAR", nil); !reflect.DeepEqual(got, []int{5, 15}) {
+		t.Errorf("getEnvInts() = %v, want [5 15]", got)
+	}
+	os.Setenv("TEST_INTS_VAR", "5,x")
+	if got := getEnvInts("TEST_INTS_VAR", []int{30}); !reflect.DeepEqual(got, []int{30}) {
+		t.Errorf("getEnvInts() with invalid should return fallback, got %v", got)
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	maxOnDemandRoads   = 1000
+	maxOnDemandHorizon = 240
+	onDemandTimeout    = 10 * time.Second
+)
+
+var onDemandRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "cityflow_predictor_on_demand_requests_total",
+	Help: "Total number of POST /predict requests by outcome.",
+}, []string{"status"})
+
+type predictRequest struct {
+	RoadIDs    []string   `json:"road_ids"`    // empty means every road with data
+	HorizonMin int        `json:"horizon_min"` // defaults to the configured horizon
+	AsOf       *time.Time `json:"as_of"`       // defaults to now
+	// Persist is rejected: only the shard owners' cycles write predictions,
+	// and any replica may serve this request.
+	Persist bool `json:"persist"`
+}
+
+type predictResponse struct {
+	AsOf         time.Time    `json:"as_of"`
+	ModelVersion string       `json:"model_version"`
+	Predictions  []Prediction `json:"predictions"`
+}
+
+// predictHandler serves POST /predict: a synchronous prediction with the
+// champion model, optionally for a past instant. Nothing is written. A
+// replica computes one request at a time and turns others away with 429, so
+// that repeated full-network requests cannot starve its cycles.
+type predictHandler struct {
+	params  func() modelParams // current champion
+	workers int
+	load    func(ctx context.Context, now time.Time, params modelParams) (cycleInput, error)
+	busy    chan struct{}
+}
+
+func newPredictHandler(dbPool *pgxpool.Pool, registry *modelRegistry, workers int) *predictHandler {
+	return &predictHandler{
+		params: func() modelParams {
+			champion, _ := registry.current()
+			return champion
+		},
+		workers: workers,
+		load: func(ctx context.Context, now time.Time, params modelParams) (cycleInput, error) {
+			return loadCycleInput(ctx, dbPool, now, params)
+		},
+		busy: make(chan struct{}, 1),
+	}
+}
+
+func (h *predictHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	var req predictRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+			h.fail(w, http.StatusBadRequest, "invalid JSON body")
+			return
+		}
+	}
+
+	params := h.params()
+	if req.HorizonMin != 0 {
+		if req.HorizonMin < 0 || req.HorizonMin > maxOnDemandHorizon {
+			h.fail(w, http.StatusBadRequest, "horizon_min must be between 1 and 240")
+			return
+		}
+		params.HorizonMin = req.HorizonMin
+	}
+	if len(req.RoadIDs) > maxOnDemandRoads {
+		h.fail(w, http.StatusBadRequest, "too many road_ids (max 1000)")
+		return
+	}
+
+	if req.Persist {
+		h.fail(w, http.StatusBadRequest, "persist is not supported: predictions are written by the prediction cycle")
+		return
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	if req.AsOf != nil {
+		if req.AsOf.After(now) {
+			h.fail(w, http.StatusBadRequest, "as_of must not be in the future")
+			return
+		}
+		now = req.AsOf.UTC().Truncate(time.Second)
+	}
+
+	select {
+	case h.busy <- struct{}{}:
+		defer func() { <-h.busy }()
+	default:
+		h.fail(w, http.StatusTooManyRequests, "an on-demand prediction is already running, retry later")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), onDemandTimeout)
+	defer cancel()
+
+	in, err := h.load(ctx, now, params)
+	if err != nil {
+		log.Printf("on-demand prediction: load failed: %v", err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, context.DeadlineExceeded) {
+			status = http.StatusGatewayTimeout
+		}
+		h.fail(w, status, "failed to load traffic data")
+		return
+	}
+
+	in.Workers = h.workers
+	if len(req.RoadIDs) > 0 {
+		wanted := make(map[string]bool, len(req.RoadIDs))
+		for _, id := range req.RoadIDs {
+			wanted[id] = true
+		}
+		in.Owns = func(roadID string) bool { return wanted[roadID] }
+	}
+
+	resp := predictResponse{AsOf: now, ModelVersion: params.Version, Predictions: predictRoads(in, params)}
+
+	onDemandRequests.WithLabelValues("ok").Inc()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (h *predictHandler) fail(w http.ResponseWriter, status int, msg string) {
+	onDemandRequests.WithLabelValues(http.StatusText(status)).Inc()
+	writeJSONError(w, status, msg)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestPredictHandler(loadedAt *time.Time) *predictHandler {
+	return &predictHandler{
+		params:  defaultModelParams,
+		workers: 2,
+		load: func(_ context.Context, now time.Time, _ modelParams) (cycleInput, error) {
+			*loadedAt = now
+			return cycleInput{Now: now, Buckets: map[string][]bucketData{
+				"A": flatBuckets(20, 0.6),
+				"B": flatBuckets(60, 0.1),
+			}}, nil
+		},
+		busy: make(chan struct{}, 1),
+	}
+}
+
+func TestPredictHandlerFiltersRoadsAndHorizon(t *testing.T) {
+	var loadedAt time.Time
+	h := newTestPredictHandler(&loadedAt)
+
+	asOf := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	body := `{"road_ids":["B"],"horizon_min":15,"as_of":"` + asOf.Format(time.RFC3339) + `"}`
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/predict", strings.NewReader(body)))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	var resp predictResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if !loadedAt.Equal(asOf) || !resp.AsOf.Equal(asOf) {
+		t.Errorf("as_of = %v (loaded %v), want %v", resp.AsOf, loadedAt, asOf)
+	}
+	if len(resp.Predictions) != 1 || resp.Predictions[0].RoadID != "B" || resp.Predictions[0].HorizonMin != 15 {
+		t.Fatalf("unexpected predictions: %+v", resp.Predictions)
+	}
+	if !resp.Predictions[0].TS.Equal(asOf) {
+		t.Errorf("ts = %v, want %v", resp.Predictions[0].TS, asOf)
+	}
+}
+
+func TestPredictHandlerOneAtATime(t *testing.T) {
+	var loadedAt time.Time
+	h := newTestPredictHandler(&loadedAt)
+
+	h.busy <- struct{}{}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/predict", nil))
+	if rec.Code != http.StatusTooManyRequests || !loadedAt.IsZero() {
+		t.Errorf("status = %d while busy, want 429 without loading", rec.Code)
+	}
+
+	<-h.busy
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/predict", nil))
+	if rec.Code != http.StatusOK || len(h.busy) != 0 {
+		t.Errorf("status = %d once free, want 200 and the slot released", rec.Code)
+	}
+}
+
+func TestPredictHandlerRejectsBadRequests(t *testing.T) {
+	var loadedAt time.Time
+	h := newTestPredictHandler(&loadedAt)
+	future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	past := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+
+	cases := []struct {
+		name   string
+		method string
+		body   string
+		want   int
+	}{
+		{"wrong method", http.MethodGet, "", http.StatusMethodNotAllowed},
+		{"invalid JSON", http.MethodPost, "{", http.StatusBadRequest},
+		{"horizon too large", http.MethodPost, `{"horizon_min":500}`, http.StatusBadRequest},
+		{"negative horizon", http.MethodPost, `{"horizon_min":-5}`, http.StatusBadRequest},
+		{"future as_of", http.MethodPost, `{"as_of":"` + future + `"}`, http.StatusBadRequest},
+		{"persist", http.MethodPost, `{"persist":true}`, http.StatusBadRequest},
+		{"persist with as_of", http.MethodPost, `{"persist":true,"as_of":"` + past + `"}`, http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(tc.method, "/predict", strings.NewReader(tc.body)))
+			if rec.Code != tc.want {
+				t.Errorf("status = %d, want %d", rec.Code, tc.want)
+			}
+		})
+	}
+	if !loadedAt.IsZero() {
+		t.Error("rejected requests must not load anything")
+	}
+}
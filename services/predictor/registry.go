@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Statuses of the models table.
+const (
+	modelShadow   = "shadow"
+	modelChampion = "champion"
+	modelRetired  = "retired"
+)
+
+// accuracyWindow is how far back registered models are scored.
+const accuracyWindow = 24 * time.Hour
+
+var (
+	shadowPredictionsStored = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cityflow_predictor_shadow_predictions_stored_total",
+		Help: "Total number of shadow model predictions written to shadow_predictions.",
+	}, []string{"model_version"})
+	modelMAE = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cityflow_predictor_model_mae",
+		Help: "Mean absolute error of each registered model over the last 24 hours.",
+	}, []string{"model_version", "status"})
+)
+
+// registeredModel is a row of the models table. Params overrides the
+// deployment defaults with model spec keys, see setModelParam.
+type registeredModel struct {
+	Version string
+	Status  string
+	Params  map[string]any
+}
+
+// modelRegistry holds the champion and shadow models of the models table.
+// base carries the deployment settings (MODEL_VERSION, lookback, horizon,
+// timezone); it stays champion until one is registered.
+type modelRegistry struct {
+	base modelParams
+
+	mu       sync.RWMutex
+	champion modelParams
+	shadows  []modelParams
+}
+
+func newModelRegistry(base modelParams) *modelRegistry {
+	return &modelRegistry{base: base, champion: base}
+}
+
+func (r *modelRegistry) current() (modelParams, []modelParams) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.champion, r.shadows
+}
+
+// refresh reloads the models table so that a promotion takes effect on the
+// next cycle. If the table cannot be read the previous models are kept.
+func (r *modelRegistry) refresh(ctx context.Context, dbPool *pgxpool.Pool) error {
+	rows, err := loadModels(ctx, dbPool)
+	if err != nil {
+		return err
+	}
+	champion, shadows, err := resolveModels(r.base, rows)
+
+	r.mu.Lock()
+	r.champion, r.shadows = champion, shadows
+	r.mu.Unlock()
+	return err
+}
+
+// resolveModels builds the parameters of the champion and shadow models.
+// Rows with invalid params are skipped and reported in the error.
+func resolveModels(base modelParams, rows []registeredModel) (modelParams, []modelParams, error) {
+	champion := base
+	var shadows []modelParams
+	var errs []error
+	for _, m := range rows {
+		if m.Status == modelRetired {
+			continue
+		}
+		p, err := applyModelParams(base, m)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		switch m.Status {
+		case modelChampion:
+			champion = p
+		case modelShadow:
+			shadows = append(shadows, p)
+		}
+	}
+	return champion, shadows, errors.Join(errs...)
+}
+
+func applyModelParams(base modelParams, m registeredModel) (modelParams, error) {
+	p := base
+	p.Version = m.Version
+	keys := make([]string, 0, len(m.Params))
+	for k := range m.Params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := setModelParam(&p, k, fmt.Sprint(m.Params[k])); err != nil {
+			return p, fmt.Errorf("model %s: %w", m.Version, err)
+		}
+	}
+	return p, nil
+}
+
+func loadModels(ctx context.Context, dbPool *pgxpool.Pool) ([]registeredModel, error) {
+	rows, err := dbPool.Query(ctx, `
+		SELECT version, status, params
+		FROM models
+		WHERE status <> 'retired'
+		ORDER BY version
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var models []registeredModel
+	for rows.Next() {
+		var m registeredModel
+		if err := rows.Scan(&m.Version, &m.Status, &m.Params); err != nil {
+			return nil, err
+		}
+		models = append(models, m)
+	}
+	return models, rows.Err()
+}
+
+// registerBaseModel records the configured MODEL_VERSION as champion when the
+// registry has none yet, so a fresh deployment starts with a champion. Once a
+// champion exists the registry wins: a changed MODEL_VERSION is not applied
+// and only logged, since models change through the admin promotion.
+func registerBaseModel(ctx context.Context, dbPool *pgxpool.Pool, base modelParams) error {
+	if _, err := dbPool.Exec(ctx, `
+		INSERT INTO models (version, status, promoted_at)
+		VALUES ($1, 'champion', NOW())
+		ON CONFLICT DO NOTHING
+	`, base.Version); err != nil {
+		return err
+	}
+	var champion string
+	err := dbPool.QueryRow(ctx, `SELECT version FROM models WHERE status = 'champion'`).Scan(&champion)
+	if err != nil {
+		return err
+	}
+	if champion != base.Version {
+		log.Printf("MODEL_VERSION=%s ignored: the registry champion is %s; register %s as a shadow model and promote it to switch",
+			base.Version, champion, base.Version)
+	}
+	return nil
+}
+
+// runShadows predicts with every shadow model on the champion's cycle input
+// and stores the results in shadow_predictions only: shadow outputs never
+// reach Redis, the rerouter or the dashboard. A shadow with a different
+// lookback window loads its own input.
+func runShadows(ctx context.Context, dbPool *pgxpool.Pool, champion modelParams, in cycleInput, shadows []modelParams) {
+	for _, s := range shadows {
+		start := time.Now()
+		sin := in
+		if s.Lookback != champion.Lookback {
+			loaded, err := loadCycleInput(ctx, dbPool, in.Now, s)
+			if err != nil {
+				log.Printf("shadow cycle [%s]: load input failed: %v", s.Version, err)
+				continue
+			}
+			loaded.cycleOptions = in.cycleOptions
+			loaded.Weather = in.Weather
+			sin = loaded
+		}
+
+		predictions := predictRoads(sin, s)
+		stored := storeShadowPredictions(ctx, dbPool, predictions)
+		log.Printf("shadow cycle [%s]: %d roads, %d stored (%.2fs)",
+			s.Version, len(predictions), stored, time.Since(start).Seconds())
+	}
+}
+
+func storeShadowPredictions(ctx context.Context, dbPool *pgxpool.Pool, predictions []Prediction) int {
+	stored := 0
+	for start := 0; start < len(predictions); start += storeBatchSize {
+		chunk := predictions[start:min(start+storeBatchSize, len(predictions))]
+
+		rows := make([][]any, len(chunk))
+		for i, p := range chunk {
+			rows[i] = []any{p.TS, p.RoadID, p.HorizonMin, p.ModelVersion, p.CongestionScore, p.CongestionP10, p.CongestionP90, p.Confidence, p.Components}
+		}
+		errs := execBatch(ctx, dbPool, `
+			INSERT INTO shadow_predictions (ts, road_id, horizon_min, model_version, congestion_score, congestion_p10, congestion_p90, confidence, components)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			ON CONFLICT (ts, road_id, horizon_min, model_version) DO UPDATE SET
+				congestion_score = EXCLUDED.congestion_score,
+				congestion_p10 = EXCLUDED.congestion_p10,
+				congestion_p90 = EXCLUDED.congestion_p90,
+				confidence = EXCLUDED.confidence,
+				components = EXCLUDED.components
+		`, rows)
+		for i, err := range errs {
+			if err != nil {
+				log.Printf("shadow insert failed for model=%s road=%s: %v", chunk[i].ModelVersion, chunk[i].RoadID, err)
+				continue
+			}
+			shadowPredictionsStored.WithLabelValues(chunk[i].ModelVersion).Inc()
+			stored++
+		}
+	}
+	return stored
+}
+
+// scoreModels writes to the models table the MAE of the champion and of each
+// shadow model over the last accuracyWindow. The admin promotion guard
+// compares these scores. It scores every road, so only the owner of shard 0
+// runs it.
+func scoreModels(ctx context.Context, dbPool *pgxpool.Pool, champion modelParams, shadows []modelParams, now time.Time) {
+	profiles, err := loadRoadProfiles(ctx, dbPool)
+	if err != nil {
+		log.Printf("model scoring: load road profiles failed, using fallback: %v", err)
+	}
+	static := cycleInput{Profiles: profiles}
+
+	type scored struct {
+		params modelParams
+		status string
+		table  string
+	}
+	todo := []scored{{champion, modelChampion, "predictions"}}
+	for _, s := range shadows {
+		todo = append(todo, scored{s, modelShadow, "shadow_predictions"})
+	}
+
+	for _, m := range todo {
+		samples, err := loadModelErrors(ctx, dbPool, m.table, m.params, static, now)
+		if err != nil {
+			log.Printf("model scoring [%s]: load errors failed: %v", m.params.Version, err)
+			continue
+		}
+		var stats maeStats
+		for _, s := range samples {
+			stats.add(s.Predicted - s.Actual)
+		}
+		if stats.n == 0 {
+			continue
+		}
+		if _, err := dbPool.Exec(ctx, `
+			UPDATE models SET mae = $2, evaluated = $3, evaluated_at = $4, updated_at = NOW()
+			WHERE version = $1
+		`, m.params.Version, stats.mae(), stats.n, now); err != nil {
+			log.Printf("model scoring [%s]: update failed: %v", m.params.Version, err)
+			continue
+		}
+		modelMAE.WithLabelValues(m.params.Version, m.status).Set(stats.mae())
+	}
+}
+
+// loadModelErrors pairs a model's realised predictions of the last
+// accuracyWindow with the observed score, one per road and 15 minutes. table
+// is predictions or shadow_predictions.
+func loadModelErrors(ctx context.Context, dbPool *pgxpool.Pool, table string, params modelParams, static cycleInput, now time.Time) ([]errorSample, error) {
+	to := now.Add(-time.Duration(params.HorizonMin)*time.Minute - bucketWidth)
+
+	rows, err := dbPool.Query(ctx, fmt.Sprintf(`
+		SELECT DISTINCT ON (p.road_id, time_bucket('15 minutes', p.ts))
+			p.road_id, p.congestion_score, t.avg_speed, t.avg_occ, t.avg_flow
+		FROM %s p
+		JOIN traffic_5m t ON t.road_id = p.road_id
+			AND t.bucket = time_bucket('5 minutes', p.ts + make_interval(mins => p.horizon_min))
+		WHERE p.model_version = $1 AND p.horizon_min = $2
+			AND p.ts >= $3 AND p.ts < $4
+		ORDER BY p.road_id, time_bucket('15 minutes', p.ts), p.ts
+	`, pgx.Identifier{table}.Sanitize()), params.Version, params.HorizonMin, to.Add(-accuracyWindow), to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var samples []errorSample
+	for rows.Next() {
+		var s errorSample
+		var speed, occ, flow float64
+		if err := rows.Scan(&s.RoadID, &s.Predicted, &speed, &occ, &flow); err != nil {
+			return nil, err
+		}
+		s.Actual = static.actualScore(s.RoadID, speed, occ, flow)
+		samples = append(samples, s)
+	}
+	return samples, rows.Err()
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestResolveModels(t *testing.T) {
+	base := defaultModelParams()
+	base.Version = "configured"
+	base.Lookback = 45 * time.Minute
+
+	var params map[string]any
+	if err := json.Unmarshal([]byte(`{"alpha":0.5,"lookback":"1h","horizon":15}`), &params); err != nil {
+		t.Fatal(err)
+	}
+	champion, shadows, err := resolveModels(base, []registeredModel{
+		{Version: "ewma-lr-v2", Status: modelChampion},
+		{Version: "tuned", Status: modelShadow, Params: params},
+		{Version: "old", Status: modelRetired},
+		{Version: "broken", Status: modelShadow, Params: map[string]any{"gamma": 1}},
+	})
+	if err == nil {
+		t.Error("expected an error for the unknown param of model broken")
+	}
+
+	if champion.Version != "ewma-lr-v2" || champion.Lookback != 45*time.Minute {
+		t.Errorf("champion = %s lookback %s, want ewma-lr-v2 with the configured lookback", champion.Version, champion.Lookback)
+	}
+	if len(shadows) != 1 {
+		t.Fatalf("got %d shadows, want only tuned", len(shadows))
+	}
+	s := shadows[0]
+	if s.Version != "tuned" || s.EWMAAlpha != 0.5 || s.Lookback != time.Hour || s.HorizonMin != 15 {
+		t.Errorf("shadow = %+v", s)
+	}
+	if s.MaxSpeed != base.MaxSpeed {
+		t.Errorf("shadow max speed = %v, want the default %v", s.MaxSpeed, base.MaxSpeed)
+	}
+}
+
+func TestResolveModelsWithoutChampionKeepsBase(t *testing.T) {
+	base := defaultModelParams()
+	champion, shadows, err := resolveModels(base, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if champion.Version != base.Version || len(shadows) != 0 {
+		t.Errorf("champion = %s, shadows = %d; want base and none", champion.Version, len(shadows))
+	}
+}
+
+func TestModelRegistryCurrent(t *testing.T) {
+	base := defaultModelParams()
+	r := newModelRegistry(base)
+	champion, shadows := r.current()
+	if champion.Version != base.Version || shadows != nil {
+		t.Errorf("fresh registry = %s, %v; want the base model alone", champion.Version, shadows)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var ownedShardsGauge = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "cityflow_predictor_owned_shards",
+	Help: "Number of road shards this replica currently predicts.",
+})
+
+// shardOf maps a road to one of n shards with jump consistent hashing
+// (Lamping & Veach), so changing n only moves about 1/n of the roads.
+func shardOf(roadID string, n int) int {
+	if n <= 1 {
+		return 0
+	}
+	h := fnv.New64a()
+	h.Write([]byte(roadID))
+	key := h.Sum64()
+
+	var b, j int64 = -1, 0
+	for j < int64(n) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
+
+// shardSet tracks which road shards this replica owns. Every shard has its
+// own advisory lock, so replicas split the shards between them and a dead
+// replica's shards fail over independently. With a single shard this is
+// plain leader election.
+type shardSet struct {
+	electors []*leaderElector
+	maxOwned int
+}
+
+func newShardSet(pool *pgxpool.Pool, name string, count, maxOwned int) *shardSet {
+	if count < 1 {
+		count = 1
+	}
+	if maxOwned < 1 || maxOwned > count {
+		maxOwned = count
+	}
+	s := &shardSet{electors: make([]*leaderElector, count), maxOwned: maxOwned}
+	for i := range s.electors {
+		lockName := name
+		if count > 1 {
+			lockName = fmt.Sprintf("%s/shard-%d", name, i)
+		}
+		s.electors[i] = newLeaderElector(pool, lockName, nil)
+	}
+	return s
+}
+
+// shardsPerReplica resolves how many shards one replica may hold. Unless
+// set explicitly, the shards are split evenly over the expected replicas,
+// rounded up; without either the first replica to start would take them all.
+func shardsPerReplica(shards, replicas, explicit int) (int, error) {
+	if explicit > 0 {
+		return min(explicit, shards), nil
+	}
+	if shards <= 1 {
+		return 1, nil
+	}
+	if replicas < 1 {
+		return 0, fmt.Errorf("PREDICTOR_SHARDS=%d needs PREDICTOR_REPLICAS or PREDICTOR_MAX_SHARDS_PER_REPLICA", shards)
+	}
+	return (shards + replicas - 1) / replicas, nil
+}
+
+func (s *shardSet) count() int {
+	return len(s.electors)
+}
+
+// owned returns the shards currently held, without touching the database.
+func (s *shardSet) owned() []int {
+	var shards []int
+	for i, e := range s.electors {
+		if e.isLeader() {
+			shards = append(shards, i)
+		}
+	}
+	return shards
+}
+
+// ensure re-checks the shards already held, then tries to pick up free ones
+// until maxOwned is reached. It returns the shards owned afterwards.
+func (s *shardSet) ensure(ctx context.Context) []int {
+	held := 0
+	for _, e := range s.electors {
+		if e.isLeader() && e.ensure(ctx) {
+			held++
+		}
+	}
+	for _, e := range s.electors {
+		if held >= s.maxOwned {
+			break
+		}
+		if !e.isLeader() && e.ensure(ctx) {
+			held++
+		}
+	}
+
+	shards := s.owned()
+	ownedShardsGauge.Set(float64(len(shards)))
+	if len(shards) > 0 {
+		leaderGauge.Set(1)
+	} else {
+		leaderGauge.Set(0)
+	}
+	return shards
+}
+
+func (s *shardSet) release(ctx context.Context) {
+	for _, e := range s.electors {
+		e.release(ctx)
+	}
+	ownedShardsGauge.Set(0)
+	leaderGauge.Set(0)
+}
+
+// owns returns a road filter for the given shards, or nil (every road) when
+// the network is not sharded.
+func (s *shardSet) owns(shards []int) func(roadID string) bool {
+	n := s.count()
+	if n == 1 {
+		return nil
+	}
+	set := make(map[int]bool, len(shards))
+	for _, sh := range shards {
+		set[sh] = true
+	}
+	return func(roadID string) bool {
+		return set[shardOf(roadID, n)]
+	}
+}
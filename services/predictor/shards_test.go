@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestShardOfRangeAndBalance(t *testing.T) {
+	const n = 4
+	counts := make([]int, n)
+	for i := 0; i < 4000; i++ {
+		s := shardOf(fmt.Sprintf("PARIS-%d", i), n)
+		if s < 0 || s >= n {
+			t.Fatalf("shardOf returned %d, want [0, %d)", s, n)
+		}
+		counts[s]++
+	}
+	for s, c := range counts {
+		if c < 800 || c > 1200 {
+			t.Errorf("shard %d got %d of 4000 roads, want ~1000", s, c)
+		}
+	}
+	if shardOf("PARIS-1", 1) != 0 || shardOf("PARIS-1", 0) != 0 {
+		t.Error("single shard must own every road")
+	}
+}
+
+func TestShardOfIsConsistent(t *testing.T) {
+	moved := 0
+	for i := 0; i < 4000; i++ {
+		road := fmt.Sprintf("PARIS-%d", i)
+		before, after := shardOf(road, 4), shardOf(road, 5)
+		if before != after {
+			if after != 4 {
+				t.Fatalf("%s moved from shard %d to %d; roads may only move to the new shard", road, before, after)
+			}
+			moved++
+		}
+	}
+	// About 1/5 of the roads should move to the new shard.
+	if moved < 600 || moved > 1000 {
+		t.Errorf("%d of 4000 roads moved, want ~800", moved)
+	}
+}
+
+func TestShardSetOwns(t *testing.T) {
+	if newShardSet(nil, "cityflow-predictor", 1, 1).owns([]int{0}) != nil {
+		t.Error("unsharded set should own every road (nil filter)")
+	}
+
+	s := newShardSet(nil, "cityflow-predictor", 3, 0)
+	if s.maxOwned != 3 {
+		t.Errorf("maxOwned = %d, want clamped to 3", s.maxOwned)
+	}
+	owns := s.owns([]int{1})
+	for i := 0; i < 100; i++ {
+		road := fmt.Sprintf("PARIS-%d", i)
+		if owns(road) != (shardOf(road, 3) == 1) {
+			t.Errorf("owns(%s) disagrees with shardOf", road)
+		}
+	}
+}
+
+func TestPredictRoadsParallelMatchesSerial(t *testing.T) {
+	now := time.Date(2026, 3, 10, 8, 0, 0, 0, time.UTC)
+	buckets := make(map[string][]bucketData)
+	var links []roadLink
+	for i := 0; i < 200; i++ {
+		road := fmt.Sprintf("PARIS-%d", i)
+		buckets[road] = flatBuckets(20+float64(i%50), 0.01*float64(i%60))
+		if i > 0 {
+			links = append(links, roadLink{fmt.Sprintf("PARIS-%d", i-1), road})
+		}
+	}
+	in := cycleInput{Now: now, Buckets: buckets, Graph: newRoadGraph(links)}
+
+	sorted := func(ps []Prediction) []Prediction {
+		sort.Slice(ps, func(i, j int) bool { return ps[i].RoadID < ps[j].RoadID })
+		return ps
+	}
+
+	serial := sorted(predictRoads(in, defaultModelParams()))
+	in.Workers = 8
+	parallel := sorted(predictRoads(in, defaultModelParams()))
+	if !reflect.DeepEqual(serial, parallel) {
+		t.Error("parallel predictions differ from serial ones")
+	}
+
+	// Sharded replicas together predict every road exactly once, with the
+	// same neighbour-aware scores as an unsharded run.
+	set := newShardSet(nil, "cityflow-predictor", 3, 3)
+	var union []Prediction
+	for shard := 0; shard < 3; shard++ {
+		in.Owns = set.owns([]int{shard})
+		union = append(union, predictRoads(in, defaultModelParams())...)
+	}
+	if !reflect.DeepEqual(serial, sorted(union)) {
+		t.Error("union of shards differs from unsharded predictions")
+	}
+}
+
+func TestShardsPerReplica(t *testing.T) {
+	cases := []struct {
+		shards, replicas, explicit int
+		want                       int
+		wantErr                    bool
+	}{
+		{shards: 1, want: 1},
+		{shards: 8, replicas: 3, want: 3},
+		{shards: 8, replicas: 4, want: 2},
+		{shards: 8, replicas: 3, explicit: 5, want: 5},
+		{shards: 4, explicit: 9, want: 4},
+		{shards: 8, wantErr: true},
+	}
+	for _, c := range cases {
+		got, err := shardsPerReplica(c.shards, c.replicas, c.explicit)
+		if (err != nil) != c.wantErr || got != c.want {
+			t.Errorf("shardsPerReplica(%d, %d, %d) = %d, %v; want %d (error %v)",
+				c.shards, c.replicas, c.explicit, got, err, c.want, c.wantErr)
+		}
+	}
+}
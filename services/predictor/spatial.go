@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"math"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// roadGraph is the directed road topology from road_links: traffic on each
+// upstream[r] road flows into r, and r flows into each downstream[r] road.
+type roadGraph struct {
+	upstream   map[string][]string
+	downstream map[string][]string
+}
+
+// roadLink is a directed edge of the road graph.
+type roadLink struct {
+	From string
+	To   string
+}
+
+func newRoadGraph(links []roadLink) roadGraph {
+	g := roadGraph{
+		upstream:   make(map[string][]string),
+		downstream: make(map[string][]string),
+	}
+	for _, l := range links {
+		if l.From == l.To {
+			continue
+		}
+		g.downstream[l.From] = append(g.downstream[l.From], l.To)
+		g.upstream[l.To] = append(g.upstream[l.To], l.From)
+	}
+	return g
+}
+
+// neighbourhood returns roadIDs and their upstream and downstream neighbours,
+// the roads spatialPressure reads for them.
+func (g roadGraph) neighbourhood(roadIDs []string) map[string]bool {
+	set := make(map[string]bool, len(roadIDs))
+	for _, id := range roadIDs {
+		set[id] = true
+		for _, n := range g.upstream[id] {
+			set[n] = true
+		}
+		for _, n := range g.downstream[id] {
+			set[n] = true
+		}
+	}
+	return set
+}
+
+func loadRoadGraph(ctx context.Context, dbPool *pgxpool.Pool) (roadGraph, error) {
+	// Only approved links; restricted movements carry no traffic from one road
+	// into the other.
+	rows, err := dbPool.Query(ctx, `SELECT from_road_id, to_road_id FROM road_links WHERE status = 'approved' AND restriction IS NULL`)
+	if err != nil {
+		return newRoadGraph(nil), err
+	}
+	defer rows.Close()
+
+	var links []roadLink
+	for rows.Next() {
+		var l roadLink
+		if err := rows.Scan(&l.From, &l.To); err != nil {
+			return newRoadGraph(nil), err
+		}
+		links = append(links, l)
+	}
+	if err := rows.Err(); err != nil {
+		return newRoadGraph(nil), err
+	}
+	return newRoadGraph(links), nil
+}
+
+// spatialPressure returns how much neighbouring roads should raise roadID's
+// blended score. Upstream roads projected to be more congested than roadID
+// push that congestion downstream within the horizon; downstream roads that
+// are already more congested spill their queue back onto roadID. Neighbours
+// without data in the lookback window are ignored.
+func spatialPressure(roadID string, states map[string]roadState, g roadGraph, params modelParams) float64 {
+	self, ok := states[roadID]
+	if !ok {
+		return 0
+	}
+	up := meanExcess(g.upstream[roadID], states, self.projected, func(s roadState) float64 { return s.projected })
+	down := meanExcess(g.downstream[roadID], states, self.current, func(s roadState) float64 { return s.current })
+	return params.SpatialUpstream*up + params.SpatialDownstream*down
+}
+
+// meanExcess averages how far each neighbour's value exceeds base, counting
+// neighbours below base as zero.
+func meanExcess(neighbours []string, states map[string]roadState, base float64, value func(roadState) float64) float64 {
+	var sum float64
+	n := 0
+	for _, id := range neighbours {
+		st, ok := states[id]
+		if !ok {
+			continue
+		}
+		sum += math.Max(0, value(st)-base)
+		n++
+	}
+	if n == 0 {
+		return 0
+	}
+	return sum / float64(n)
+}
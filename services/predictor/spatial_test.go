@@ -0,0 +1,97 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func flatBuckets(speed, occ float64) []bucketData {
+	var buckets []bucketData
+	for i := 0; i < 6; i++ {
+		buckets = append(buckets, bucketData{offsetMin: float64(i * 5), avgSpeed: speed, avgOcc: occ, avgFlow: 40, samples: 10})
+	}
+	return buckets
+}
+
+func TestNewRoadGraph(t *testing.T) {
+	g := newRoadGraph([]roadLink{{"A", "B"}, {"B", "C"}, {"A", "C"}, {"C", "C"}})
+	if got := g.downstream["A"]; len(got) != 2 {
+		t.Errorf("downstream[A] = %v, want [B C]", got)
+	}
+	if got := g.upstream["C"]; len(got) != 2 {
+		t.Errorf("upstream[C] = %v, want [B A] (self-loop ignored)", got)
+	}
+	if got := g.upstream["A"]; len(got) != 0 {
+		t.Errorf("upstream[A] = %v, want none", got)
+	}
+}
+
+func TestRoadGraphNeighbourhood(t *testing.T) {
+	g := newRoadGraph([]roadLink{{"A", "B"}, {"B", "C"}, {"C", "D"}, {"E", "F"}})
+	got := g.neighbourhood([]string{"B"})
+	want := map[string]bool{"A": true, "B": true, "C": true}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("neighbourhood(B) = %v, want %v", got, want)
+	}
+	if got := g.neighbourhood([]string{"Z"}); !reflect.DeepEqual(got, map[string]bool{"Z": true}) {
+		t.Errorf("neighbourhood(Z) = %v, want only Z", got)
+	}
+}
+
+func TestSpatialPressure(t *testing.T) {
+	params := defaultModelParams()
+	states := map[string]roadState{
+		"UP":   {current: 0.6, projected: 0.9},
+		"SELF": {current: 0.3, projected: 0.3},
+		"DOWN": {current: 0.7, projected: 0.7},
+		"CALM": {current: 0.1, projected: 0.1},
+	}
+	g := newRoadGraph([]roadLink{{"UP", "SELF"}, {"CALM", "SELF"}, {"SELF", "DOWN"}})
+
+	got := spatialPressure("SELF", states, g, params)
+	// upstream: mean(0.6, 0) = 0.3; downstream: 0.4
+	want := params.SpatialUpstream*0.3 + params.SpatialDownstream*0.4
+	if diff := got - want; diff > 1e-9 || diff < -1e-9 {
+		t.Errorf("spatialPressure = %v, want %v", got, want)
+	}
+
+	if got := spatialPressure("DOWN", states, g, params); got != 0 {
+		t.Errorf("road without congested neighbours got pressure %v", got)
+	}
+	if got := spatialPressure("MISSING", states, g, params); got != 0 {
+		t.Errorf("unknown road got pressure %v", got)
+	}
+}
+
+func TestPredictRoadsUpstreamShockwave(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	// Upstream speed collapsing over the lookback window.
+	var upstream []bucketData
+	for i, speed := range []float64{50, 45, 35, 25, 15, 10} {
+		upstream = append(upstream, bucketData{offsetMin: float64(i * 5), avgSpeed: speed, avgOcc: 0.2 + 0.1*float64(i), avgFlow: 40, samples: 10})
+	}
+	buckets := map[string][]bucketData{
+		"RING-A": upstream,
+		"RING-B": flatBuckets(80, 0.1),
+	}
+
+	scores := func(g roadGraph) map[string]float64 {
+		out := make(map[string]float64)
+		for _, p := range predictRoads(cycleInput{Now: now, Buckets: buckets, Graph: g}, defaultModelParams()) {
+			out[p.RoadID] = p.CongestionScore
+		}
+		return out
+	}
+
+	isolated := scores(newRoadGraph(nil))
+	linked := scores(newRoadGraph([]roadLink{{"RING-A", "RING-B"}}))
+
+	if linked["RING-B"] <= isolated["RING-B"] {
+		t.Errorf("downstream forecast %v should rise above isolated %v", linked["RING-B"], isolated["RING-B"])
+	}
+	if linked["RING-A"] != isolated["RING-A"] {
+		t.Errorf("upstream forecast changed from %v to %v", isolated["RING-A"], linked["RING-A"])
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeWeatherProvider returns canned observations.
+type fakeWeatherProvider struct {
+	obs []weatherObservation
+}
+
+func (p fakeWeatherProvider) Name() string { return "fake" }
+
+func (p fakeWeatherProvider) Fetch(context.Context) ([]weatherObservation, error) {
+	return p.obs, nil
+}
+
+func floatPtr(v float64) *float64 { return &v }
+
+func TestValidWeatherDropsAndClamps(t *testing.T) {
+	t0 := time.Date(2026, 3, 10, 12, 0, 0, 0, time.FixedZone("CET", 3600))
+	var provider weatherProvider = fakeWeatherProvider{obs: []weatherObservation{
+		{TS: t0.Add(10 * time.Minute), Zone: "north", PrecipMMH: -1, VisibilityM: floatPtr(-5)},
+		{TS: t0, Zone: "north", PrecipMMH: 2},
+		{TS: t0, Zone: ""},
+		{Zone: "north"},
+		{TS: t0, Zone: "south", PrecipMMH: math.NaN()},
+	}}
+	obs, _ := provider.Fetch(context.Background())
+
+	got := validWeather(obs)
+	if len(got) != 2 {
+		t.Fatalf("got %d observations, want 2: %+v", len(got), got)
+	}
+	if !got[0].TS.Equal(t0) || got[0].TS.Location() != time.UTC {
+		t.Errorf("first ts = %v, want %v in UTC", got[0].TS, t0)
+	}
+	if got[1].PrecipMMH != 0 || got[1].VisibilityM != nil {
+		t.Errorf("negative values not cleaned: %+v", got[1])
+	}
+}
+
+func TestParseWeatherCSV(t *testing.T) {
+	in := "ts,zone_id,precipitation_mm_h,temperature_c,visibility_m\n" +
+		"2026-03-10T12:00:00Z,default,1.5,-2,800\n" +
+		"2026-03-10T12:10:00Z,north,0,,\n"
+	obs, err := parseWeatherCSV(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(obs) != 2 {
+		t.Fatalf("got %d observations, want 2", len(obs))
+	}
+	if obs[0].PrecipMMH != 1.5 || *obs[0].TemperatureC != -2 || *obs[0].VisibilityM != 800 {
+		t.Errorf("first row = %+v", obs[0])
+	}
+	if obs[1].Zone != "north" || obs[1].TemperatureC != nil || obs[1].VisibilityM != nil {
+		t.Errorf("optional columns not left nil: %+v", obs[1])
+	}
+
+	for name, bad := range map[string]string{
+		"header": "time,zone,rain,temp,vis\n",
+		"ts":     "ts,zone_id,precipitation_mm_h,temperature_c,visibility_m\nnoon,default,0,,\n",
+		"rain":   "ts,zone_id,precipitation_mm_h,temperature_c,visibility_m\n2026-03-10T12:00:00Z,default,heavy,,\n",
+	} {
+		if _, err := parseWeatherCSV(strings.NewReader(bad)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestOpenMeteoProviderConvertsInterval(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/forecast" || !strings.Contains(r.URL.Query().Get("current"), "precipitation") {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"current":{"time":"2026-03-10T12:15","interval":900,"precipitation":0.5,"temperature_2m":3.2,"visibility":12000}}`))
+	}))
+	defer srv.Close()
+
+	p := openMeteoProvider{baseURL: srv.URL, zone: "centre", lat: 48.85, lon: 2.35, client: srv.Client()}
+	obs, err := p.Fetch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(obs) != 1 {
+		t.Fatalf("got %d observations, want 1", len(obs))
+	}
+	o := obs[0]
+	if o.Zone != "centre" || !o.TS.Equal(time.Date(2026, 3, 10, 12, 15, 0, 0, time.UTC)) {
+		t.Errorf("observation = %+v", o)
+	}
+	if o.PrecipMMH != 2 { // 0.5 mm over 15 minutes
+		t.Errorf("precipitation = %v mm/h, want 2", o.PrecipMMH)
+	}
+	if o.TemperatureC == nil || *o.TemperatureC != 3.2 {
+		t.Errorf("temperature = %v, want 3.2", o.TemperatureC)
+	}
+}
+
+func TestPickWeatherIgnoresFutureAndStale(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	picked := pickWeather([]weatherObservation{
+		{TS: now.Add(-30 * time.Minute), Zone: "default", PrecipMMH: 1},
+		{TS: now.Add(-10 * time.Minute), Zone: "default", PrecipMMH: 2},
+		{TS: now.Add(10 * time.Minute), Zone: "default", PrecipMMH: 9},
+		{TS: now.Add(-3 * time.Hour), Zone: "north", PrecipMMH: 4},
+	}, now)
+
+	if len(picked) != 1 {
+		t.Fatalf("got zones %v, want only default", picked)
+	}
+	if picked["default"].PrecipMMH != 2 {
+		t.Errorf("picked %+v, want the latest past observation", picked["default"])
+	}
+}
+
+func TestWeatherFeedFillsMissingAndOlderZones(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	var nilFeed *weatherFeed
+	if got := nilFeed.fill(nil, now); got != nil {
+		t.Errorf("nil feed filled %v", got)
+	}
+
+	feed := &weatherFeed{}
+	feed.set([]weatherObservation{
+		{TS: now.Add(-5 * time.Minute), Zone: "default", PrecipMMH: 3},
+		{TS: now.Add(-20 * time.Minute), Zone: "north", PrecipMMH: 1},
+	})
+	stored := map[string]weatherObservation{
+		"default": {TS: now.Add(-15 * time.Minute), Zone: "default", PrecipMMH: 2},
+		"north":   {TS: now.Add(-10 * time.Minute), Zone: "north", PrecipMMH: 5},
+	}
+	got := feed.fill(stored, now)
+	if got["default"].PrecipMMH != 3 || got["north"].PrecipMMH != 5 {
+		t.Errorf("filled %+v, want the newer observation of each zone", got)
+	}
+	// A failed weather load leaves only the polled observations.
+	if got := feed.fill(nil, now); len(got) != 2 {
+		t.Errorf("filled %+v from nothing, want both polled zones", got)
+	}
+}
+
+func TestWeatherFactor(t *testing.T) {
+	p := defaultModelParams()
+	cases := []struct {
+		name string
+		w    weatherObservation
+		want float64
+	}{
+		{"dry", weatherObservation{}, 1},
+		{"light rain", weatherObservation{PrecipMMH: 2}, 1 + 2*p.RainPerMM},
+		{"downpour capped", weatherObservation{PrecipMMH: 50}, 1 + p.RainCap},
+		{"fog", weatherObservation{VisibilityM: floatPtr(200)}, 1 + p.LowVisibility},
+		{"cold but dry", weatherObservation{TemperatureC: floatPtr(-3)}, 1},
+		{"freezing rain", weatherObservation{PrecipMMH: 1, TemperatureC: floatPtr(-1)}, 1 + p.RainPerMM + p.Freezing},
+	}
+	for _, c := range cases {
+		if got := p.weatherFactor(c.w); math.Abs(got-c.want) > 1e-9 {
+			t.Errorf("%s: factor = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestPredictRoadsRainRaisesScoreInZone(t *testing.T) {
+	now := time.Date(2026, 3, 10, 3, 0, 0, 0, time.UTC) // off-peak
+	in := cycleInput{
+		Now: now,
+		Buckets: map[string][]bucketData{
+			"A": flatBuckets(40, 0.3),
+			"B": flatBuckets(40, 0.3),
+		},
+		RoadZones: map[string]string{"A": "north", "B": "south"},
+		Weather: map[string]weatherObservation{
+			"north": {TS: now.Add(-5 * time.Minute), Zone: "north", PrecipMMH: 4},
+		},
+	}
+
+	byRoad := make(map[string]Prediction)
+	for _, p := range predictRoads(in, defaultModelParams()) {
+		byRoad[p.RoadID] = p
+	}
+	a, b := byRoad["A"], byRoad["B"]
+	if a.CongestionScore <= b.CongestionScore {
+		t.Errorf("rain on A: score %v, want above dry B %v", a.CongestionScore, b.CongestionScore)
+	}
+	if a.Components.Weather == nil || a.Components.WeatherFactor <= 1 {
+		t.Errorf("A components = %+v, want the weather recorded", a.Components)
+	}
+	if b.Components.Weather != nil || b.Components.WeatherFactor != 1 {
+		t.Errorf("B components = %+v, want no weather", b.Components)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	// defaultCapacityVPH is assumed for roads without a known capacity: the
+	// urban class default of two 900 veh/h lanes.
+	defaultCapacityVPH = 1800.0
+	// divertShare is the share of a congested road's flow that a
+	// recommendation is expected to divert.
+	divertShare = 0.3
+	// maxLoadRatio caps the flow of any road, measured plus diverted, as a
+	// fraction of its capacity.
+	maxLoadRatio = 0.9
+	// minDivertedVPH is the smallest headroom, in veh/h, worth recommending
+	// a detour through.
+	minDivertedVPH = 50.0
+	// flowWindow is how far back the current flow of each road is averaged.
+	flowWindow = 15 * time.Minute
+)
+
+// roadFlows holds the current flow of each monitored road, in vehicles per
+// hour.
+type roadFlows map[string]float64
+
+// loadFlows averages the flow measured on each road over the last flowWindow.
+func loadFlows(ctx context.Context, dbPool *pgxpool.Pool, now time.Time) (roadFlows, error) {
+	rows, err := dbPool.Query(ctx, `
+		SELECT road_id, AVG(flow_rate)
+		FROM traffic_raw
+		WHERE ts >= $1 AND flow_rate IS NOT NULL
+		GROUP BY road_id
+	`, now.Add(-flowWindow))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	flows := make(roadFlows)
+	for rows.Next() {
+		var roadID string
+		var vph float64
+		if err := rows.Scan(&roadID, &vph); err != nil {
+			return nil, err
+		}
+		flows[roadID] = vph
+	}
+	return flows, rows.Err()
+}
+
+func (g roadGraph) capacityOf(roadID string) float64 {
+	if vph, ok := g.capacity[roadID]; ok {
+		return vph
+	}
+	return defaultCapacityVPH
+}
+
+// divertLedger assigns diverted volume to detour roads during a cycle, so
+// that several congested roads are not all sent onto the same alternative.
+// Roads are served from the most congested down: once an alternative is full,
+// later roads look for another one.
+type divertLedger struct {
+	graph     roadGraph
+	flows     roadFlows
+	forecasts roadForecasts
+	assigned  map[string]float64
+}
+
+func newDivertLedger(graph roadGraph, flows roadFlows, forecasts roadForecasts) *divertLedger {
+	return &divertLedger{graph: graph, flows: flows, forecasts: forecasts, assigned: make(map[string]float64)}
+}
+
+// flow is the current flow of roadID: measured, or estimated from its
+// congestion score and capacity when the road has no recent measurement.
+func (l *divertLedger) flow(roadID string) float64 {
+	if vph, ok := l.flows[roadID]; ok {
+		return vph
+	}
+	return l.forecasts.score(roadID, 0) * l.graph.capacityOf(roadID)
+}
+
+// headroom is the volume roadID can still take this cycle before reaching
+// maxLoadRatio of its capacity.
+func (l *divertLedger) headroom(roadID string) float64 {
+	return l.graph.capacityOf(roadID)*maxLoadRatio - l.flow(roadID) - l.assigned[roadID]
+}
+
+// divertible is the volume a recommendation on roadID should divert.
+func (l *divertLedger) divertible(roadID string) float64 {
+	return divertShare * l.flow(roadID)
+}
+
+// full reports whether roadID cannot take even a useful part of volume.
+func (l *divertLedger) full(roadID string, volume float64) bool {
+	return l.headroom(roadID) < math.Min(volume, minDivertedVPH)
+}
+
+// assign diverts up to volume onto roads, limited by the road with the least
+// headroom, and returns the volume actually assigned.
+func (l *divertLedger) assign(roads []string, volume float64) float64 {
+	for _, r := range roads {
+		volume = math.Min(volume, l.headroom(r))
+	}
+	volume = math.Max(0, volume)
+	for _, r := range roads {
+		l.assigned[r] += volume
+	}
+	return volume
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// sharedDetourGraph has two congested roads, JAM-1 and JAM-2, that can both
+// be bypassed through the short SHARED road, or each through a longer side
+// street.
+func sharedDetourGraph() roadGraph {
+	var links []roadLink
+	for _, n := range []string{"1", "2"} {
+		links = append(links,
+			roadLink{From: "UP-" + n, To: "JAM-" + n, LengthM: meters(500)},
+			roadLink{From: "JAM-" + n, To: "DOWN-" + n, LengthM: meters(500)},
+			roadLink{From: "UP-" + n, To: "SHARED", LengthM: meters(300)},
+			roadLink{From: "SHARED", To: "DOWN-" + n, LengthM: meters(300)},
+			roadLink{From: "UP-" + n, To: "SIDE-" + n, LengthM: meters(600)},
+			roadLink{From: "SIDE-" + n, To: "DOWN-" + n, LengthM: meters(600)},
+		)
+	}
+	g := newRoadGraph(links)
+	g.capacity = map[string]float64{"SHARED": 600}
+	return g
+}
+
+func TestSelectReroutesSharesCapacity(t *testing.T) {
+	g := sharedDetourGraph()
+	preds := map[string]RoadPrediction{
+		"JAM-1":  {RoadID: "JAM-1", CongestionScore: 0.9, UpperBound: 0.95},
+		"JAM-2":  {RoadID: "JAM-2", CongestionScore: 0.8, UpperBound: 0.9},
+		"SHARED": {RoadID: "SHARED", CongestionScore: 0.2, UpperBound: 0.3},
+	}
+	flows := roadFlows{"JAM-1": 1000, "JAM-2": 900, "SHARED": 200}
+
+	got := make(map[string]Reroute)
+	for _, r := range selectReroutes(forecastsAt(5, preds), flows, g, roadRules{}, 0.5, nil, time.Now()) {
+		got[r.RouteID] = r
+	}
+	// JAM-1 is served first and fills SHARED (540 veh/h usable, 200 measured):
+	// JAM-2 falls back on its side street.
+	if r := got["JAM-1"]; r.AltRouteID != "SHARED" || r.DivertedVPH == nil || *r.DivertedVPH != 300 {
+		t.Errorf("JAM-1 reroute = %+v, want 300 veh/h via SHARED", r)
+	}
+	if r := got["JAM-2"]; r.AltRouteID != "SIDE-2" || r.DivertedVPH == nil || *r.DivertedVPH != 270 {
+		t.Errorf("JAM-2 reroute = %+v, want 270 veh/h via SIDE-2", r)
+	}
+
+	// With room for both, the short cut is recommended twice.
+	g.capacity["SHARED"] = 2000
+	for _, r := range selectReroutes(forecastsAt(5, preds), flows, g, roadRules{}, 0.5, nil, time.Now()) {
+		if r.AltRouteID != "SHARED" {
+			t.Errorf("reroute = %+v, want via SHARED", r)
+		}
+	}
+}
+
+func TestDivertLedgerAssign(t *testing.T) {
+	g := newRoadGraph(nil)
+	g.capacity = map[string]float64{"A": 1000, "B": 500}
+	l := newDivertLedger(g, roadFlows{"A": 100}, forecastsAt(5, map[string]RoadPrediction{"B": {RoadID: "B", CongestionScore: 0.4}}))
+
+	// B: 450 usable, 200 estimated from its score.
+	if got := l.assign([]string{"A", "B"}, 300); got != 250 {
+		t.Errorf("assigned %v, want the 250 veh/h B has left", got)
+	}
+	if !l.full("B", 100) || l.full("A", 100) {
+		t.Errorf("headroom A = %v, B = %v", l.headroom("A"), l.headroom("B"))
+	}
+	if got := l.assign([]string{"B"}, 100); got != 0 {
+		t.Errorf("assigned %v onto a full road", got)
+	}
+	// Unknown roads default to defaultCapacityVPH at the unmonitored score.
+	if got, want := l.headroom("C"), defaultCapacityVPH*(maxLoadRatio-unmonitoredScore); got != want {
+		t.Errorf("headroom C = %v, want %v", got, want)
+	}
+}
+
+func TestSelectReroutesDirectLinkHeadroom(t *testing.T) {
+	// UP links straight to DOWN, so the detour has no road in between and
+	// the diverted volume lands on DOWN itself.
+	g := newRoadGraph([]roadLink{
+		{From: "UP", To: "JAM", LengthM: meters(500)},
+		{From: "JAM", To: "DOWN", LengthM: meters(500)},
+		{From: "UP", To: "DOWN", LengthM: meters(300)},
+	})
+	g.capacity = map[string]float64{"DOWN": 1000}
+	preds := map[string]RoadPrediction{
+		"JAM":  {RoadID: "JAM", CongestionScore: 0.9, UpperBound: 0.95},
+		"DOWN": {RoadID: "DOWN", CongestionScore: 0.2, UpperBound: 0.3},
+	}
+
+	// DOWN: 900 usable, 750 measured.
+	got := selectReroutes(forecastsAt(5, preds), roadFlows{"JAM": 1000, "DOWN": 750}, g, roadRules{}, 0.5, nil, time.Now())
+	if len(got) != 1 || got[0].AltRouteID != "DOWN" || got[0].DivertedVPH == nil || *got[0].DivertedVPH != 150 {
+		t.Fatalf("reroutes = %+v, want 150 veh/h onto DOWN", got)
+	}
+
+	// A full downstream road leaves nothing to divert: no recommendation.
+	if got := selectReroutes(forecastsAt(5, preds), roadFlows{"JAM": 1000, "DOWN": 950}, g, roadRules{}, 0.5, nil, time.Now()); len(got) != 0 {
+		t.Errorf("reroutes = %+v, want none onto a full road", got)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// offset returns a point dx metres east and dy metres north of origin.
+func offset(id string, dx, dy float64) roadPoint {
+	const lat0, lng0 = 48.8566, 2.3522
+	return roadPoint{
+		RoadID: id,
+		Lat:    lat0 + dy/earthRadiusM*180/math.Pi,
+		Lng:    lng0 + dx/(earthRadiusM*math.Cos(lat0*math.Pi/180))*180/math.Pi,
+	}
+}
+
+func TestHaversineAndBearing(t *testing.T) {
+	o := offset("O", 0, 0)
+	e := offset("E", 500, 0)
+	n := offset("N", 0, 300)
+
+	if d := haversineM(o, e); math.Abs(d-500) > 1 {
+		t.Errorf("distance O-E = %.1f m, want 500", d)
+	}
+	if b := bearingDeg(o, e); math.Abs(b-90) > 0.5 {
+		t.Errorf("bearing O->E = %.1f, want 90", b)
+	}
+	if b := bearingDeg(o, n); b > 0.5 && b < 359.5 {
+		t.Errorf("bearing O->N = %.1f, want 0", b)
+	}
+	if d := angleDiff(350, 10); d != 20 {
+		t.Errorf("angleDiff(350, 10) = %v, want 20", d)
+	}
+}
+
+func TestDeriveLinks(t *testing.T) {
+	roads := []roadPoint{
+		offset("PARIS-A", 0, 0),
+		offset("PARIS-B", 200, 0),  // east of A
+		offset("PARIS-C", 400, 5),  // further east, behind B seen from A
+		offset("PARIS-D", 0, -300), // south of A
+		offset("PARIS-E", 5000, 0), // beyond the cutoff
+	}
+	links := deriveLinks(roads, deriveParams{K: 4, MaxDistanceM: 800, MinSeparationDeg: 30})
+
+	got := make(map[[2]string]candidateLink)
+	for _, l := range links {
+		got[[2]string{l.From, l.To}] = l
+	}
+	for _, want := range [][2]string{
+		{"PARIS-A", "PARIS-B"}, {"PARIS-B", "PARIS-A"},
+		{"PARIS-A", "PARIS-D"}, {"PARIS-D", "PARIS-A"},
+		{"PARIS-B", "PARIS-C"}, {"PARIS-C", "PARIS-B"},
+	} {
+		if _, ok := got[want]; !ok {
+			t.Errorf("missing link %s -> %s", want[0], want[1])
+		}
+	}
+	if _, ok := got[[2]string{"PARIS-A", "PARIS-C"}]; ok {
+		t.Error("A -> C proposed although C lies behind B")
+	}
+	for key := range got {
+		if key[0] == "PARIS-E" || key[1] == "PARIS-E" {
+			t.Errorf("link %v beyond the distance cutoff", key)
+		}
+	}
+
+	ab := got[[2]string{"PARIS-A", "PARIS-B"}]
+	if ab.LengthM != 200 || math.Abs(ab.Score-0.75) > 0.001 {
+		t.Errorf("A -> B = %+v, want length 200 and score 0.75", ab)
+	}
+}
+
+func TestDeriveLinksKeepsKNearest(t *testing.T) {
+	roads := []roadPoint{offset("HUB", 0, 0)}
+	for i, angle := range []float64{0, 90, 180, 270} {
+		rad := angle * math.Pi / 180
+		dist := 100 * float64(i+1)
+		roads = append(roads, offset(string(rune('A'+i)), dist*math.Sin(rad), dist*math.Cos(rad)))
+	}
+	links := deriveLinks(roads, deriveParams{K: 2, MaxDistanceM: 800, MinSeparationDeg: 30})
+
+	from := 0
+	for _, l := range links {
+		if l.From == "HUB" {
+			from++
+			if l.To != "A" && l.To != "B" && l.To != "C" && l.To != "D" {
+				t.Errorf("unexpected link %+v", l)
+			}
+		}
+	}
+	// HUB keeps its 2 nearest (A, B); C and D keep HUB among their own nearest.
+	if from < 2 {
+		t.Errorf("HUB has %d outgoing links, want at least its 2 nearest", from)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestParseFleetMix(t *testing.T) {
+	mix, err := parseFleetMix("petrol=3, diesel=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mix["petrol"] != 0.75 || mix["diesel"] != 0.25 {
+		t.Errorf("mix = %v, want shares normalised to 0.75 and 0.25", mix)
+	}
+	for _, bad := range []string{"", "petrol", "steam=1", "petrol=-1", "electric=0"} {
+		if _, err := parseFleetMix(bad); err == nil {
+			t.Errorf("parseFleetMix(%q) should fail", bad)
+		}
+	}
+	if _, err := parseFleetMix(defaultFleetMix); err != nil {
+		t.Errorf("default fleet mix: %v", err)
+	}
+}
+
+func TestEmissionCurves(t *testing.T) {
+	petrol := vehicleCurves["petrol"]
+	best := 5.0
+	for v := 5.0; v <= 130; v++ {
+		if petrol.gramsPerKm(v) < petrol.gramsPerKm(best) {
+			best = v
+		}
+	}
+	if best < 45 || best > 65 {
+		t.Errorf("petrol curve minimum at %v km/h, want steady urban speeds", best)
+	}
+	if g := petrol.gramsPerKm(50); g < 120 || g > 170 {
+		t.Errorf("petrol at 50 km/h = %.0f g/km", g)
+	}
+	if g := (fleetMix{"electric": 1}).gramsPerKm(30); g != 0 {
+		t.Errorf("electric = %v g/km, want no tailpipe CO2", g)
+	}
+	// Stop and go costs more than steady driving for the whole fleet.
+	if fleetMix(nil).gramsPerKm(10) <= fleetMix(nil).gramsPerKm(40) {
+		t.Error("default fleet emits less at 10 km/h than at 40 km/h")
+	}
+}
+
+func TestSelectReroutesGains(t *testing.T) {
+	g := newRoadGraph([]roadLink{
+		{From: "UP", To: "JAM", LengthM: meters(1000)},
+		{From: "JAM", To: "DOWN", LengthM: meters(1000)},
+		{From: "UP", To: "ALT", LengthM: meters(1000)},
+		{From: "ALT", To: "DOWN", LengthM: meters(1000)},
+	})
+	preds := map[string]RoadPrediction{
+		"JAM": {RoadID: "JAM", CongestionScore: 0.8, UpperBound: 0.9}, // 10 km/h
+		"ALT": {RoadID: "ALT", CongestionScore: 0.2, UpperBound: 0.3}, // 40 km/h
+	}
+	fleet := fleetMix{"petrol": 0.5, "diesel": 0.5}
+
+	reroutes := selectReroutes(forecastsAt(5, preds), roadFlows{"JAM": 1000}, g, roadRules{}, 0.5, fleet, time.Now())
+	if len(reroutes) != 1 {
+		t.Fatalf("got %d reroutes, want 1", len(reroutes))
+	}
+	r := reroutes[0]
+	// 1 km at 10 km/h instead of 1 km at 40 km/h; both then drive into DOWN.
+	if r.ETAGainMin == nil || *r.ETAGainMin != 4.5 {
+		t.Errorf("ETA gain = %v, want 4.5 min", r.ETAGainMin)
+	}
+	// 30 % of 1000 veh/h diverted.
+	want := (fleet.gramsPerKm(10) - fleet.gramsPerKm(40)) * 300 / 1000
+	if r.EstimatedCO2Gain == nil || math.Abs(*r.EstimatedCO2Gain-want) > 0.001 {
+		t.Errorf("CO2 gain = %v kg/h, want %.3f", r.EstimatedCO2Gain, want)
+	}
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// roadLinksChannel is notified by a trigger on road_links after every edit.
+const roadLinksChannel = "road_links_changed"
+
+var (
+	graphLinks = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "cityflow_rerouter_graph_links",
+		Help: "Number of usable road_links in the loaded road graph.",
+	})
+	graphReloads = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cityflow_rerouter_graph_reloads_total",
+		Help: "Total number of road graph reloads, by result.",
+	}, []string{"result"})
+)
+
+// roadLink is a directed edge of road_links: traffic on From can turn into
+// To unless Restriction forbids the movement. LengthM is the distance driven
+// to reach To, when known.
+type roadLink struct {
+	From        string
+	To          string
+	LengthM     *float64
+	Restriction *string
+}
+
+// roadGraph is the directed road topology: traffic on each upstream[r] road
+// flows into r, and r flows into each downstream[r] road. Restricted links
+// and self-loops are left out. length holds the known link lengths, freeFlow
+// the free-flow speed of each road, in km/h, capacity its capacity, in
+// vehicles per hour, and shapes its [lng, lat] geometry: the imported
+// segment, or the sensor point alone.
+type roadGraph struct {
+	upstream   map[string][]string
+	downstream map[string][]string
+	length     map[[2]string]float64
+	freeFlow   map[string]float64
+	capacity   map[string]float64
+	shapes     map[string][][2]float64
+	links      int
+}
+
+func newRoadGraph(links []roadLink) roadGraph {
+	g := roadGraph{
+		upstream:   make(map[string][]string),
+		downstream: make(map[string][]string),
+		length:     make(map[[2]string]float64),
+	}
+	for _, l := range links {
+		if l.From == l.To || l.Restriction != nil {
+			continue
+		}
+		g.downstream[l.From] = append(g.downstream[l.From], l.To)
+		g.upstream[l.To] = append(g.upstream[l.To], l.From)
+		if l.LengthM != nil && *l.LengthM > 0 {
+			g.length[[2]string{l.From, l.To}] = *l.LengthM
+		}
+		g.links++
+	}
+	return g
+}
+
+func loadRoadGraph(ctx context.Context, dbPool *pgxpool.Pool) (roadGraph, error) {
+	rows, err := dbPool.Query(ctx, `SELECT from_road_id, to_road_id, length_m, restriction FROM road_links WHERE status = 'approved'`)
+	if err != nil {
+		return roadGraph{}, err
+	}
+	defer rows.Close()
+
+	var links []roadLink
+	for rows.Next() {
+		var l roadLink
+		if err := rows.Scan(&l.From, &l.To, &l.LengthM, &l.Restriction); err != nil {
+			return roadGraph{}, err
+		}
+		links = append(links, l)
+	}
+	if err := rows.Err(); err != nil {
+		return roadGraph{}, err
+	}
+
+	g := newRoadGraph(links)
+	if g.freeFlow, g.capacity, err = loadRoadAttributes(ctx, dbPool); err != nil {
+		return roadGraph{}, err
+	}
+	if g.shapes, err = loadRoadShapes(ctx, dbPool); err != nil {
+		return roadGraph{}, err
+	}
+	return g, nil
+}
+
+// loadRoadShapes reads the geometry of imported segments, and the sensor
+// point of roads that are not mapped onto one.
+func loadRoadShapes(ctx context.Context, dbPool *pgxpool.Pool) (map[string][][2]float64, error) {
+	rows, err := dbPool.Query(ctx, `
+		SELECT road_id, geometry, NULL::float8, NULL::float8 FROM road_segments
+		UNION ALL
+		SELECT r.road_id, NULL, r.lat, r.lng FROM roads r
+		WHERE r.lat IS NOT NULL AND r.lng IS NOT NULL
+			AND NOT EXISTS (SELECT 1 FROM road_segments s WHERE s.road_id = r.road_id)
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shapes := make(map[string][][2]float64)
+	for rows.Next() {
+		var roadID string
+		var geometry [][2]float64
+		var lat, lng *float64
+		if err := rows.Scan(&roadID, &geometry, &lat, &lng); err != nil {
+			return nil, err
+		}
+		if lat != nil && lng != nil {
+			geometry = [][2]float64{{*lng, *lat}}
+		}
+		if len(geometry) > 0 {
+			shapes[roadID] = geometry
+		}
+	}
+	return shapes, rows.Err()
+}
+
+// loadRoadAttributes reads the free-flow speed and capacity of the sensor
+// roads, falling back to their class defaults, and the speed limit and class
+// capacity of imported segments without a sensor.
+func loadRoadAttributes(ctx context.Context, dbPool *pgxpool.Pool) (map[string]float64, map[string]float64, error) {
+	rows, err := dbPool.Query(ctx, `
+		SELECT r.road_id, COALESCE(r.free_flow_speed_kmh, c.free_flow_speed_kmh),
+			COALESCE(r.capacity_vph, c.capacity_per_lane_vph * COALESCE(r.lanes, c.default_lanes))
+		FROM roads r
+		LEFT JOIN road_classes c ON c.road_class = r.road_class
+		UNION ALL
+		SELECT s.road_id, s.speed_limit_kmh, c.capacity_per_lane_vph * COALESCE(s.lanes, c.default_lanes)
+		FROM road_segments s
+		LEFT JOIN road_classes c ON c.road_class = s.road_class
+		WHERE s.sensor_distance_m IS NULL
+	`)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	speeds := make(map[string]float64)
+	capacities := make(map[string]float64)
+	for rows.Next() {
+		var roadID string
+		var kmh, vph *float64
+		if err := rows.Scan(&roadID, &kmh, &vph); err != nil {
+			return nil, nil, err
+		}
+		if kmh != nil && *kmh > 0 {
+			speeds[roadID] = *kmh
+		}
+		if vph != nil && *vph > 0 {
+			capacities[roadID] = *vph
+		}
+	}
+	return speeds, capacities, rows.Err()
+}
+
+// graphStore caches the road graph between cycles. It reloads it when a
+// road_links notification marked it stale, and at least every maxAge in case
+// a notification was missed while the listener was reconnecting.
+type graphStore struct {
+	pool   *pgxpool.Pool
+	maxAge time.Duration
+	load   func(ctx context.Context) (roadGraph, error)
+
+	stale    atomic.Bool
+	mu       sync.Mutex
+	graph    roadGraph
+	loadedAt time.Time
+}
+
+func newGraphStore(pool *pgxpool.Pool, maxAge time.Duration) *graphStore {
+	s := &graphStore{pool: pool, maxAge: maxAge}
+	s.load = func(ctx context.Context) (roadGraph, error) { return loadRoadGraph(ctx, pool) }
+	s.stale.Store(true)
+	return s
+}
+
+// get returns the current graph, reloading it first if needed. When a reload
+// fails the previous graph is kept.
+func (s *graphStore) get(ctx context.Context, now time.Time) roadGraph {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.stale.Load() && now.Sub(s.loadedAt) < s.maxAge {
+		return s.graph
+	}
+	// Clear the flag first so that an edit made during the load is not lost.
+	s.stale.Store(false)
+	g, err := s.load(ctx)
+	if err != nil {
+		s.stale.Store(true)
+		graphReloads.WithLabelValues("error").Inc()
+		log.Printf("road graph reload failed, keeping %d links: %v", s.graph.links, err)
+		return s.graph
+	}
+	graphReloads.WithLabelValues("ok").Inc()
+	graphLinks.Set(float64(g.links))
+	if g.links != s.graph.links || s.loadedAt.IsZero() {
+		log.Printf("road graph loaded: %d links", g.links)
+	}
+	s.graph, s.loadedAt = g, now
+	return g
+}
+
+func (s *graphStore) invalidate() {
+	s.stale.Store(true)
+}
+
+// listen marks the graph stale on every road_links notification until ctx is
+// done, reconnecting after errors.
+func (s *graphStore) listen(ctx context.Context, retry time.Duration) {
+	for ctx.Err() == nil {
+		if err := s.listenOnce(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("road_links listener: %v, retrying in %s", err, retry)
+			// Changes may have been missed while disconnected.
+			s.invalidate()
+			select {
+			case <-time.After(retry):
+			case <-ctx.Done():
+			}
+		}
+	}
+}
+
+func (s *graphStore) listenOnce(ctx context.Context) error {
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if !conn.Conn().IsClosed() {
+			conn.Exec(context.Background(), "UNLISTEN *")
+		}
+		conn.Release()
+	}()
+
+	if _, err := conn.Exec(ctx, "LISTEN "+roadLinksChannel); err != nil {
+		return err
+	}
+	for {
+		if _, err := conn.Conn().WaitForNotification(ctx); err != nil {
+			return err
+		}
+		s.invalidate()
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewRoadGraph(t *testing.T) {
+	noLeft := "no_left_turn"
+	length := 120.0
+	g := newRoadGraph([]roadLink{
+		{From: "PARIS-1", To: "PARIS-2", LengthM: &length},
+		{From: "PARIS-1", To: "PARIS-3"},
+		{From: "PARIS-1", To: "PARIS-4", Restriction: &noLeft},
+		{From: "PARIS-2", To: "PARIS-5"},
+		{From: "PARIS-6", To: "PARIS-5"},
+		{From: "PARIS-2", To: "PARIS-2"},
+	})
+
+	if g.links != 4 {
+		t.Errorf("links = %d, want 4 (restricted link and self-loop skipped)", g.links)
+	}
+	if got, want := g.downstream["PARIS-1"], []string{"PARIS-2", "PARIS-3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("downstream(PARIS-1) = %v, want %v: the turn into PARIS-4 is forbidden", got, want)
+	}
+	if got, want := g.upstream["PARIS-5"], []string{"PARIS-2", "PARIS-6"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("upstream(PARIS-5) = %v, want %v", got, want)
+	}
+	if got := g.length[[2]string{"PARIS-1", "PARIS-2"}]; got != 120 {
+		t.Errorf("length PARIS-1 > PARIS-2 = %v, want 120", got)
+	}
+	if _, ok := g.length[[2]string{"PARIS-1", "PARIS-3"}]; ok {
+		t.Error("a link without length should use the default")
+	}
+}
+
+func TestGraphStoreReloads(t *testing.T) {
+	loads := 0
+	var failNext bool
+	s := &graphStore{maxAge: time.Minute}
+	s.load = func(context.Context) (roadGraph, error) {
+		if failNext {
+			return roadGraph{}, errors.New("db down")
+		}
+		loads++
+		links := make([]roadLink, loads)
+		for i := range links {
+			links[i] = roadLink{From: "A", To: string(rune('B' + i))}
+		}
+		return newRoadGraph(links), nil
+	}
+
+	t0 := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+	if g := s.get(ctx, t0); g.links != 1 {
+		t.Fatalf("first get: %d links, want 1", g.links)
+	}
+	if s.get(ctx, t0.Add(30*time.Second)); loads != 1 {
+		t.Errorf("fresh graph reloaded: %d loads", loads)
+	}
+
+	s.invalidate()
+	if g := s.get(ctx, t0.Add(40*time.Second)); g.links != 2 {
+		t.Errorf("after notification: %d links, want 2", g.links)
+	}
+	if g := s.get(ctx, t0.Add(2*time.Minute)); g.links != 3 {
+		t.Errorf("after maxAge: %d links, want 3", g.links)
+	}
+
+	failNext = true
+	s.invalidate()
+	if g := s.get(ctx, t0.Add(3*time.Minute)); g.links != 3 {
+		t.Errorf("failed reload: %d links, want the previous 3", g.links)
+	}
+	failNext = false
+	if g := s.get(ctx, t0.Add(3*time.Minute)); g.links != 4 {
+		t.Errorf("retry after failure: %d links, want 4", g.links)
+	}
+}
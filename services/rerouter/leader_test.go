@@ -0,0 +1,19 @@
+package main
+
+import "testing"
+
+func TestAdvisoryLockKey(t *testing.T) {
+	if advisoryLockKey("cityflow-rerouter") != advisoryLockKey("cityflow-rerouter") {
+		t.Error("lock key must be stable across replicas")
+	}
+	if advisoryLockKey("cityflow-rerouter") == advisoryLockKey("cityflow-predictor") {
+		t.Error("services must not share a lock key")
+	}
+}
+
+func TestLeaderElectorStartsAsStandby(t *testing.T) {
+	e := newLeaderElector(nil, "cityflow-rerouter", leaderGauge)
+	if e.isLeader() {
+		t.Error("new elector should not be leader before ensure()")
+	}
+}
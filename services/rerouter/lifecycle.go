@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	rerouteActivated = "activated"
+	rerouteUpdated   = "updated"
+	rerouteCleared   = "cleared"
+)
+
+var (
+	rerouteEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cityflow_rerouter_reroute_events_total",
+		Help: "Total number of reroute lifecycle events, by event.",
+	}, []string{"event"})
+	reroutesActive = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "cityflow_rerouter_reroutes_active",
+		Help: "Number of active reroute recommendations.",
+	})
+)
+
+// lifecycleParams keep recommendations stable enough for variable message
+// signs. A recommendation is activated when its road goes above the
+// congestion threshold, and cleared only once the road is back below
+// ClearThreshold and it has been active for MinActive. Its detour changes at
+// most once per MinActive, and a cleared road is not recommended again before
+// Cooldown has passed.
+type lifecycleParams struct {
+	ClearThreshold float64
+	MinActive      time.Duration
+	Cooldown       time.Duration
+}
+
+// loadRerouteStates returns the latest lifecycle event of each road: an
+// active recommendation unless it is a clear.
+func loadRerouteStates(ctx context.Context, dbPool *pgxpool.Pool) (map[string]Reroute, error) {
+	rows, err := dbPool.Query(ctx, `
+		SELECT DISTINCT ON (route_id) ts, route_id, alt_route_id, reason,
+			estimated_co2_gain, eta_gain_min, alt_path, diverted_vph, event, active_since
+		FROM reroutes
+		WHERE event IS NOT NULL
+		ORDER BY route_id, ts DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	states := make(map[string]Reroute)
+	for rows.Next() {
+		var r Reroute
+		if err := rows.Scan(&r.TS, &r.RouteID, &r.AltRouteID, &r.Reason, &r.EstimatedCO2Gain, &r.ETAGainMin,
+			&r.AltPath, &r.DivertedVPH, &r.Event, &r.ActiveSince); err != nil {
+			return nil, err
+		}
+		states[r.RouteID] = r
+	}
+	return states, rows.Err()
+}
+
+// reconcileReroutes merges this cycle's candidate recommendations with the
+// latest state of each road and returns the lifecycle events to store and
+// publish, in road order. A road with no candidate keeps its active
+// recommendation while its score stays at or above ClearThreshold.
+//
+// An active recommendation whose detour enters a road closed at now (see
+// roadRules) is switched to the road's candidate or cleared at once, without
+// waiting for MinActive: drivers must not be sent into a closure.
+func reconcileReroutes(states map[string]Reroute, candidates []Reroute, forecasts roadForecasts, rules roadRules, p lifecycleParams, now time.Time) []Reroute {
+	byRoad := make(map[string]Reroute, len(candidates))
+	for _, c := range candidates {
+		byRoad[c.RouteID] = c
+	}
+
+	var events []Reroute
+	for roadID, c := range byRoad {
+		state, seen := states[roadID]
+		switch {
+		case !seen || state.Event == rerouteCleared:
+			if seen && now.Sub(state.TS) < p.Cooldown {
+				continue
+			}
+			since := now
+			c.Event, c.ActiveSince = rerouteActivated, &since
+			events = append(events, c)
+		case !slices.Equal(c.AltPath, state.AltPath) && (now.Sub(state.TS) >= p.MinActive || closedOnPath(state.AltPath, rules) != ""):
+			c.Event, c.ActiveSince = rerouteUpdated, state.ActiveSince
+			events = append(events, c)
+		}
+	}
+
+	for roadID, state := range states {
+		if state.Event == rerouteCleared {
+			continue
+		}
+		cleared := state
+		cleared.TS, cleared.Event = now, rerouteCleared
+		cleared.EstimatedCO2Gain, cleared.ETAGainMin, cleared.DivertedVPH = nil, nil, nil
+
+		if closed := closedOnPath(state.AltPath, rules); closed != "" {
+			if c, ok := byRoad[roadID]; ok && !slices.Equal(c.AltPath, state.AltPath) {
+				continue // switched above
+			}
+			cleared.Reason = fmt.Sprintf("cleared: detour for %s enters %s, closed", roadID, closed)
+			events = append(events, cleared)
+			continue
+		}
+		score := forecasts.score(roadID, 0)
+		if score >= p.ClearThreshold || (state.ActiveSince != nil && now.Sub(*state.ActiveSince) < p.MinActive) {
+			continue
+		}
+		cleared.Reason = fmt.Sprintf("cleared: %.2f on %s, below %.2f", score, roadID, p.ClearThreshold)
+		events = append(events, cleared)
+	}
+
+	sort.Slice(events, func(i, j int) bool { return events[i].RouteID < events[j].RouteID })
+	return events
+}
+
+// closedOnPath returns the first road after the start of path that rules
+// close at departure, or "".
+func closedOnPath(path []string, rules roadRules) string {
+	for i := 1; i < len(path); i++ {
+		if rules.closed(path[i], 0) {
+			return path[i]
+		}
+	}
+	return ""
+}
+
+// countActive is the number of active recommendations once events apply.
+func countActive(states map[string]Reroute, events []Reroute) int {
+	active := make(map[string]bool)
+	for roadID, s := range states {
+		active[roadID] = s.Event != rerouteCleared
+	}
+	for _, e := range events {
+		active[e.RouteID] = e.Event != rerouteCleared
+	}
+	n := 0
+	for _, a := range active {
+		if a {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReconcileReroutes(t *testing.T) {
+	now := time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)
+	p := lifecycleParams{ClearThreshold: 0.4, MinActive: 10 * time.Minute, Cooldown: 10 * time.Minute}
+	ago := func(min int) time.Time { return now.Add(-time.Duration(min) * time.Minute) }
+	active := func(road string, since, last time.Time, path ...string) Reroute {
+		return Reroute{TS: last, RouteID: road, AltRouteID: path[1], AltPath: path, Event: rerouteActivated, ActiveSince: &since}
+	}
+	candidate := func(road string, path ...string) Reroute {
+		gain := 5.0
+		return Reroute{TS: now, RouteID: road, AltRouteID: path[1], AltPath: path, ETAGainMin: &gain}
+	}
+	forecasts := forecastsAt(5, map[string]RoadPrediction{
+		"NEW":      {CongestionScore: 0.8},
+		"HOVERING": {CongestionScore: 0.45},
+		"RECENT":   {CongestionScore: 0.2},
+		"EASED":    {CongestionScore: 0.2},
+		"COOLING":  {CongestionScore: 0.8},
+		"COOLED":   {CongestionScore: 0.8},
+		"SWITCHED": {CongestionScore: 0.8},
+		"STEADY":   {CongestionScore: 0.8},
+	})
+	states := map[string]Reroute{
+		"HOVERING": active("HOVERING", ago(30), ago(30), "U", "A", "D"),
+		"RECENT":   active("RECENT", ago(5), ago(5), "U", "A", "D"),
+		"EASED":    active("EASED", ago(11), ago(11), "U", "A", "D"),
+		"COOLING":  {TS: ago(5), RouteID: "COOLING", Event: rerouteCleared},
+		"COOLED":   {TS: ago(11), RouteID: "COOLED", Event: rerouteCleared},
+		"SWITCHED": active("SWITCHED", ago(40), ago(12), "U", "A", "D"),
+		"STEADY":   active("STEADY", ago(40), ago(3), "U", "A", "D"),
+	}
+	candidates := []Reroute{
+		candidate("NEW", "U", "A", "D"),
+		candidate("COOLING", "U", "A", "D"),
+		candidate("COOLED", "U", "A", "D"),
+		candidate("SWITCHED", "U", "B", "D"),
+		candidate("STEADY", "U", "B", "D"), // switched 3 minutes ago: too soon
+	}
+
+	events := reconcileReroutes(states, candidates, forecasts, roadRules{}, p, now)
+	got := make(map[string]Reroute)
+	for _, e := range events {
+		got[e.RouteID] = e
+	}
+	want := map[string]string{
+		"COOLED":   rerouteActivated,
+		"EASED":    rerouteCleared,
+		"NEW":      rerouteActivated,
+		"SWITCHED": rerouteUpdated,
+	}
+	if len(got) != len(want) {
+		t.Errorf("events = %+v, want %v", events, want)
+	}
+	for road, event := range want {
+		if got[road].Event != event {
+			t.Errorf("%s: event = %q, want %q", road, got[road].Event, event)
+		}
+	}
+
+	if e := got["NEW"]; e.ActiveSince == nil || !e.ActiveSince.Equal(now) {
+		t.Errorf("activated = %+v", e)
+	}
+	if e := got["SWITCHED"]; e.AltRouteID != "B" || e.ActiveSince == nil || !e.ActiveSince.Equal(ago(40)) {
+		t.Errorf("updated = %+v, want via B, still active since activation", e)
+	}
+	if e := got["EASED"]; !e.TS.Equal(now) || e.AltRouteID != "A" || e.ETAGainMin != nil {
+		t.Errorf("cleared = %+v", e)
+	}
+	// HOVERING, RECENT, SWITCHED and STEADY stay active; NEW and COOLED join them.
+	if n := countActive(states, events); n != 6 {
+		t.Errorf("active = %d, want 6", n)
+	}
+}
+
+func TestReconcileReroutesClosedDetour(t *testing.T) {
+	now := time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)
+	p := lifecycleParams{ClearThreshold: 0.4, MinActive: 10 * time.Minute, Cooldown: 10 * time.Minute}
+	since := now.Add(-2 * time.Minute)
+	forecasts := forecastsAt(5, map[string]RoadPrediction{
+		"JAM-1": {CongestionScore: 0.8},
+		"JAM-2": {CongestionScore: 0.8},
+		"JAM-3": {CongestionScore: 0.8},
+	})
+	// All three went live two minutes ago, well within MinActive.
+	states := map[string]Reroute{
+		"JAM-1": {TS: since, RouteID: "JAM-1", AltRouteID: "A", AltPath: []string{"U", "A", "D"}, Event: rerouteActivated, ActiveSince: &since},
+		"JAM-2": {TS: since, RouteID: "JAM-2", AltRouteID: "A", AltPath: []string{"U", "A", "D"}, Event: rerouteActivated, ActiveSince: &since},
+		"JAM-3": {TS: since, RouteID: "JAM-3", AltRouteID: "B", AltPath: []string{"U", "B", "D"}, Event: rerouteActivated, ActiveSince: &since},
+	}
+	candidates := []Reroute{{TS: now, RouteID: "JAM-1", AltRouteID: "C", AltPath: []string{"U", "C", "D"}}}
+	rules := roadRules{
+		Restrictions: roadRestrictions{"A": {{Kind: restrictionClosed, LaneRatio: 1, StartsAt: now.Add(-time.Minute)}}},
+		Depart:       now,
+	}
+
+	events := reconcileReroutes(states, candidates, forecasts, rules, p, now)
+	got := make(map[string]Reroute)
+	for _, e := range events {
+		got[e.RouteID] = e
+	}
+	if len(events) != 2 {
+		t.Errorf("events = %+v, want JAM-1 and JAM-2 only", events)
+	}
+	if e := got["JAM-1"]; e.Event != rerouteUpdated || e.AltRouteID != "C" {
+		t.Errorf("JAM-1 = %+v, want switched to C at once", e)
+	}
+	if e := got["JAM-2"]; e.Event != rerouteCleared || e.Reason != "cleared: detour for JAM-2 enters A, closed" {
+		t.Errorf("JAM-2 = %+v, want cleared at once", e)
+	}
+}
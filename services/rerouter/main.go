@@ -5,10 +5,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"math"
 	"net/http"
 	"os"
 	"os/signal"
+	"sort"
 	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
@@ -19,27 +22,37 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
-// Route adjacency map: for each road, which roads are valid alternatives.
-var adjacencyMap = map[string][]string{
-	"RING-NORTH-12":      {"RING-SOUTH-09", "CITY-CENTER-01"},
-	"RING-SOUTH-09":      {"RING-NORTH-12", "CITY-CENTER-01"},
-	"CITY-CENTER-01":     {"RING-NORTH-12", "RING-SOUTH-09"},
-	"AIRPORT-AXIS-03":    {"RING-SOUTH-09", "UNIVERSITY-LOOP-07"},
-	"UNIVERSITY-LOOP-07": {"AIRPORT-AXIS-03", "CITY-CENTER-01"},
-}
+// maxPredictionAge bounds how old the predictions a cycle uses can be.
+const maxPredictionAge = 30 * time.Minute
 
 type RoadPrediction struct {
 	RoadID          string
+	HorizonMin      int
 	CongestionScore float64
+	// UpperBound is the P90 congestion score; it equals CongestionScore for
+	// predictions stored without an interval.
+	UpperBound float64
 }
 
 type Reroute struct {
-	TS               time.Time `json:"ts"`
-	RouteID          string    `json:"route_id"`
-	AltRouteID       string    `json:"alt_route_id"`
-	Reason           string    `json:"reason"`
-	EstimatedCO2Gain *float64  `json:"estimated_co2_gain"`
-	ETAGainMin       *float64  `json:"eta_gain_min"`
+	TS         time.Time `json:"ts"`
+	RouteID    string    `json:"route_id"`
+	AltRouteID string    `json:"alt_route_id"`
+	Reason     string    `json:"reason"`
+	// EstimatedCO2Gain is the CO2 the diverted volume saves, in kg per hour
+	// (see pathCO2), and ETAGainMin the time each diverted vehicle saves over
+	// the original route.
+	EstimatedCO2Gain *float64 `json:"estimated_co2_gain"`
+	ETAGainMin       *float64 `json:"eta_gain_min"`
+	// AltPath is the whole detour, from the road before RouteID to the road
+	// after it; AltRouteID is its first road.
+	AltPath []string `json:"alt_path"`
+	// DivertedVPH is the volume, in vehicles per hour, assigned to the detour.
+	DivertedVPH *float64 `json:"diverted_vph"`
+	// Event is the lifecycle event (activated, updated or cleared) and
+	// ActiveSince when the recommendation was activated.
+	Event       string     `json:"event"`
+	ActiveSince *time.Time `json:"active_since"`
 }
 
 var (
@@ -64,9 +77,22 @@ var (
 		Help:    "Duration of a full reroute cycle.",
 		Buckets: []float64{0.1, 0.5, 1.0, 2.5, 5.0, 10.0},
 	})
+	leaderGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "cityflow_rerouter_is_leader",
+		Help: "1 if this replica holds the leader lock and runs reroute cycles.",
+	})
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "derive-links":
+			os.Exit(runDeriveLinks(os.Args[2:]))
+		case "import-network":
+			os.Exit(runImportNetwork(os.Args[2:]))
+		}
+	}
+
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
@@ -75,6 +101,25 @@ func main() {
 	metricsAddr := getEnv("METRICS_ADDR", ":8080")
 	intervalSec := getEnvInt("REROUTE_INTERVAL_SEC", 60)
 	threshold := getEnvFloat("CONGESTION_THRESHOLD", 0.5)
+	lifecycle := lifecycleParams{
+		ClearThreshold: getEnvFloat("REROUTE_CLEAR_THRESHOLD", 0.4),
+		MinActive:      time.Duration(getEnvInt("REROUTE_MIN_ACTIVE_SEC", 600)) * time.Second,
+		Cooldown:       time.Duration(getEnvInt("REROUTE_COOLDOWN_SEC", 600)) * time.Second,
+	}
+	fleet, err := parseFleetMix(getEnv("FLEET_MIX", defaultFleetMix))
+	if err != nil {
+		log.Fatalf("invalid FLEET_MIX: %v", err)
+	}
+	if lifecycle.ClearThreshold > threshold {
+		log.Printf("REROUTE_CLEAR_THRESHOLD %.2f above CONGESTION_THRESHOLD, using %.2f", lifecycle.ClearThreshold, threshold)
+		lifecycle.ClearThreshold = threshold
+	}
+	leaderRetrySec := getEnvInt("LEADER_RETRY_SEC", 5)
+	if leaderRetrySec <= 0 {
+		log.Fatalf("invalid LEADER_RETRY_SEC=%d: must be > 0", leaderRetrySec)
+	}
+	leaderRetry := time.Duration(leaderRetrySec) * time.Second
+	graphMaxAge := time.Duration(getEnvInt("GRAPH_RELOAD_SEC", 300)) * time.Second
 
 	// DB pool
 	dbPool, err := pgxpool.New(ctx, dbDSN)
@@ -101,23 +146,62 @@ func main() {
 	}
 	log.Printf("redis connected: %s", redisURL)
 
-	// HTTP health + metrics
-	go serveHTTP(metricsAddr)
+	// Road graph from road_links, reloaded when the table changes
+	graphs := newGraphStore(dbPool, graphMaxAge)
+	go graphs.listen(ctx, leaderRetry)
+
+	// HTTP health + metrics + route queries, served by every replica
+	forecasts := &routeCache[roadForecasts]{load: func(ctx context.Context, now time.Time) (roadForecasts, error) {
+		return loadForecasts(ctx, dbPool, now)
+	}}
+	restrictions := &routeCache[roadRestrictions]{load: func(ctx context.Context, now time.Time) (roadRestrictions, error) {
+		return loadRestrictions(ctx, dbPool, now)
+	}}
+	go serveHTTP(metricsAddr, &routeHandler{
+		graph:        graphs.get,
+		forecasts:    forecasts.get,
+		restrictions: restrictions.get,
+		threshold:    threshold,
+		fleet:        fleet,
+		now:          time.Now,
+	})
 
 	interval := time.Duration(intervalSec) * time.Second
 
-	log.Printf("rerouter running: interval=%s threshold=%.2f", interval, threshold)
+	log.Printf("rerouter running: interval=%s threshold=%.2f clear=%.2f min_active=%s cooldown=%s",
+		interval, threshold, lifecycle.ClearThreshold, lifecycle.MinActive, lifecycle.Cooldown)
+
+	elector := newLeaderElector(dbPool, "cityflow-rerouter", leaderGauge)
+	defer func() {
+		releaseCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		elector.release(releaseCtx)
+	}()
 
 	// Run first cycle immediately
-	runCycle(ctx, dbPool, redisClient, threshold)
+	if elector.ensure(ctx) {
+		runCycle(ctx, dbPool, redisClient, graphs, threshold, lifecycle, fleet)
+	} else {
+		log.Printf("standing by: another replica is leader")
+	}
 
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
+	leaderTicker := time.NewTicker(leaderRetry)
+	defer leaderTicker.Stop()
 
 	for {
 		select {
 		case <-ticker.C:
-			runCycle(ctx, dbPool, redisClient, threshold)
+			if elector.ensure(ctx) {
+				runCycle(ctx, dbPool, redisClient, graphs, threshold, lifecycle, fleet)
+			}
+		case <-leaderTicker.C:
+			// Standbys poll the lock so failover does not wait a full interval.
+			wasLeader := elector.isLeader()
+			if elector.ensure(ctx) && !wasLeader {
+				runCycle(ctx, dbPool, redisClient, graphs, threshold, lifecycle, fleet)
+			}
 		case <-ctx.Done():
 			log.Printf("rerouter shutting down")
 			return
@@ -125,7 +209,7 @@ func main() {
 	}
 }
 
-func runCycle(ctx context.Context, dbPool *pgxpool.Pool, redisClient *redis.Client, threshold float64) {
+func runCycle(ctx context.Context, dbPool *pgxpool.Pool, redisClient *redis.Client, graphs *graphStore, threshold float64, lifecycle lifecycleParams, fleet fleetMix) {
 	start := time.Now()
 	defer func() {
 		cycleDuration.Observe(time.Since(start).Seconds())
@@ -133,122 +217,206 @@ func runCycle(ctx context.Context, dbPool *pgxpool.Pool, redisClient *redis.Clie
 
 	now := time.Now().UTC().Truncate(time.Second)
 
-	// Get latest prediction per road
-	rows, err := dbPool.Query(ctx, `
-		SELECT DISTINCT ON (road_id) road_id, congestion_score
-		FROM predictions
-		ORDER BY road_id, ts DESC
-	`)
+	forecasts, err := loadForecasts(ctx, dbPool, now)
 	if err != nil {
 		reroutesFailed.Inc()
 		log.Printf("query predictions failed: %v", err)
 		return
 	}
+
+	if len(forecasts) == 0 {
+		log.Printf("no predictions available, skipping")
+		return
+	}
+
+	graph := graphs.get(ctx, now)
+	if graph.links == 0 {
+		log.Printf("road graph is empty, no alternatives to recommend (fill road_links)")
+		return
+	}
+
+	flows, err := loadFlows(ctx, dbPool, now)
+	if err != nil {
+		// Flows only refine the assignment; estimate them from the scores.
+		log.Printf("query flows failed, estimating from predictions: %v", err)
+	}
+
+	restrictions, err := loadRestrictions(ctx, dbPool, now)
+	if err != nil {
+		log.Printf("query road restrictions failed, routing without them: %v", err)
+	}
+
+	rules := roadRules{Restrictions: restrictions, Depart: now}
+	reroutes := selectReroutes(forecasts, flows, graph, rules, threshold, fleet, now)
+	reroutesGenerated.Add(float64(len(reroutes)))
+
+	states, err := loadRerouteStates(ctx, dbPool)
+	if err != nil {
+		reroutesFailed.Inc()
+		log.Printf("query reroute states failed: %v", err)
+		return
+	}
+	events := reconcileReroutes(states, reroutes, forecasts, rules, lifecycle, now)
+	reroutesActive.Set(float64(countActive(states, events)))
+
+	if len(events) == 0 {
+		log.Printf("reroute cycle: %d candidates above threshold %.2f, no lifecycle change (%d roads)", len(reroutes), threshold, len(forecasts))
+		return
+	}
+
+	// Only stored events are published, so that subscribers never see a
+	// transition the next cycle would not know about.
+	stored := storeReroutes(ctx, dbPool, events)
+	published := publishReroutes(ctx, redisClient, stored)
+
+	log.Printf("reroute cycle completed: %d candidates, %d events, %d stored, %d published (%.2fs)",
+		len(reroutes), len(events), len(stored), published, time.Since(start).Seconds())
+}
+
+// loadForecasts reads the latest prediction of each road and horizon made in
+// the last maxPredictionAge.
+func loadForecasts(ctx context.Context, dbPool *pgxpool.Pool, now time.Time) (roadForecasts, error) {
+	rows, err := dbPool.Query(ctx, `
+		SELECT DISTINCT ON (road_id, horizon_min) road_id, horizon_min, congestion_score,
+			COALESCE(congestion_p90, congestion_score)
+		FROM predictions
+		WHERE ts >= $1
+		ORDER BY road_id, horizon_min, ts DESC
+	`, now.Add(-maxPredictionAge))
+	if err != nil {
+		return nil, err
+	}
 	defer rows.Close()
 
-	// Build map of road -> congestion score
-	scores := make(map[string]float64)
+	forecasts := make(roadForecasts)
 	for rows.Next() {
 		var rp RoadPrediction
-		if err := rows.Scan(&rp.RoadID, &rp.CongestionScore); err != nil {
-			reroutesFailed.Inc()
-			log.Printf("row scan failed: %v", err)
-			continue
+		if err := rows.Scan(&rp.RoadID, &rp.HorizonMin, &rp.CongestionScore, &rp.UpperBound); err != nil {
+			return nil, err
 		}
-		scores[rp.RoadID] = rp.CongestionScore
+		if forecasts[rp.RoadID] == nil {
+			forecasts[rp.RoadID] = make(map[int]RoadPrediction)
+		}
+		forecasts[rp.RoadID][rp.HorizonMin] = rp
 	}
-	if rows.Err() != nil {
-		reroutesFailed.Inc()
-		log.Printf("rows iteration error: %v", rows.Err())
-		return
+	return forecasts, rows.Err()
+}
+
+// selectReroutes recommends, for each road predicted above threshold, the
+// detour around it that saves the most time, as long as the detour is
+// meaningfully less congested and has room for part of the road's flow.
+func selectReroutes(forecasts roadForecasts, flows roadFlows, graph roadGraph, rules roadRules, threshold float64, fleet fleetMix, now time.Time) []Reroute {
+	graph = graph.withLaneCapacity(rules)
+	cost := func(from, to string, atMin float64) float64 {
+		return graph.travelMin(from, to, forecasts, atMin) * rules.slowdown(to, atMin)
 	}
+	ledger := newDivertLedger(graph, flows, forecasts)
 
-	if len(scores) == 0 {
-		log.Printf("no predictions available, skipping")
-		return
+	var congested []string
+	for roadID := range forecasts {
+		if forecasts.score(roadID, 0) > threshold {
+			congested = append(congested, roadID)
+		}
 	}
+	sort.Slice(congested, func(i, j int) bool {
+		si, sj := forecasts.score(congested[i], 0), forecasts.score(congested[j], 0)
+		return si > sj || (si == sj && congested[i] < congested[j])
+	})
 
-	// Generate reroute recommendations
 	var reroutes []Reroute
-	for roadID, score := range scores {
-		if score <= threshold {
-			continue
+	for _, roadID := range congested {
+		score := forecasts.score(roadID, 0)
+		volume := ledger.divertible(roadID)
+		// Each road is judged on the prediction for the time drivers would
+		// enter it; those above threshold at P90 then are never entered.
+		avoid := func(r string, atMin float64) bool {
+			if rp, ok := forecasts.at(r, atMin); ok && rp.UpperBound > threshold {
+				return true
+			}
+			return ledger.full(r, volume)
 		}
 
-		alternatives, ok := adjacencyMap[roadID]
+		route, alt, ok := graph.detour(roadID, cost, avoid, rules.closed)
 		if !ok {
 			continue
 		}
 
-		// Find the least congested alternative
-		bestAlt := ""
-		bestAltScore := 1.0
-		for _, alt := range alternatives {
-			altScore, exists := scores[alt]
-			if !exists {
-				continue
-			}
-			if altScore < bestAltScore {
-				bestAlt = alt
-				bestAltScore = altScore
-			}
+		// The detour proper, without the roads shared with the original route;
+		// a direct link between them leaves only the downstream road.
+		first, last := 1, len(alt.Roads)-1
+		if first == last {
+			last++
 		}
-
-		if bestAlt == "" {
-			continue
+		via := alt.Roads[first:last]
+		detourScore := 0.0
+		var banned []string
+		for i := first; i < last; i++ {
+			detourScore = math.Max(detourScore, forecasts.score(alt.Roads[i], alt.At[i]))
+			if rules.Restrictions.has(alt.Roads[i], restrictionHGVBanned, rules.at(alt.At[i])) {
+				banned = append(banned, alt.Roads[i])
+			}
 		}
 
 		// Only recommend if alternative is meaningfully better
-		delta := score - bestAltScore
+		delta := score - detourScore
 		if delta < 0.1 {
 			continue
 		}
 
-		etaGain := delta * 15.0
-		co2Gain := delta * 2.5
-		reason := fmt.Sprintf("high-congestion: %.2f on %s, reroute to %s (%.2f)", score, roadID, bestAlt, bestAltScore)
+		// Traffic reaches the downstream road either way, so only the roads in
+		// between take extra load; a direct link enters the downstream road
+		// from another side, which must then have room for it. A detour with
+		// no room left is not worth recommending.
+		diverted := math.Round(ledger.assign(via, volume))
+		if diverted <= 0 {
+			continue
+		}
+		etaGain := math.Round((route.Minutes-alt.Minutes)*100) / 100
+		savedGrams := graph.pathCO2(route, forecasts, fleet, 0) - graph.pathCO2(alt, forecasts, fleet, 0)
+		co2Gain := math.Round(savedGrams*diverted) / 1000
+		reason := fmt.Sprintf("high-congestion: %.2f on %s, reroute %.0f veh/h via %s (%.2f), saving %.1f min each",
+			score, roadID, diverted, strings.Join(via, " > "), detourScore, etaGain)
+		if len(banned) > 0 {
+			reason += fmt.Sprintf("; %s closed to heavy vehicles", strings.Join(banned, ", "))
+		}
 
 		reroutes = append(reroutes, Reroute{
 			TS:               now,
 			RouteID:          roadID,
-			AltRouteID:       bestAlt,
+			AltRouteID:       via[0],
 			Reason:           reason,
 			EstimatedCO2Gain: &co2Gain,
 			ETAGainMin:       &etaGain,
+			AltPath:          alt.Roads,
+			DivertedVPH:      &diverted,
 		})
-		reroutesGenerated.Inc()
 	}
-
-	if len(reroutes) == 0 {
-		log.Printf("reroute cycle: no congested roads above threshold %.2f (scores: %v)", threshold, scores)
-		return
-	}
-
-	stored := storeReroutes(ctx, dbPool, reroutes)
-	published := publishReroutes(ctx, redisClient, reroutes)
-
-	log.Printf("reroute cycle completed: %d recommendations, %d stored, %d published (%.2fs)",
-		len(reroutes), stored, published, time.Since(start).Seconds())
+	return reroutes
 }
 
-func storeReroutes(ctx context.Context, dbPool *pgxpool.Pool, reroutes []Reroute) int {
-	stored := 0
+func storeReroutes(ctx context.Context, dbPool *pgxpool.Pool, reroutes []Reroute) []Reroute {
+	var stored []Reroute
 	for _, r := range reroutes {
 		_, err := dbPool.Exec(ctx, `
-			INSERT INTO reroutes (ts, route_id, alt_route_id, reason, estimated_co2_gain, eta_gain_min)
-			VALUES ($1, $2, $3, $4, $5, $6)
+			INSERT INTO reroutes (ts, route_id, alt_route_id, reason, estimated_co2_gain, eta_gain_min, alt_path, diverted_vph, event, active_since)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
 			ON CONFLICT (ts, route_id, alt_route_id) DO UPDATE SET
 				reason = EXCLUDED.reason,
 				estimated_co2_gain = EXCLUDED.estimated_co2_gain,
-				eta_gain_min = EXCLUDED.eta_gain_min
-		`, r.TS, r.RouteID, r.AltRouteID, r.Reason, r.EstimatedCO2Gain, r.ETAGainMin)
+				eta_gain_min = EXCLUDED.eta_gain_min,
+				alt_path = EXCLUDED.alt_path,
+				diverted_vph = EXCLUDED.diverted_vph,
+				event = EXCLUDED.event,
+				active_since = EXCLUDED.active_since
+		`, r.TS, r.RouteID, r.AltRouteID, r.Reason, r.EstimatedCO2Gain, r.ETAGainMin, r.AltPath, r.DivertedVPH, r.Event, r.ActiveSince)
 		if err != nil {
 			reroutesFailed.Inc()
 			log.Printf("db insert failed for route=%s: %v", r.RouteID, err)
 			continue
 		}
 		reroutesStored.Inc()
-		stored++
+		rerouteEvents.WithLabelValues(r.Event).Inc()
+		stored = append(stored, r)
 	}
 	return stored
 }
@@ -271,9 +439,10 @@ func publishReroutes(ctx context.Context, redisClient *redis.Client, reroutes []
 	return published
 }
 
-func serveHTTP(addr string) {
+func serveHTTP(addr string, routes http.Handler) {
 	mux := http.NewServeMux()
 	mux.Handle("/metrics", promhttp.Handler())
+	mux.Handle("/routes", routes)
 	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		fmt.Fprint(w, "ok")
@@ -2,38 +2,22 @@ package main
 
 import (
 	"os"
+	"reflect"
 	"testing"
+	"time"
 )
 
-func TestAdjacencyMap(t *testing.T) {
-	expectedRoads := []string{
-		"RING-NORTH-12", "RING-SOUTH-09", "CITY-CENTER-01",
-		"AIRPORT-AXIS-03", "UNIVERSITY-LOOP-07",
-	}
-
-	for _, road := range expectedRoads {
-		alts, ok := adjacencyMap[road]
-		if !ok {
-			t.Errorf("road %q missing from adjacencyMap", road)
-			continue
-		}
-		if len(alts) == 0 {
-			t.Errorf("road %q has no alternatives", road)
-		}
-	}
-}
-
-func TestAdjacencyMapAlternativesExist(t *testing.T) {
-	for road, alts := range adjacencyMap {
-		for _, alt := range alts {
-			if _, ok := adjacencyMap[alt]; !ok {
-				t.Errorf("road %q lists %q as alternative, but %q is not in adjacencyMap", road, alt, alt)
-			}
-			if alt == road {
-				t.Errorf("road %q lists itself as alternative", road)
-			}
-		}
-	}
+// testGraph splits traffic from AIRPORT-AXIS-03 over three parallel roads
+// that all lead to PERIPH-EAST-04.
+func testGraph() roadGraph {
+	return newRoadGraph([]roadLink{
+		{From: "AIRPORT-AXIS-03", To: "RING-NORTH-12"},
+		{From: "AIRPORT-AXIS-03", To: "RING-SOUTH-09"},
+		{From: "AIRPORT-AXIS-03", To: "CITY-CENTER-01"},
+		{From: "RING-NORTH-12", To: "PERIPH-EAST-04"},
+		{From: "RING-SOUTH-09", To: "PERIPH-EAST-04"},
+		{From: "CITY-CENTER-01", To: "PERIPH-EAST-04"},
+	})
 }
 
 func TestRerouteDecisionLogic(t *testing.T) {
@@ -42,6 +26,7 @@ func TestRerouteDecisionLogic(t *testing.T) {
 	tests := []struct {
 		name        string
 		scores      map[string]float64
+		upper       map[string]float64
 		wantReroute bool
 		wantFrom    string
 		wantTo      string
@@ -74,47 +59,48 @@ func TestRerouteDecisionLogic(t *testing.T) {
 			},
 			wantReroute: false,
 		},
+		{
+			name: "alternative skipped when its upper bound is congested",
+			scores: map[string]float64{
+				"RING-NORTH-12":  0.8,
+				"RING-SOUTH-09":  0.3,
+				"CITY-CENTER-01": 0.4,
+			},
+			upper: map[string]float64{
+				"RING-SOUTH-09":  0.65,
+				"CITY-CENTER-01": 0.45,
+			},
+			wantReroute: true,
+			wantFrom:    "RING-NORTH-12",
+			wantTo:      "CITY-CENTER-01",
+		},
+		{
+			name: "no reroute when every alternative may be congested",
+			scores: map[string]float64{
+				"RING-NORTH-12":  0.8,
+				"RING-SOUTH-09":  0.3,
+				"CITY-CENTER-01": 0.4,
+			},
+			upper: map[string]float64{
+				"RING-SOUTH-09":  0.6,
+				"CITY-CENTER-01": 0.7,
+			},
+			wantReroute: false,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			var reroutes []Reroute
+			preds := make(map[string]RoadPrediction, len(tt.scores))
 			for roadID, score := range tt.scores {
-				if score <= threshold {
-					continue
-				}
-				alternatives, ok := adjacencyMap[roadID]
-				if !ok {
-					continue
-				}
-				bestAlt := ""
-				bestAltScore := 1.0
-				for _, alt := range alternatives {
-					altScore, exists := tt.scores[alt]
-					if !exists {
-						continue
-					}
-					if altScore < bestAltScore {
-						bestAlt = alt
-						bestAltScore = altScore
-					}
-				}
-				if bestAlt == "" {
-					continue
-				}
-				delta := score - bestAltScore
-				if delta < 0.1 {
-					continue
-				}
-				etaGain := delta * 15.0
-				co2Gain := delta * 2.5
-				reroutes = append(reroutes, Reroute{
-					RouteID:          roadID,
-					AltRouteID:       bestAlt,
-					EstimatedCO2Gain: &co2Gain,
-					ETAGainMin:       &etaGain,
-				})
+				preds[roadID] = RoadPrediction{RoadID: roadID, CongestionScore: score, UpperBound: score}
 			}
+			for roadID, upper := range tt.upper {
+				rp := preds[roadID]
+				rp.UpperBound = upper
+				preds[roadID] = rp
+			}
+			reroutes := selectReroutes(forecastsAt(30, preds), nil, testGraph(), roadRules{}, threshold, nil, time.Now())
 
 			gotReroute := len(reroutes) > 0
 			if gotReroute != tt.wantReroute {
@@ -132,6 +118,9 @@ func TestRerouteDecisionLogic(t *testing.T) {
 						if r.ETAGainMin == nil || *r.ETAGainMin <= 0 {
 							t.Error("ETA gain should be positive")
 						}
+						if want := []string{"AIRPORT-AXIS-03", tt.wantTo, "PERIPH-EAST-04"}; !reflect.DeepEqual(r.AltPath, want) {
+							t.Errorf("alt path = %v, want %v", r.AltPath, want)
+						}
 					}
 				}
 				if !found {
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+// testOSM is a T junction: residential ways 10 (1-2) and 11 (2-3) continue
+// each other, one-way primary 20 leaves node 2 towards node 4, and turning
+// from 10 into 20 is forbidden. The footway is not imported.
+const testOSM = `<?xml version="1.0" encoding="UTF-8"?>
+<osm version="0.6">
+  <node id="1" lat="48.8566" lon="2.3522"/>
+  <node id="2" lat="48.8576" lon="2.3522"/>
+  <node id="3" lat="48.8586" lon="2.3522"/>
+  <node id="4" lat="48.8576" lon="2.3537"/>
+  <way id="10"><nd ref="1"/><nd ref="2"/><tag k="highway" v="residential"/><tag k="name" v="Rue A"/></way>
+  <way id="11"><nd ref="2"/><nd ref="3"/><tag k="highway" v="residential"/><tag k="maxspeed" v="FR:zone30"/></way>
+  <way id="20"><nd ref="2"/><nd ref="4"/><tag k="highway" v="primary"/><tag k="oneway" v="yes"/><tag k="lanes" v="2"/></way>
+  <way id="30"><nd ref="1"/><nd ref="4"/><tag k="highway" v="footway"/></way>
+  <relation id="100">
+    <member type="way" ref="10" role="from"/>
+    <member type="node" ref="2" role="via"/>
+    <member type="way" ref="20" role="to"/>
+    <tag k="type" v="restriction"/><tag k="restriction" v="no_left_turn"/>
+  </relation>
+</osm>`
+
+func importTestOSM(t *testing.T) roadNetwork {
+	t.Helper()
+	raw, err := parseOSMXML(strings.NewReader(testOSM))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buildNetwork(raw)
+}
+
+func TestBuildNetworkFromOSM(t *testing.T) {
+	rn := importTestOSM(t)
+
+	if len(rn.Segments) != 3 {
+		t.Fatalf("got %d segments, want 3 (the footway is skipped)", len(rn.Segments))
+	}
+	a := rn.Segments[0]
+	if a.ID != "osm:10:0" || a.Name != "Rue A" || a.RoadClass != "local" || a.SpeedLimitKMH != 30 {
+		t.Errorf("segment = %+v", a)
+	}
+	if math.Abs(a.LengthM-111.2) > 0.5 {
+		t.Errorf("length = %.1f m, want about 111.2", a.LengthM)
+	}
+	if s := rn.Segments[2]; s.RoadClass != "arterial" || s.Oneway != 1 || s.Lanes == nil || *s.Lanes != 2 || s.SpeedLimitKMH != 50 {
+		t.Errorf("primary segment = %+v", s)
+	}
+
+	got := make(map[string]string)
+	for _, l := range rn.roadLinks() {
+		r := ""
+		if l.Restriction != nil {
+			r = *l.Restriction
+		}
+		got[l.From+">"+l.To] = r
+	}
+	want := map[string]string{
+		"osm:10:0>osm:11:0": "",
+		"osm:10:0>osm:20:0": "no_left_turn",
+		"osm:11:0>osm:10:0": "",
+		"osm:11:0>osm:20:0": "",
+	}
+	if len(got) != len(want) {
+		t.Errorf("links = %v, want %v", got, want)
+	}
+	for k, r := range want {
+		if gr, ok := got[k]; !ok || gr != r {
+			t.Errorf("link %s = %q (present %v), want %q", k, gr, ok, r)
+		}
+	}
+}
+
+func TestBuildNetworkSplitsAtJunctions(t *testing.T) {
+	raw := rawNetwork{
+		Source: "osm",
+		Nodes:  map[string][2]float64{"1": {2.35, 48.85}, "2": {2.35, 48.851}, "3": {2.35, 48.852}, "4": {2.351, 48.851}},
+		Ways: []rawWay{
+			{ID: "10", Tags: map[string]string{"highway": "tertiary"}, Nodes: []string{"1", "2", "3"}},
+			{ID: "20", Tags: map[string]string{"highway": "tertiary", "oneway": "-1"}, Nodes: []string{"4", "2"}},
+			{ID: "30", Tags: map[string]string{"highway": "tertiary"}, Nodes: []string{"3", "9"}},
+		},
+	}
+	rn := buildNetwork(raw)
+	if len(rn.Segments) != 3 {
+		t.Fatalf("got %d segments, want way 10 split in two plus way 20 (way 30 has a missing node)", len(rn.Segments))
+	}
+
+	links := rn.roadLinks()
+	var fromReverse, intoReverse int
+	for _, l := range links {
+		if l.From == "osm:20:0" {
+			fromReverse++
+		}
+		if l.To == "osm:20:0" {
+			intoReverse++
+		}
+	}
+	// Way 20 is drawn 4->2 but one-way 2->4: it can be entered at node 2, never left there.
+	if fromReverse != 0 || intoReverse != 2 {
+		t.Errorf("links = %+v; want two links into osm:20:0 and none out of it", links)
+	}
+}
+
+func TestParseGeoJSON(t *testing.T) {
+	const doc = `{"type": "FeatureCollection", "features": [
+		{"type": "Feature", "id": 7, "properties": {"highway": "secondary", "maxspeed": "30 mph", "lanes": 3, "oneway": true},
+		 "geometry": {"type": "LineString", "coordinates": [[2.3522, 48.8566], [2.3522, 48.8576]]}},
+		{"type": "Feature", "properties": {"name": "Quai"},
+		 "geometry": {"type": "MultiLineString", "coordinates": [[[2.3522, 48.8576], [2.3537, 48.8576]], [[2.3537, 48.8576], [2.3537, 48.8586]]]}},
+		{"type": "Feature", "properties": {}, "geometry": {"type": "Point", "coordinates": [2.35, 48.85]}}
+	]}`
+	raw, err := parseGeoJSON(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rn := buildNetwork(raw)
+	if len(rn.Segments) != 3 {
+		t.Fatalf("got %d segments, want 3", len(rn.Segments))
+	}
+	s := rn.Segments[0]
+	if s.ID != "geojson:7:0" || s.SpeedLimitKMH != 48 || s.Oneway != 1 || s.Lanes == nil || *s.Lanes != 3 {
+		t.Errorf("segment = %+v", s)
+	}
+	if q := rn.Segments[1]; q.ID != "geojson:1-0:0" || q.Highway != "unclassified" || q.Name != "Quai" {
+		t.Errorf("segment = %+v", q)
+	}
+	if links := rn.roadLinks(); len(links) != 3 {
+		t.Errorf("links = %+v; want 7->1-0 and both ways between 1-0 and 1-1", links)
+	}
+}
+
+func TestMapSensors(t *testing.T) {
+	rn := importTestOSM(t)
+	sensors := []roadPoint{
+		{RoadID: "RUE-A", Lat: 48.8571, Lng: 2.35223},  // about 2 m from way 10
+		{RoadID: "RUE-A2", Lat: 48.8571, Lng: 2.35230}, // also nearest to way 10, but farther
+		{RoadID: "FAR", Lat: 48.90, Lng: 2.40},
+	}
+	mappings, unmapped := rn.mapSensors(sensors, 50)
+
+	if len(mappings) != 1 || mappings[0].RoadID != "RUE-A" || mappings[0].SegmentID != "osm:10:0" || mappings[0].DistanceM > 3 {
+		t.Errorf("mappings = %+v", mappings)
+	}
+	if strings.Join(unmapped, ",") != "FAR,RUE-A2" {
+		t.Errorf("unmapped = %v", unmapped)
+	}
+	for _, l := range rn.roadLinks() {
+		if l.From == "osm:10:0" || l.To == "osm:10:0" {
+			t.Errorf("link %+v still uses the segment id of a mapped segment", l)
+		}
+	}
+	if rn.Segments[0].RoadID != "RUE-A" || rn.Segments[0].SensorDistanceM == nil {
+		t.Errorf("segment = %+v", rn.Segments[0])
+	}
+}
+
+func TestMapSensorsFallsBackOnNextNearestSegment(t *testing.T) {
+	rn := importTestOSM(t)
+	sensors := []roadPoint{
+		{RoadID: "RUE-A", Lat: 48.8571, Lng: 2.35223},
+		// Nearest to way 10 too, which RUE-A holds: primary 20 is next, about 56 m away.
+		{RoadID: "RUE-A2", Lat: 48.8571, Lng: 2.35230},
+	}
+	mappings, unmapped := rn.mapSensors(sensors, 100)
+
+	got := make(map[string]string)
+	for _, m := range mappings {
+		got[m.RoadID] = m.SegmentID
+	}
+	if got["RUE-A"] != "osm:10:0" || got["RUE-A2"] != "osm:20:0" || len(unmapped) != 0 {
+		t.Errorf("mappings = %+v, unmapped = %v; want RUE-A on way 10 and RUE-A2 on way 20", mappings, unmapped)
+	}
+}
+
+func TestSegmentGridFindsSegmentsWithinCell(t *testing.T) {
+	// A 20x20 street grid of 100 m blocks, with one diagonal avenue across it.
+	var segments []roadSegment
+	const step = 0.0009 // about 100 m of latitude
+	for i := 0; i < 20; i++ {
+		for j := 0; j < 20; j++ {
+			lng, lat := 2.30+float64(i)*step*1.5, 48.84+float64(j)*step
+			segments = append(segments,
+				roadSegment{ID: fmt.Sprintf("h%d-%d", i, j), Geometry: [][2]float64{{lng, lat}, {lng + step*1.5, lat}}},
+				roadSegment{ID: fmt.Sprintf("v%d-%d", i, j), Geometry: [][2]float64{{lng, lat}, {lng, lat + step}}},
+			)
+		}
+	}
+	segments = append(segments, roadSegment{ID: "avenue", Geometry: [][2]float64{{2.30, 48.84}, {2.33, 48.85}, {2.345, 48.858}}})
+
+	const cellM = 50
+	grid := newSegmentGrid(segments, cellM)
+	for k := 0; k < 400; k++ {
+		p := roadPoint{Lat: 48.839 + float64(k%20)*0.00097, Lng: 2.299 + float64(k/20)*0.0024}
+		near := make(map[int]bool)
+		for _, i := range grid.near(p) {
+			near[i] = true
+		}
+		for i, s := range segments {
+			if distanceToLineM(p, s.Geometry) <= cellM && !near[i] {
+				t.Fatalf("segment %s is within %d m of %+v but not in the cells around it", s.ID, cellM, p)
+			}
+		}
+	}
+}
+
+func TestParseMaxSpeed(t *testing.T) {
+	for in, want := range map[string]float64{"50": 50, "30 mph": 48, "FR:urban": 50, "70;50": 70} {
+		if got, ok := parseMaxSpeed(in); !ok || got != want {
+			t.Errorf("parseMaxSpeed(%q) = %v, %v; want %v", in, got, ok, want)
+		}
+	}
+	for _, in := range []string{"", "none", "signals", "-5"} {
+		if _, ok := parseMaxSpeed(in); ok {
+			t.Errorf("parseMaxSpeed(%q) should fail", in)
+		}
+	}
+}
+
+func TestNetworkFormat(t *testing.T) {
+	if f, err := networkFormat("paris.osm", ""); err != nil || f != "osm" {
+		t.Errorf("paris.osm: %s, %v", f, err)
+	}
+	if f, err := networkFormat("roads.json", "osm"); err != nil || f != "osm" {
+		t.Errorf("-format should win over the extension: %s, %v", f, err)
+	}
+	if f, err := networkFormat("ile-de-france.osm.pbf", ""); err != nil || f != "pbf" {
+		t.Errorf("ile-de-france.osm.pbf: %s, %v", f, err)
+	}
+	if _, err := networkFormat("roads.csv", ""); err == nil {
+		t.Error("roads.csv: expected an error")
+	}
+}
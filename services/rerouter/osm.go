@@ -0,0 +1,243 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// rawWay is a polyline read from an extract, before it is split into
+// segments. Nodes are keys of rawNetwork.Nodes: OSM node ids, or rounded
+// coordinates for GeoJSON, so that lines sharing a vertex share a node.
+type rawWay struct {
+	ID    string
+	Tags  map[string]string
+	Nodes []string
+}
+
+// rawRestriction is an OSM turn restriction relation: turning from way From
+// into way To at node Via is forbidden.
+type rawRestriction struct {
+	From, Via, To string
+	Kind          string
+}
+
+type rawNetwork struct {
+	Source       string
+	Nodes        map[string][2]float64 // [lng, lat]
+	Ways         []rawWay
+	Restrictions []rawRestriction
+}
+
+type osmTag struct {
+	K string `xml:"k,attr"`
+	V string `xml:"v,attr"`
+}
+
+type osmNode struct {
+	ID  string  `xml:"id,attr"`
+	Lat float64 `xml:"lat,attr"`
+	Lon float64 `xml:"lon,attr"`
+}
+
+type osmWay struct {
+	ID  string `xml:"id,attr"`
+	Nds []struct {
+		Ref string `xml:"ref,attr"`
+	} `xml:"nd"`
+	Tags []osmTag `xml:"tag"`
+}
+
+type osmMember struct {
+	Type string `xml:"type,attr"`
+	Ref  string `xml:"ref,attr"`
+	Role string `xml:"role,attr"`
+}
+
+type osmRelation struct {
+	ID      string      `xml:"id,attr"`
+	Members []osmMember `xml:"member"`
+	Tags    []osmTag    `xml:"tag"`
+}
+
+func tagMap(tags []osmTag) map[string]string {
+	m := make(map[string]string, len(tags))
+	for _, t := range tags {
+		m[t.K] = t.V
+	}
+	return m
+}
+
+// parseOSMXML reads an OSM XML extract (.osm) element by element. Only no_*
+// turn restrictions with a via node are kept; only_* restrictions and via ways
+// are ignored.
+func parseOSMXML(r io.Reader) (rawNetwork, error) {
+	out := rawNetwork{Source: "osm", Nodes: make(map[string][2]float64)}
+	dec := xml.NewDecoder(r)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return out, err
+		}
+		se, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch se.Name.Local {
+		case "node":
+			var n osmNode
+			if err := dec.DecodeElement(&n, &se); err != nil {
+				return out, err
+			}
+			out.Nodes[n.ID] = [2]float64{n.Lon, n.Lat}
+		case "way":
+			var w osmWay
+			if err := dec.DecodeElement(&w, &se); err != nil {
+				return out, err
+			}
+			way := rawWay{ID: w.ID, Tags: tagMap(w.Tags)}
+			for _, nd := range w.Nds {
+				way.Nodes = append(way.Nodes, nd.Ref)
+			}
+			out.Ways = append(out.Ways, way)
+		case "relation":
+			var rel osmRelation
+			if err := dec.DecodeElement(&rel, &se); err != nil {
+				return out, err
+			}
+			if r, ok := restrictionOf(rel); ok {
+				out.Restrictions = append(out.Restrictions, r)
+			}
+		}
+	}
+	return out, nil
+}
+
+func restrictionOf(rel osmRelation) (rawRestriction, bool) {
+	tags := tagMap(rel.Tags)
+	kind := tags["restriction"]
+	if kind == "" {
+		kind = tags["restriction:motorcar"]
+	}
+	if tags["type"] != "restriction" || !strings.HasPrefix(kind, "no_") {
+		return rawRestriction{}, false
+	}
+
+	r := rawRestriction{Kind: kind}
+	for _, m := range rel.Members {
+		switch {
+		case m.Role == "from" && m.Type == "way":
+			r.From = m.Ref
+		case m.Role == "to" && m.Type == "way":
+			r.To = m.Ref
+		case m.Role == "via" && m.Type == "node":
+			r.Via = m.Ref
+		}
+	}
+	return r, r.From != "" && r.To != "" && r.Via != ""
+}
+
+type geoJSONFeature struct {
+	ID       any `json:"id"`
+	Geometry *struct {
+		Type        string          `json:"type"`
+		Coordinates json.RawMessage `json:"coordinates"`
+	} `json:"geometry"`
+	Properties map[string]any `json:"properties"`
+}
+
+// parseGeoJSON reads a FeatureCollection of LineString and MultiLineString
+// features. Properties use the OSM tag names (highway, name, maxspeed, lanes,
+// oneway); features without highway are taken as unclassified roads. Lines
+// are connected where they share a vertex.
+func parseGeoJSON(r io.Reader) (rawNetwork, error) {
+	var fc struct {
+		Type     string           `json:"type"`
+		Features []geoJSONFeature `json:"features"`
+	}
+	if err := json.NewDecoder(r).Decode(&fc); err != nil {
+		return rawNetwork{}, err
+	}
+	if fc.Type != "FeatureCollection" {
+		return rawNetwork{}, fmt.Errorf("expected a FeatureCollection, got %q", fc.Type)
+	}
+
+	out := rawNetwork{Source: "geojson", Nodes: make(map[string][2]float64)}
+	for i, f := range fc.Features {
+		if f.Geometry == nil {
+			continue
+		}
+		var lines [][][2]float64
+		switch f.Geometry.Type {
+		case "LineString":
+			var line [][2]float64
+			if err := json.Unmarshal(f.Geometry.Coordinates, &line); err != nil {
+				return out, fmt.Errorf("feature %d: %w", i, err)
+			}
+			lines = [][][2]float64{line}
+		case "MultiLineString":
+			if err := json.Unmarshal(f.Geometry.Coordinates, &lines); err != nil {
+				return out, fmt.Errorf("feature %d: %w", i, err)
+			}
+		default:
+			continue
+		}
+
+		tags := make(map[string]string, len(f.Properties))
+		for k, v := range f.Properties {
+			tags[k] = propertyString(v)
+		}
+		if tags["highway"] == "" {
+			tags["highway"] = "unclassified"
+		}
+		id := featureID(f, i)
+		for j, line := range lines {
+			way := rawWay{ID: id, Tags: tags}
+			if len(lines) > 1 {
+				way.ID = fmt.Sprintf("%s-%d", id, j)
+			}
+			for _, c := range line {
+				key := fmt.Sprintf("%.7f,%.7f", c[0], c[1])
+				out.Nodes[key] = c
+				way.Nodes = append(way.Nodes, key)
+			}
+			out.Ways = append(out.Ways, way)
+		}
+	}
+	return out, nil
+}
+
+func featureID(f geoJSONFeature, index int) string {
+	if f.ID != nil {
+		return propertyString(f.ID)
+	}
+	if id, ok := f.Properties["id"]; ok && id != nil {
+		return propertyString(id)
+	}
+	return strconv.Itoa(index)
+}
+
+func propertyString(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		if v {
+			return "yes"
+		}
+		return "no"
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,441 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Size limits of the OSM PBF format: a BlobHeader is at most 64 KiB and a
+// Blob, compressed or not, at most 32 MiB.
+const (
+	pbfMaxHeaderSize = 64 << 10
+	pbfMaxBlobSize   = 32 << 20
+)
+
+// pbfFeatures are the required features of an OSMHeader block this reader
+// understands; an extract needing any other one is rejected.
+var pbfFeatures = map[string]bool{"OsmSchema-V0.6": true, "DenseNodes": true}
+
+// parsePBF reads an OSM PBF extract (.osm.pbf) into the same rawNetwork as
+// parseOSMXML: node coordinates, ways with their tags and no_* turn
+// restrictions with a via node. Blobs must be raw or zlib-compressed, which
+// is what osmium and the Geofabrik extracts use.
+func parsePBF(r io.Reader) (rawNetwork, error) {
+	out := rawNetwork{Source: "osm", Nodes: make(map[string][2]float64)}
+	var size [4]byte
+	for {
+		if _, err := io.ReadFull(r, size[:]); err == io.EOF {
+			return out, nil
+		} else if err != nil {
+			return out, fmt.Errorf("pbf: read blob header size: %w", err)
+		}
+		n := binary.BigEndian.Uint32(size[:])
+		if n > pbfMaxHeaderSize {
+			return out, fmt.Errorf("pbf: blob header of %d bytes exceeds %d", n, pbfMaxHeaderSize)
+		}
+		header := make([]byte, n)
+		if _, err := io.ReadFull(r, header); err != nil {
+			return out, fmt.Errorf("pbf: read blob header: %w", err)
+		}
+		kind, dataSize, err := parseBlobHeader(header)
+		if err != nil {
+			return out, err
+		}
+		if dataSize > pbfMaxBlobSize {
+			return out, fmt.Errorf("pbf: %s blob of %d bytes exceeds %d", kind, dataSize, pbfMaxBlobSize)
+		}
+		blob := make([]byte, dataSize)
+		if _, err := io.ReadFull(r, blob); err != nil {
+			return out, fmt.Errorf("pbf: read %s blob: %w", kind, err)
+		}
+
+		switch kind {
+		case "OSMHeader":
+			data, err := blobData(blob)
+			if err != nil {
+				return out, err
+			}
+			if err := checkPBFHeader(data); err != nil {
+				return out, err
+			}
+		case "OSMData":
+			data, err := blobData(blob)
+			if err != nil {
+				return out, err
+			}
+			if err := parsePrimitiveBlock(data, &out); err != nil {
+				return out, err
+			}
+		}
+		// Other blob types are skipped, as the format requires.
+	}
+}
+
+// pbfFields calls fn for each field of a protobuf message: x holds varint
+// values and b the content of length-delimited ones. Fixed-width fields,
+// unused by the OSM messages read here, are skipped.
+func pbfFields(msg []byte, fn func(num protowire.Number, typ protowire.Type, x uint64, b []byte) error) error {
+	for len(msg) > 0 {
+		num, typ, n := protowire.ConsumeTag(msg)
+		if n < 0 {
+			return fmt.Errorf("pbf: %w", protowire.ParseError(n))
+		}
+		msg = msg[n:]
+		var x uint64
+		var b []byte
+		switch typ {
+		case protowire.VarintType:
+			x, n = protowire.ConsumeVarint(msg)
+		case protowire.BytesType:
+			b, n = protowire.ConsumeBytes(msg)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, msg)
+		}
+		if n < 0 {
+			return fmt.Errorf("pbf: field %d: %w", num, protowire.ParseError(n))
+		}
+		msg = msg[n:]
+		if typ != protowire.VarintType && typ != protowire.BytesType {
+			continue
+		}
+		if err := fn(num, typ, x, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appendVarints adds a repeated varint field to dst, whether it was written
+// packed (one length-delimited field) or as one field per value.
+func appendVarints(dst []uint64, typ protowire.Type, x uint64, b []byte) ([]uint64, error) {
+	if typ == protowire.VarintType {
+		return append(dst, x), nil
+	}
+	for len(b) > 0 {
+		v, n := protowire.ConsumeVarint(b)
+		if n < 0 {
+			return dst, fmt.Errorf("pbf: packed field: %w", protowire.ParseError(n))
+		}
+		dst = append(dst, v)
+		b = b[n:]
+	}
+	return dst, nil
+}
+
+func parseBlobHeader(msg []byte) (kind string, dataSize int, err error) {
+	err = pbfFields(msg, func(num protowire.Number, typ protowire.Type, x uint64, b []byte) error {
+		switch num {
+		case 1:
+			kind = string(b)
+		case 3:
+			dataSize = int(int32(x))
+		}
+		return nil
+	})
+	if err == nil && (kind == "" || dataSize < 0) {
+		err = errors.New("pbf: blob header without type or size")
+	}
+	return kind, dataSize, err
+}
+
+// blobData returns the uncompressed content of a Blob.
+func blobData(msg []byte) ([]byte, error) {
+	var raw, zlibData []byte
+	rawSize := -1
+	compression := ""
+	err := pbfFields(msg, func(num protowire.Number, typ protowire.Type, x uint64, b []byte) error {
+		switch num {
+		case 1:
+			raw = b
+		case 2:
+			rawSize = int(int32(x))
+		case 3:
+			zlibData = b
+		case 4:
+			compression = "lzma"
+		case 6:
+			compression = "lz4"
+		case 7:
+			compression = "zstd"
+		}
+		return nil
+	})
+	switch {
+	case err != nil:
+		return nil, err
+	case raw != nil:
+		return raw, nil
+	case zlibData != nil:
+		if rawSize < 0 || rawSize > pbfMaxBlobSize {
+			return nil, fmt.Errorf("pbf: zlib blob with invalid raw size %d", rawSize)
+		}
+		zr, err := zlib.NewReader(bytes.NewReader(zlibData))
+		if err != nil {
+			return nil, fmt.Errorf("pbf: %w", err)
+		}
+		defer zr.Close()
+		data := make([]byte, rawSize)
+		if _, err := io.ReadFull(zr, data); err != nil {
+			return nil, fmt.Errorf("pbf: inflate blob: %w", err)
+		}
+		return data, nil
+	case compression != "":
+		return nil, fmt.Errorf("pbf: %s-compressed blobs are not supported, recompress with zlib: osmium cat in.osm.pbf -o out.osm.pbf", compression)
+	}
+	return nil, errors.New("pbf: empty blob")
+}
+
+func checkPBFHeader(msg []byte) error {
+	return pbfFields(msg, func(num protowire.Number, typ protowire.Type, x uint64, b []byte) error {
+		if num == 4 && !pbfFeatures[string(b)] {
+			return fmt.Errorf("pbf: unsupported required feature %q", b)
+		}
+		return nil
+	})
+}
+
+// pbfBlock is the context shared by the groups of a PrimitiveBlock: its
+// string table and the coordinate encoding.
+type pbfBlock struct {
+	strings     []string
+	granularity int64
+	latOffset   int64
+	lonOffset   int64
+}
+
+// coord converts encoded coordinates to [lng, lat] degrees.
+func (blk pbfBlock) coord(lat, lon int64) [2]float64 {
+	return [2]float64{
+		float64(blk.lonOffset+blk.granularity*lon) * 1e-9,
+		float64(blk.latOffset+blk.granularity*lat) * 1e-9,
+	}
+}
+
+func (blk pbfBlock) str(i uint64) (string, error) {
+	if i >= uint64(len(blk.strings)) {
+		return "", fmt.Errorf("pbf: string index %d out of a table of %d", i, len(blk.strings))
+	}
+	return blk.strings[i], nil
+}
+
+// tags resolves parallel key and value string indexes.
+func (blk pbfBlock) tags(keys, vals []uint64) (map[string]string, error) {
+	if len(keys) != len(vals) {
+		return nil, fmt.Errorf("pbf: %d tag keys for %d values", len(keys), len(vals))
+	}
+	tags := make(map[string]string, len(keys))
+	for i := range keys {
+		k, err := blk.str(keys[i])
+		if err != nil {
+			return nil, err
+		}
+		v, err := blk.str(vals[i])
+		if err != nil {
+			return nil, err
+		}
+		tags[k] = v
+	}
+	return tags, nil
+}
+
+func parsePrimitiveBlock(msg []byte, out *rawNetwork) error {
+	blk := pbfBlock{granularity: 100}
+	var groups [][]byte
+	err := pbfFields(msg, func(num protowire.Number, typ protowire.Type, x uint64, b []byte) error {
+		switch num {
+		case 1:
+			return pbfFields(b, func(num protowire.Number, typ protowire.Type, x uint64, s []byte) error {
+				if num == 1 {
+					blk.strings = append(blk.strings, string(s))
+				}
+				return nil
+			})
+		case 2:
+			groups = append(groups, b)
+		case 17:
+			blk.granularity = int64(int32(x))
+		case 19:
+			blk.latOffset = int64(x)
+		case 20:
+			blk.lonOffset = int64(x)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, g := range groups {
+		err := pbfFields(g, func(num protowire.Number, typ protowire.Type, x uint64, b []byte) error {
+			switch num {
+			case 1:
+				return blk.parseNode(b, out)
+			case 2:
+				return blk.parseDenseNodes(b, out)
+			case 3:
+				return blk.parseWay(b, out)
+			case 4:
+				return blk.parseRelation(b, out)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (blk pbfBlock) parseNode(msg []byte, out *rawNetwork) error {
+	var id, lat, lon int64
+	err := pbfFields(msg, func(num protowire.Number, typ protowire.Type, x uint64, b []byte) error {
+		switch num {
+		case 1:
+			id = protowire.DecodeZigZag(x)
+		case 8:
+			lat = protowire.DecodeZigZag(x)
+		case 9:
+			lon = protowire.DecodeZigZag(x)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	out.Nodes[strconv.FormatInt(id, 10)] = blk.coord(lat, lon)
+	return nil
+}
+
+// parseDenseNodes reads delta-coded node ids and coordinates. Node tags are
+// not needed to build the network and are skipped.
+func (blk pbfBlock) parseDenseNodes(msg []byte, out *rawNetwork) error {
+	var ids, lats, lons []uint64
+	err := pbfFields(msg, func(num protowire.Number, typ protowire.Type, x uint64, b []byte) error {
+		var err error
+		switch num {
+		case 1:
+			ids, err = appendVarints(ids, typ, x, b)
+		case 8:
+			lats, err = appendVarints(lats, typ, x, b)
+		case 9:
+			lons, err = appendVarints(lons, typ, x, b)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if len(lats) != len(ids) || len(lons) != len(ids) {
+		return fmt.Errorf("pbf: dense nodes with %d ids, %d lats and %d lons", len(ids), len(lats), len(lons))
+	}
+	var id, lat, lon int64
+	for i := range ids {
+		id += protowire.DecodeZigZag(ids[i])
+		lat += protowire.DecodeZigZag(lats[i])
+		lon += protowire.DecodeZigZag(lons[i])
+		out.Nodes[strconv.FormatInt(id, 10)] = blk.coord(lat, lon)
+	}
+	return nil
+}
+
+func (blk pbfBlock) parseWay(msg []byte, out *rawNetwork) error {
+	var id int64
+	var keys, vals, refs []uint64
+	err := pbfFields(msg, func(num protowire.Number, typ protowire.Type, x uint64, b []byte) error {
+		var err error
+		switch num {
+		case 1:
+			id = int64(x)
+		case 2:
+			keys, err = appendVarints(keys, typ, x, b)
+		case 3:
+			vals, err = appendVarints(vals, typ, x, b)
+		case 8:
+			refs, err = appendVarints(refs, typ, x, b)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	tags, err := blk.tags(keys, vals)
+	if err != nil {
+		return err
+	}
+	way := rawWay{ID: strconv.FormatInt(id, 10), Tags: tags, Nodes: make([]string, len(refs))}
+	var ref int64
+	for i, d := range refs {
+		ref += protowire.DecodeZigZag(d)
+		way.Nodes[i] = strconv.FormatInt(ref, 10)
+	}
+	out.Ways = append(out.Ways, way)
+	return nil
+}
+
+// pbfMemberTypes are the MemberType values of a Relation.
+var pbfMemberTypes = []string{"node", "way", "relation"}
+
+func (blk pbfBlock) parseRelation(msg []byte, out *rawNetwork) error {
+	var id int64
+	var keys, vals, roles, memids, types []uint64
+	err := pbfFields(msg, func(num protowire.Number, typ protowire.Type, x uint64, b []byte) error {
+		var err error
+		switch num {
+		case 1:
+			id = int64(x)
+		case 2:
+			keys, err = appendVarints(keys, typ, x, b)
+		case 3:
+			vals, err = appendVarints(vals, typ, x, b)
+		case 8:
+			roles, err = appendVarints(roles, typ, x, b)
+		case 9:
+			memids, err = appendVarints(memids, typ, x, b)
+		case 10:
+			types, err = appendVarints(types, typ, x, b)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	tags, err := blk.tags(keys, vals)
+	if err != nil {
+		return err
+	}
+	// Only restrictions are kept: skip the members of every other relation.
+	if tags["type"] != "restriction" {
+		return nil
+	}
+	if len(roles) != len(memids) || len(types) != len(memids) {
+		return fmt.Errorf("pbf: relation %d with %d members, %d roles and %d types", id, len(memids), len(roles), len(types))
+	}
+
+	rel := osmRelation{ID: strconv.FormatInt(id, 10)}
+	for k, v := range tags {
+		rel.Tags = append(rel.Tags, osmTag{K: k, V: v})
+	}
+	var ref int64
+	for i := range memids {
+		ref += protowire.DecodeZigZag(memids[i])
+		role, err := blk.str(roles[i])
+		if err != nil {
+			return err
+		}
+		if types[i] >= uint64(len(pbfMemberTypes)) {
+			return fmt.Errorf("pbf: relation %d has a member of unknown type %d", id, types[i])
+		}
+		rel.Members = append(rel.Members, osmMember{Type: pbfMemberTypes[types[i]], Ref: strconv.FormatInt(ref, 10), Role: role})
+	}
+	if r, ok := restrictionOf(rel); ok {
+		out.Restrictions = append(out.Restrictions, r)
+	}
+	return nil
+}
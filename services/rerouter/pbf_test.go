@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// pbfMessage builds a protobuf message field by field.
+type pbfMessage []byte
+
+func (m pbfMessage) varint(num protowire.Number, v uint64) pbfMessage {
+	m = protowire.AppendTag(m, num, protowire.VarintType)
+	return protowire.AppendVarint(m, v)
+}
+
+func (m pbfMessage) bytes(num protowire.Number, b []byte) pbfMessage {
+	m = protowire.AppendTag(m, num, protowire.BytesType)
+	return protowire.AppendBytes(m, b)
+}
+
+func (m pbfMessage) packed(num protowire.Number, vs ...uint64) pbfMessage {
+	var b []byte
+	for _, v := range vs {
+		b = protowire.AppendVarint(b, v)
+	}
+	return m.bytes(num, b)
+}
+
+// deltas zigzag-encodes the differences between consecutive values.
+func deltas(vs ...int64) []uint64 {
+	out := make([]uint64, len(vs))
+	prev := int64(0)
+	for i, v := range vs {
+		out[i] = protowire.EncodeZigZag(v - prev)
+		prev = v
+	}
+	return out
+}
+
+// pbfFile frames blobs as a PBF file; zlib compresses the data blobs.
+func pbfFile(t *testing.T, header pbfMessage, blocks ...pbfMessage) []byte {
+	t.Helper()
+	var file bytes.Buffer
+	write := func(kind string, blob pbfMessage) {
+		h := pbfMessage(nil).bytes(1, []byte(kind)).varint(3, uint64(len(blob)))
+		binary.Write(&file, binary.BigEndian, uint32(len(h)))
+		file.Write(h)
+		file.Write(blob)
+	}
+	write("OSMHeader", pbfMessage(nil).bytes(1, header).varint(2, uint64(len(header))))
+	for _, blk := range blocks {
+		var z bytes.Buffer
+		zw := zlib.NewWriter(&z)
+		zw.Write(blk)
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		write("OSMData", pbfMessage(nil).varint(2, uint64(len(blk))).bytes(3, z.Bytes()))
+	}
+	return file.Bytes()
+}
+
+// testPBF encodes testOSM: dense nodes in one block, ways and the turn
+// restriction in another, with the default granularity of 100 nanodegrees.
+func testPBF(t *testing.T) []byte {
+	t.Helper()
+	header := pbfMessage(nil).bytes(4, []byte("OsmSchema-V0.6")).bytes(4, []byte("DenseNodes"))
+
+	e7 := func(deg float64) int64 { return int64(math.Round(deg * 1e7)) }
+	dense := pbfMessage(nil).
+		packed(1, deltas(1, 2, 3, 4)...).
+		packed(8, deltas(e7(48.8566), e7(48.8576), e7(48.8586), e7(48.8576))...).
+		packed(9, deltas(e7(2.3522), e7(2.3522), e7(2.3522), e7(2.3537))...)
+	nodes := pbfMessage(nil).
+		bytes(1, pbfMessage(nil).bytes(1, nil).bytes(1, nil)). // string table: "", ""
+		bytes(2, pbfMessage(nil).bytes(2, dense))
+
+	strs := []string{"", "highway", "residential", "name", "Rue A", "maxspeed", "FR:zone30", "primary",
+		"oneway", "yes", "lanes", "2", "footway", "type", "restriction", "no_left_turn", "from", "via", "to"}
+	table := pbfMessage(nil)
+	for _, s := range strs {
+		table = table.bytes(1, []byte(s))
+	}
+	idx := func(s string) uint64 {
+		for i, v := range strs {
+			if v == s {
+				return uint64(i)
+			}
+		}
+		t.Fatalf("%q not in the string table", s)
+		return 0
+	}
+	way := func(id uint64, refs []int64, kv ...string) []byte {
+		w := pbfMessage(nil).varint(1, id)
+		var keys, vals []uint64
+		for i := 0; i < len(kv); i += 2 {
+			keys, vals = append(keys, idx(kv[i])), append(vals, idx(kv[i+1]))
+		}
+		return w.packed(2, keys...).packed(3, vals...).packed(8, deltas(refs...)...)
+	}
+	group := pbfMessage(nil).
+		bytes(3, way(10, []int64{1, 2}, "highway", "residential", "name", "Rue A")).
+		bytes(3, way(11, []int64{2, 3}, "highway", "residential", "maxspeed", "FR:zone30")).
+		bytes(3, way(20, []int64{2, 4}, "highway", "primary", "oneway", "yes", "lanes", "2")).
+		bytes(3, way(30, []int64{1, 4}, "highway", "footway")).
+		bytes(4, pbfMessage(nil).varint(1, 100).
+			packed(2, idx("type"), idx("restriction")).
+			packed(3, idx("restriction"), idx("no_left_turn")).
+			packed(8, idx("from"), idx("via"), idx("to")).
+			packed(9, deltas(10, 2, 20)...).
+			packed(10, 1, 0, 1))
+	ways := pbfMessage(nil).bytes(1, table).bytes(2, group)
+
+	return pbfFile(t, header, nodes, ways)
+}
+
+func TestParsePBFMatchesOSMXML(t *testing.T) {
+	fromPBF, err := parsePBF(bytes.NewReader(testPBF(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromXML, err := parseOSMXML(strings.NewReader(testOSM))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(fromPBF.Ways, fromXML.Ways) || !reflect.DeepEqual(fromPBF.Restrictions, fromXML.Restrictions) {
+		t.Errorf("ways = %+v, restrictions = %+v;\nwant %+v, %+v", fromPBF.Ways, fromPBF.Restrictions, fromXML.Ways, fromXML.Restrictions)
+	}
+	if len(fromPBF.Nodes) != len(fromXML.Nodes) {
+		t.Fatalf("nodes = %v, want %v", fromPBF.Nodes, fromXML.Nodes)
+	}
+	for id, want := range fromXML.Nodes {
+		got := fromPBF.Nodes[id]
+		if math.Abs(got[0]-want[0]) > 1e-9 || math.Abs(got[1]-want[1]) > 1e-9 {
+			t.Errorf("node %s = %v, want %v", id, got, want)
+		}
+	}
+	if got, want := buildNetwork(fromPBF).roadLinks(), buildNetwork(fromXML).roadLinks(); !reflect.DeepEqual(got, want) {
+		t.Errorf("links = %+v, want %+v", got, want)
+	}
+}
+
+func TestParsePBFRejectsUnsupportedFiles(t *testing.T) {
+	historical := pbfFile(t, pbfMessage(nil).bytes(4, []byte("OsmSchema-V0.6")).bytes(4, []byte("HistoricalInformation")))
+	if _, err := parsePBF(bytes.NewReader(historical)); err == nil || !strings.Contains(err.Error(), "HistoricalInformation") {
+		t.Errorf("historical extract: err = %v", err)
+	}
+
+	var zstd bytes.Buffer
+	blob := pbfMessage(nil).varint(2, 10).bytes(7, []byte("compressed"))
+	h := pbfMessage(nil).bytes(1, []byte("OSMData")).varint(3, uint64(len(blob)))
+	binary.Write(&zstd, binary.BigEndian, uint32(len(h)))
+	zstd.Write(h)
+	zstd.Write(blob)
+	if _, err := parsePBF(&zstd); err == nil || !strings.Contains(err.Error(), "zstd") {
+		t.Errorf("zstd blob: err = %v", err)
+	}
+
+	truncated := testPBF(t)
+	if _, err := parsePBF(bytes.NewReader(truncated[:len(truncated)-5])); err == nil {
+		t.Error("truncated file: expected an error")
+	}
+}
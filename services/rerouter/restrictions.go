@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	restrictionClosed       = "closed"
+	restrictionReducedLanes = "reduced_lanes"
+	restrictionHGVBanned    = "hgv_banned"
+	// restrictionLookahead is how far ahead restrictions are loaded: far
+	// enough for routes departing maxDepartAhead from now.
+	restrictionLookahead = maxDepartAhead + time.Hour
+)
+
+// roadRestriction is a road_restrictions row. LaneRatio is the share of the
+// road's lanes left open, below 1 for reduced_lanes only.
+type roadRestriction struct {
+	Kind      string
+	LaneRatio float64
+	StartsAt  time.Time
+	EndsAt    *time.Time
+}
+
+func (r roadRestriction) activeAt(t time.Time) bool {
+	return !t.Before(r.StartsAt) && (r.EndsAt == nil || t.Before(*r.EndsAt))
+}
+
+// roadRestrictions holds the current and upcoming restrictions of each road.
+type roadRestrictions map[string][]roadRestriction
+
+// loadRestrictions reads the restrictions not over by now that start within
+// restrictionLookahead. The lanes of a road come from the road, its imported
+// segment or its class, in that order.
+func loadRestrictions(ctx context.Context, dbPool *pgxpool.Pool, now time.Time) (roadRestrictions, error) {
+	rows, err := dbPool.Query(ctx, `
+		SELECT x.road_id, x.kind, x.starts_at, x.ends_at,
+			COALESCE(LEAST(1.0, x.lanes_open::float8 / COALESCE(r.lanes, s.lanes, c.default_lanes, x.lanes_open)), 1.0)
+		FROM road_restrictions x
+		LEFT JOIN roads r ON r.road_id = x.road_id
+		LEFT JOIN road_segments s ON s.road_id = x.road_id
+		LEFT JOIN road_classes c ON c.road_class = COALESCE(r.road_class, s.road_class)
+		WHERE (x.ends_at IS NULL OR x.ends_at > $1) AND x.starts_at < $2
+	`, now, now.Add(restrictionLookahead))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	restrictions := make(roadRestrictions)
+	for rows.Next() {
+		var roadID string
+		var r roadRestriction
+		if err := rows.Scan(&roadID, &r.Kind, &r.StartsAt, &r.EndsAt, &r.LaneRatio); err != nil {
+			return nil, err
+		}
+		restrictions[roadID] = append(restrictions[roadID], r)
+	}
+	return restrictions, rows.Err()
+}
+
+// has reports whether a restriction of kind is in force on roadID at t.
+func (rs roadRestrictions) has(roadID, kind string, t time.Time) bool {
+	for _, r := range rs[roadID] {
+		if r.Kind == kind && r.activeAt(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// laneRatio is the share of roadID's lanes open at t: the tightest reduction
+// in force, or 1.
+func (rs roadRestrictions) laneRatio(roadID string, t time.Time) float64 {
+	ratio := 1.0
+	for _, r := range rs[roadID] {
+		if r.Kind == restrictionReducedLanes && r.activeAt(t) && r.LaneRatio > 0 {
+			ratio = min(ratio, r.LaneRatio)
+		}
+	}
+	return ratio
+}
+
+// roadRules applies restrictions to a vehicle departing at Depart, judging
+// each road on the restrictions in force when it is entered. Heavy vehicles
+// are also kept off hgv_banned roads. The zero value restricts nothing.
+//
+// Recommended detours are planned for all traffic: they never enter roads
+// closed when drivers reach them, roads with lanes closed take longer to
+// drive and less diverted volume, and hgv_banned roads on a detour are named
+// in its reason rather than avoided.
+type roadRules struct {
+	Restrictions roadRestrictions
+	Depart       time.Time
+	Heavy        bool
+}
+
+func (u roadRules) at(atMin float64) time.Time {
+	return u.Depart.Add(time.Duration(atMin * float64(time.Minute)))
+}
+
+// closed reports whether roadID cannot be entered atMin minutes after
+// departure.
+func (u roadRules) closed(roadID string, atMin float64) bool {
+	if len(u.Restrictions[roadID]) == 0 {
+		return false
+	}
+	t := u.at(atMin)
+	return u.Restrictions.has(roadID, restrictionClosed, t) ||
+		(u.Heavy && u.Restrictions.has(roadID, restrictionHGVBanned, t))
+}
+
+// slowdown multiplies the time to drive roadID entered atMin minutes after
+// departure: 2 - the share of lanes open, so that with one of two lanes open
+// it takes 1.5 times longer. Traffic merging into fewer lanes slows down
+// before the queue shows in the predictions.
+func (u roadRules) slowdown(roadID string, atMin float64) float64 {
+	if len(u.Restrictions[roadID]) == 0 {
+		return 1
+	}
+	return 2 - u.Restrictions.laneRatio(roadID, u.at(atMin))
+}
+
+// withLaneCapacity returns a copy of g whose roads with lanes closed at
+// departure have their capacity reduced in proportion.
+func (g roadGraph) withLaneCapacity(u roadRules) roadGraph {
+	capacity := make(map[string]float64, len(g.capacity))
+	for roadID, vph := range g.capacity {
+		capacity[roadID] = vph
+	}
+	for roadID := range u.Restrictions {
+		if ratio := u.Restrictions.laneRatio(roadID, u.Depart); ratio < 1 {
+			capacity[roadID] = g.capacityOf(roadID) * ratio
+		}
+	}
+	g.capacity = capacity
+	return g
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRoadRules(t *testing.T) {
+	now := time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)
+	end := now.Add(30 * time.Minute)
+	rs := roadRestrictions{
+		"QUAI-2": {{Kind: restrictionClosed, LaneRatio: 1, StartsAt: now.Add(-time.Hour), EndsAt: &end}},
+		"PONT-6": {{Kind: restrictionHGVBanned, LaneRatio: 1, StartsAt: now}},
+		"QUAI-3": {
+			{Kind: restrictionReducedLanes, LaneRatio: 0.5, StartsAt: now.Add(10 * time.Minute)},
+			{Kind: restrictionReducedLanes, LaneRatio: 0.75, StartsAt: now},
+		},
+	}
+	car := roadRules{Restrictions: rs, Depart: now}
+	hgv := roadRules{Restrictions: rs, Depart: now, Heavy: true}
+
+	if !car.closed("QUAI-2", 0) || car.closed("QUAI-2", 30) || car.closed("QUAI-1", 0) {
+		t.Error("QUAI-2 should be closed until its end only")
+	}
+	if car.closed("PONT-6", 0) || !hgv.closed("PONT-6", 0) {
+		t.Error("PONT-6 should be closed to heavy vehicles only")
+	}
+	if got := car.slowdown("QUAI-3", 0); got != 1.25 {
+		t.Errorf("slowdown with 3/4 lanes = %v, want 1.25", got)
+	}
+	if got := car.slowdown("QUAI-3", 15); got != 1.5 {
+		t.Errorf("slowdown once half the lanes are closed = %v, want 1.5", got)
+	}
+	if got := (roadRules{}).slowdown("QUAI-3", 0); got != 1 {
+		t.Errorf("slowdown without restrictions = %v, want 1", got)
+	}
+
+	g := newRoadGraph(nil)
+	g.capacity = map[string]float64{"QUAI-3": 2000}
+	if got := g.withLaneCapacity(car).capacityOf("QUAI-3"); got != 1500 {
+		t.Errorf("capacity with 3/4 lanes = %v, want 1500", got)
+	}
+	if g.capacity["QUAI-3"] != 2000 {
+		t.Error("withLaneCapacity modified the graph")
+	}
+}
+
+func TestSelectReroutesAvoidsClosedRoads(t *testing.T) {
+	now := time.Now()
+	preds := map[string]RoadPrediction{
+		"QUAI-2": {RoadID: "QUAI-2", CongestionScore: 0.9, UpperBound: 0.95},
+	}
+	rs := roadRestrictions{"PONT-6": {{Kind: restrictionClosed, LaneRatio: 1, StartsAt: now.Add(-time.Hour)}}}
+
+	reroutes := selectReroutes(forecastsAt(5, preds), nil, detourGraph(), roadRules{}, 0.5, nil, now)
+	if len(reroutes) != 1 || reroutes[0].AltRouteID != "PONT-6" {
+		t.Fatalf("reroutes = %+v, want QUAI-2 via PONT-6", reroutes)
+	}
+
+	reroutes = selectReroutes(forecastsAt(5, preds), nil, detourGraph(), roadRules{Restrictions: rs, Depart: now}, 0.5, nil, now)
+	if len(reroutes) != 1 || reroutes[0].AltRouteID != "RIVOLI-4" {
+		t.Fatalf("reroutes = %+v, want QUAI-2 via RIVOLI-4 with PONT-6 closed", reroutes)
+	}
+	if strings.Contains(reroutes[0].Reason, "heavy vehicles") {
+		t.Errorf("reason = %q, no road is closed to heavy vehicles", reroutes[0].Reason)
+	}
+
+	rs["RIVOLI-5"] = []roadRestriction{{Kind: restrictionHGVBanned, LaneRatio: 1, StartsAt: now.Add(-time.Hour)}}
+	reroutes = selectReroutes(forecastsAt(5, preds), nil, detourGraph(), roadRules{Restrictions: rs, Depart: now}, 0.5, nil, now)
+	if len(reroutes) != 1 || !strings.HasSuffix(reroutes[0].Reason, "; RIVOLI-5 closed to heavy vehicles") {
+		t.Errorf("reroutes = %+v, want the ban on RIVOLI-5 in the reason", reroutes)
+	}
+}
+
+func TestRankedRoutesRestrictions(t *testing.T) {
+	g := routeTestGraph()
+	now := time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)
+	rs := roadRestrictions{"PONT-6": {{Kind: restrictionHGVBanned, LaneRatio: 1, StartsAt: now}}}
+
+	car := g.rankedRoutes("QUAI-1", "QUAI-3", nil, roadRules{Restrictions: rs, Depart: now}, nil, 0, 0.5, 3)
+	if len(car) == 0 || car[0].Roads[1] != "PONT-6" {
+		t.Errorf("car routes = %+v, want the short cut first", car)
+	}
+	hgv := g.rankedRoutes("QUAI-1", "QUAI-3", nil, roadRules{Restrictions: rs, Depart: now, Heavy: true}, nil, 0, 0.5, 3)
+	for _, r := range hgv {
+		if r.Roads[1] == "PONT-6" {
+			t.Errorf("heavy vehicle routed over PONT-6: %+v", r)
+		}
+	}
+	if len(hgv) == 0 {
+		t.Error("no route for heavy vehicles")
+	}
+
+	// With one of its two lanes open the short cut takes 1.5 times longer.
+	rs["PONT-6"] = []roadRestriction{{Kind: restrictionReducedLanes, LaneRatio: 0.5, StartsAt: now}}
+	narrowed := g.evaluateRoute(car[0].Roads, nil, roadRules{Restrictions: rs, Depart: now}, nil, 0, 0.5)
+	if narrowed.ETAMin <= car[0].ETAMin {
+		t.Errorf("ETA with a lane closed = %v, want above %v", narrowed.ETAMin, car[0].ETAMin)
+	}
+}
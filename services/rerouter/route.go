@@ -0,0 +1,239 @@
+package main
+
+import (
+	"container/heap"
+	"math"
+	"slices"
+	"sort"
+)
+
+const (
+	// defaultLinkLengthM is assumed for links stored without a length.
+	defaultLinkLengthM = 300.0
+	// defaultFreeFlowKMH is assumed for roads without a known free-flow speed.
+	defaultFreeFlowKMH = 50.0
+	// unmonitoredScore is assumed for roads without a prediction, such as
+	// imported segments with no sensor: moderately busy rather than empty, so
+	// that detours through unmonitored streets are not oversold.
+	unmonitoredScore = 0.3
+	// minSpeedRatio floors the predicted speed at a fraction of free-flow so
+	// that a fully congested road stays passable at a finite cost.
+	minSpeedRatio = 0.1
+)
+
+// routePath is a sequence of roads with its predicted travel time, starting
+// at the end of Roads[0]. At[i] is when Roads[i] is entered, in minutes from
+// departure.
+type routePath struct {
+	Roads   []string
+	At      []float64
+	Minutes float64
+}
+
+// roadForecasts holds the latest prediction of each road for each horizon,
+// in minutes.
+type roadForecasts map[string]map[int]RoadPrediction
+
+// at returns the prediction of roadID for a vehicle entering it atMin minutes
+// from now: the shortest horizon reaching that far, or the longest one when
+// none does. A missing horizon thus falls back to the nearest available one.
+func (f roadForecasts) at(roadID string, atMin float64) (RoadPrediction, bool) {
+	byHorizon := f[roadID]
+	if len(byHorizon) == 0 {
+		return RoadPrediction{}, false
+	}
+	best, longest := -1, -1
+	for h := range byHorizon {
+		if float64(h) >= atMin && (best < 0 || h < best) {
+			best = h
+		}
+		longest = max(longest, h)
+	}
+	if best < 0 {
+		best = longest
+	}
+	return byHorizon[best], true
+}
+
+// score returns the congestion predicted on roadID atMin minutes from now, or
+// unmonitoredScore when it has no prediction.
+func (f roadForecasts) score(roadID string, atMin float64) float64 {
+	if rp, ok := f.at(roadID, atMin); ok {
+		return rp.CongestionScore
+	}
+	return unmonitoredScore
+}
+
+// predictedSpeed approximates the speed on roadID from its congestion score:
+// free-flow at 0, down to minSpeedRatio of free-flow at 1.
+func (g roadGraph) predictedSpeed(roadID string, score float64) float64 {
+	kmh, ok := g.freeFlow[roadID]
+	if !ok {
+		kmh = defaultFreeFlowKMH
+	}
+	return kmh * math.Max(minSpeedRatio, 1-score)
+}
+
+// travelMin is the predicted time, in minutes, to drive from road from into
+// road to when entering it atMin minutes from now: the link length at the
+// speed predicted on to for that time.
+func (g roadGraph) travelMin(from, to string, forecasts roadForecasts, atMin float64) float64 {
+	return g.linkLength(from, to) / 1000 / g.predictedSpeed(to, forecasts.score(to, atMin)) * 60
+}
+
+// linkLength is the length of the link from road from into road to, or
+// defaultLinkLengthM when it was stored without one.
+func (g roadGraph) linkLength(from, to string) float64 {
+	if lengthM, ok := g.length[[2]string{from, to}]; ok {
+		return lengthM
+	}
+	return defaultLinkLengthM
+}
+
+// linkCost is the time to drive from road from into road to, entering to
+// atMin minutes after departure.
+type linkCost func(from, to string, atMin float64) float64
+
+// roadFilter reports whether a road must not be entered atMin minutes after
+// departure.
+type roadFilter func(roadID string, atMin float64) bool
+
+type queueItem struct {
+	road    string
+	minutes float64
+}
+
+type routeQueue []queueItem
+
+func (q routeQueue) Len() int           { return len(q) }
+func (q routeQueue) Less(i, j int) bool { return q[i].minutes < q[j].minutes }
+func (q routeQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *routeQueue) Push(x any)        { *q = append(*q, x.(queueItem)) }
+func (q *routeQueue) Pop() any {
+	old := *q
+	it := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return it
+}
+
+// shortestPath returns the path from road from to the nearest of targets; see
+// shortestPaths.
+func (g roadGraph) shortestPath(from string, targets map[string]bool, cost linkCost, avoid, closed roadFilter, maxMin float64) (routePath, bool) {
+	paths := g.shortestPaths(from, targets, cost, avoid, closed, maxMin)
+	ends := make([]string, 0, len(paths))
+	for end := range paths {
+		ends = append(ends, end)
+	}
+	sort.Strings(ends)
+
+	var best routePath
+	found := false
+	for _, end := range ends {
+		if p := paths[end]; !found || p.Minutes < best.Minutes {
+			best, found = p, true
+		}
+	}
+	return best, found
+}
+
+// shortestPaths runs Dijkstra from road from, departing now, until every
+// target it can reach within maxMin is settled, and returns the path to each,
+// keyed by target. Link costs depend on when each road is entered, so that
+// every road is judged on the prediction for the time drivers would reach it.
+// Roads rejected by avoid are never entered, except targets; roads rejected
+// by closed are never entered at all. Paths end on their target and never
+// run through another one.
+func (g roadGraph) shortestPaths(from string, targets map[string]bool, cost linkCost, avoid, closed roadFilter, maxMin float64) map[string]routePath {
+	dist := map[string]float64{from: 0}
+	prev := make(map[string]string)
+	done := make(map[string]bool)
+	q := &routeQueue{{road: from}}
+	paths := make(map[string]routePath)
+
+	for q.Len() > 0 && len(paths) < len(targets) {
+		it := heap.Pop(q).(queueItem)
+		if done[it.road] {
+			continue
+		}
+		done[it.road] = true
+		if it.road != from && targets[it.road] {
+			p := routePath{Roads: []string{it.road}, Minutes: it.minutes}
+			for r := it.road; r != from; {
+				r = prev[r]
+				p.Roads = append(p.Roads, r)
+			}
+			slices.Reverse(p.Roads)
+			p.At = make([]float64, len(p.Roads))
+			for i := 1; i < len(p.Roads); i++ {
+				p.At[i] = dist[p.Roads[i-1]]
+			}
+			paths[it.road] = p
+			continue
+		}
+
+		for _, next := range g.downstream[it.road] {
+			if done[next] || closed(next, it.minutes) || (!targets[next] && avoid(next, it.minutes)) {
+				continue
+			}
+			d := it.minutes + cost(it.road, next, it.minutes)
+			if d > maxMin {
+				continue
+			}
+			if cur, seen := dist[next]; !seen || d < cur {
+				dist[next] = d
+				prev[next] = it.road
+				heap.Push(q, queueItem{road: next, minutes: d})
+			}
+		}
+	}
+	return paths
+}
+
+// detour finds the path around roadID that saves the most predicted time:
+// from one of its upstream roads to one of its downstream roads without
+// entering roadID or any road avoid rejects, and without ending on a road
+// closed rejects. It returns the original route through roadID and the
+// detour, both with their end roads.
+func (g roadGraph) detour(roadID string, cost linkCost, avoid, closed roadFilter) (routePath, routePath, bool) {
+	ups := append([]string(nil), g.upstream[roadID]...)
+	downs := append([]string(nil), g.downstream[roadID]...)
+	sort.Strings(ups)
+	sort.Strings(downs)
+	skip := func(r string, atMin float64) bool { return r == roadID || avoid(r, atMin) }
+
+	var bestRoute, bestAlt routePath
+	bestGain := 0.0
+	for _, u := range ups {
+		targets := make(map[string]bool)
+		orig := make(map[string]float64)
+		maxMin := 0.0
+		into := cost(u, roadID, 0)
+		for _, d := range downs {
+			if d == u {
+				continue
+			}
+			targets[d] = true
+			orig[d] = into + cost(roadID, d, into)
+			maxMin = math.Max(maxMin, orig[d])
+		}
+		if len(targets) == 0 {
+			continue
+		}
+
+		// The nearest rejoin is not always the best: a farther one may skip
+		// a slower stretch of the original route.
+		alts := g.shortestPaths(u, targets, cost, skip, closed, maxMin)
+		for _, d := range downs {
+			alt, ok := alts[d]
+			if !ok {
+				continue
+			}
+			if gain := orig[d] - alt.Minutes; gain > bestGain {
+				bestGain = gain
+				bestRoute = routePath{Roads: []string{u, roadID, d}, At: []float64{0, 0, into}, Minutes: orig[d]}
+				bestAlt = alt
+			}
+		}
+	}
+	return bestRoute, bestAlt, bestGain > 0
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func meters(m float64) *float64 { return &m }
+
+func never(string, float64) bool { return false }
+
+// forecastsAt puts every prediction at the same horizon.
+func forecastsAt(horizon int, preds map[string]RoadPrediction) roadForecasts {
+	f := make(roadForecasts, len(preds))
+	for roadID, rp := range preds {
+		rp.HorizonMin = horizon
+		f[roadID] = map[int]RoadPrediction{horizon: rp}
+	}
+	return f
+}
+
+// detourGraph has QUAI-2 between QUAI-1 and QUAI-3, a two-road detour through
+// RIVOLI-4 and RIVOLI-5, and a short cut through PONT-6.
+func detourGraph() roadGraph {
+	g := newRoadGraph([]roadLink{
+		{From: "QUAI-1", To: "QUAI-2", LengthM: meters(400)},
+		{From: "QUAI-2", To: "QUAI-3", LengthM: meters(400)},
+		{From: "QUAI-1", To: "RIVOLI-4", LengthM: meters(300)},
+		{From: "RIVOLI-4", To: "RIVOLI-5", LengthM: meters(300)},
+		{From: "RIVOLI-5", To: "QUAI-3", LengthM: meters(300)},
+		{From: "QUAI-1", To: "PONT-6", LengthM: meters(200)},
+		{From: "PONT-6", To: "QUAI-3", LengthM: meters(200)},
+	})
+	g.freeFlow = map[string]float64{"QUAI-2": 50, "QUAI-3": 50, "RIVOLI-4": 30, "RIVOLI-5": 30, "PONT-6": 30}
+	return g
+}
+
+func TestTravelMin(t *testing.T) {
+	g := newRoadGraph([]roadLink{{From: "A", To: "B", LengthM: meters(1000)}})
+	g.freeFlow = map[string]float64{"B": 60}
+	f := roadForecasts{"B": {
+		5:  {RoadID: "B", CongestionScore: 0.5},
+		15: {RoadID: "B", CongestionScore: 1},
+	}}
+
+	if got := g.travelMin("A", "B", f, 0); math.Abs(got-2) > 1e-9 {
+		t.Errorf("1 km at 30 km/h = %.3f min, want 2", got)
+	}
+	if got := g.travelMin("A", "B", f, 8); math.Abs(got-10) > 1e-9 {
+		t.Errorf("fully congested in 15 min = %.3f min, want 10 (floored at 10%% of free-flow)", got)
+	}
+	// Unknown link length and speed, no prediction: 300 m at 35 km/h.
+	if got, want := g.travelMin("B", "C", nil, 0), 0.3/35*60; math.Abs(got-want) > 1e-9 {
+		t.Errorf("defaults = %.3f min, want %.3f", got, want)
+	}
+}
+
+func TestForecastsAt(t *testing.T) {
+	f := roadForecasts{
+		"QUAI-2":   {5: {HorizonMin: 5}, 15: {HorizonMin: 15}, 30: {HorizonMin: 30}},
+		"RIVOLI-4": {30: {HorizonMin: 30}},
+		"PONT-6":   {5: {HorizonMin: 5}},
+	}
+	for _, tt := range []struct {
+		road  string
+		atMin float64
+		want  int
+	}{
+		{"QUAI-2", 0, 5},
+		{"QUAI-2", 5, 5},
+		{"QUAI-2", 6, 15},
+		{"QUAI-2", 40, 30},
+		{"RIVOLI-4", 0, 30}, // only the default horizon is stored
+		{"PONT-6", 12, 5},   // beyond the longest horizon
+	} {
+		if rp, ok := f.at(tt.road, tt.atMin); !ok || rp.HorizonMin != tt.want {
+			t.Errorf("at(%s, %v) = horizon %d, want %d", tt.road, tt.atMin, rp.HorizonMin, tt.want)
+		}
+	}
+	if _, ok := f.at("UNKNOWN", 0); ok {
+		t.Error("at(UNKNOWN) should report no prediction")
+	}
+	if got := f.score("UNKNOWN", 0); got != unmonitoredScore {
+		t.Errorf("score(UNKNOWN) = %v, want %v", got, unmonitoredScore)
+	}
+}
+
+func TestShortestPathAvoidsRoads(t *testing.T) {
+	g := detourGraph()
+	cost := func(from, to string, atMin float64) float64 { return g.travelMin(from, to, nil, atMin) }
+	targets := map[string]bool{"QUAI-3": true}
+
+	p, ok := g.shortestPath("QUAI-1", targets, cost, func(r string, _ float64) bool { return r == "QUAI-2" }, never, math.Inf(1))
+	if !ok || !reflect.DeepEqual(p.Roads, []string{"QUAI-1", "PONT-6", "QUAI-3"}) {
+		t.Errorf("path = %v (%v), want the short cut", p.Roads, ok)
+	}
+
+	avoid := func(r string, _ float64) bool { return r == "QUAI-2" || r == "PONT-6" }
+	p, ok = g.shortestPath("QUAI-1", targets, cost, avoid, never, math.Inf(1))
+	if !ok || !reflect.DeepEqual(p.Roads, []string{"QUAI-1", "RIVOLI-4", "RIVOLI-5", "QUAI-3"}) {
+		t.Errorf("path = %v (%v), want the detour through RIVOLI", p.Roads, ok)
+	}
+	if p.At[0] != 0 || p.At[1] != 0 || p.At[2] <= 0 || p.At[3] <= p.At[2] || p.At[3] >= p.Minutes {
+		t.Errorf("entry times = %v for %.2f min", p.At, p.Minutes)
+	}
+	if _, ok := g.shortestPath("QUAI-1", targets, cost, avoid, never, p.Minutes-0.01); ok {
+		t.Error("path found beyond maxMin")
+	}
+
+	// Targets ignore avoid, but not closed.
+	target := func(r string, _ float64) bool { return r == "QUAI-3" }
+	if _, ok := g.shortestPath("QUAI-1", targets, cost, target, never, math.Inf(1)); !ok {
+		t.Error("avoid kept the path off its target")
+	}
+	if p, ok := g.shortestPath("QUAI-1", targets, cost, never, target, math.Inf(1)); ok {
+		t.Errorf("path = %v ends on a closed road", p.Roads)
+	}
+}
+
+func TestDetourPicksBestRejoin(t *testing.T) {
+	// QUAI-2 leads to QUAI-3 quickly and to QUAI-7 slowly. The side street
+	// to QUAI-3 saves little; the longer one to QUAI-7 saves much more.
+	g := newRoadGraph([]roadLink{
+		{From: "QUAI-1", To: "QUAI-2"},
+		{From: "QUAI-2", To: "QUAI-3"},
+		{From: "QUAI-2", To: "QUAI-7"},
+		{From: "QUAI-1", To: "RIVOLI-4"},
+		{From: "RIVOLI-4", To: "QUAI-3"},
+		{From: "QUAI-1", To: "PONT-6"},
+		{From: "PONT-6", To: "RIVOLI-5"},
+		{From: "RIVOLI-5", To: "QUAI-7"},
+	})
+	minutes := map[[2]string]float64{
+		{"QUAI-2", "QUAI-7"}:   10,
+		{"RIVOLI-4", "QUAI-3"}: 0.5,
+		{"RIVOLI-5", "QUAI-7"}: 2,
+	}
+	cost := func(from, to string, _ float64) float64 {
+		if m, ok := minutes[[2]string{from, to}]; ok {
+			return m
+		}
+		return 1
+	}
+
+	route, alt, ok := g.detour("QUAI-2", cost, never, never)
+	if !ok {
+		t.Fatal("no detour found")
+	}
+	if !reflect.DeepEqual(route.Roads, []string{"QUAI-1", "QUAI-2", "QUAI-7"}) || route.Minutes != 11 {
+		t.Errorf("route = %v (%.1f min), want through QUAI-2 to QUAI-7 in 11", route.Roads, route.Minutes)
+	}
+	if !reflect.DeepEqual(alt.Roads, []string{"QUAI-1", "PONT-6", "RIVOLI-5", "QUAI-7"}) || alt.Minutes != 4 {
+		t.Errorf("detour = %v (%.1f min), want through PONT-6 in 4", alt.Roads, alt.Minutes)
+	}
+
+	// The nearest rejoin is still what shortestPath returns.
+	p, ok := g.shortestPath("QUAI-1", map[string]bool{"QUAI-3": true, "QUAI-7": true}, cost,
+		func(r string, _ float64) bool { return r == "QUAI-2" }, never, math.Inf(1))
+	if !ok || !reflect.DeepEqual(p.Roads, []string{"QUAI-1", "RIVOLI-4", "QUAI-3"}) {
+		t.Errorf("nearest = %v (%v), want QUAI-3 through RIVOLI-4", p.Roads, ok)
+	}
+}
+
+func TestSelectReroutesMultiHop(t *testing.T) {
+	g := detourGraph()
+	preds := map[string]RoadPrediction{
+		"QUAI-2":   {RoadID: "QUAI-2", CongestionScore: 0.9, UpperBound: 0.95},
+		"PONT-6":   {RoadID: "PONT-6", CongestionScore: 0.45, UpperBound: 0.7},
+		"RIVOLI-4": {RoadID: "RIVOLI-4", CongestionScore: 0.2, UpperBound: 0.3},
+		"RIVOLI-5": {RoadID: "RIVOLI-5", CongestionScore: 0.25, UpperBound: 0.35},
+	}
+
+	reroutes := selectReroutes(forecastsAt(5, preds), nil, g, roadRules{}, 0.5, nil, time.Now())
+	if len(reroutes) != 1 {
+		t.Fatalf("got %d reroutes, want 1: %+v", len(reroutes), reroutes)
+	}
+	r := reroutes[0]
+	if r.RouteID != "QUAI-2" || r.AltRouteID != "RIVOLI-4" {
+		t.Errorf("reroute %s -> %s, want QUAI-2 -> RIVOLI-4 (PONT-6 may be jammed)", r.RouteID, r.AltRouteID)
+	}
+	if want := []string{"QUAI-1", "RIVOLI-4", "RIVOLI-5", "QUAI-3"}; !reflect.DeepEqual(r.AltPath, want) {
+		t.Errorf("alt path = %v, want %v", r.AltPath, want)
+	}
+}
+
+func TestSelectReroutesSkipsSlowerDetour(t *testing.T) {
+	g := newRoadGraph([]roadLink{
+		{From: "QUAI-1", To: "QUAI-2", LengthM: meters(200)},
+		{From: "QUAI-2", To: "QUAI-3", LengthM: meters(200)},
+		{From: "QUAI-1", To: "BOULEVARD-7", LengthM: meters(4000)},
+		{From: "BOULEVARD-7", To: "QUAI-3", LengthM: meters(4000)},
+	})
+	preds := map[string]RoadPrediction{
+		"QUAI-2":      {RoadID: "QUAI-2", CongestionScore: 0.7, UpperBound: 0.8},
+		"BOULEVARD-7": {RoadID: "BOULEVARD-7", CongestionScore: 0.1, UpperBound: 0.2},
+	}
+	if reroutes := selectReroutes(forecastsAt(5, preds), nil, g, roadRules{}, 0.5, nil, time.Now()); len(reroutes) != 0 {
+		t.Errorf("got %+v; an 8 km detour does not beat 400 m of congestion", reroutes)
+	}
+}
+
+func TestSelectReroutesUsesArrivalTimePredictions(t *testing.T) {
+	// PONT-8 is clear now but jammed in 15 minutes, and drivers only reach it
+	// after several kilometres: the longer detour through RIVOLI-4 is safer.
+	g := newRoadGraph([]roadLink{
+		{From: "QUAI-1", To: "QUAI-2", LengthM: meters(2000)},
+		{From: "QUAI-2", To: "QUAI-3", LengthM: meters(500)},
+		{From: "QUAI-1", To: "BERGES-6", LengthM: meters(3000)},
+		{From: "BERGES-6", To: "BERGES-7", LengthM: meters(1500)},
+		{From: "BERGES-7", To: "PONT-8", LengthM: meters(1500)},
+		{From: "PONT-8", To: "QUAI-3", LengthM: meters(500)},
+		{From: "QUAI-1", To: "RIVOLI-4", LengthM: meters(5000)},
+		{From: "RIVOLI-4", To: "QUAI-3", LengthM: meters(5000)},
+	})
+	clearNow := func(road string) map[int]RoadPrediction {
+		return map[int]RoadPrediction{5: {RoadID: road, CongestionScore: 0.1, UpperBound: 0.2}}
+	}
+	f := roadForecasts{
+		"QUAI-2":   {5: {RoadID: "QUAI-2", CongestionScore: 0.9, UpperBound: 0.95}},
+		"BERGES-6": clearNow("BERGES-6"),
+		"BERGES-7": clearNow("BERGES-7"),
+		"PONT-8": {
+			5:  {RoadID: "PONT-8", CongestionScore: 0.1, UpperBound: 0.2},
+			15: {RoadID: "PONT-8", CongestionScore: 0.7, UpperBound: 0.85},
+		},
+		"RIVOLI-4": clearNow("RIVOLI-4"),
+	}
+
+	reroutes := selectReroutes(f, nil, g, roadRules{}, 0.5, nil, time.Now())
+	if len(reroutes) != 1 || reroutes[0].AltRouteID != "RIVOLI-4" {
+		t.Fatalf("reroutes = %+v, want QUAI-2 via RIVOLI-4", reroutes)
+	}
+
+	// Without the 15-minute horizon PONT-8 falls back to its 5-minute prediction.
+	delete(f["PONT-8"], 15)
+	reroutes = selectReroutes(f, nil, g, roadRules{}, 0.5, nil, time.Now())
+	if len(reroutes) != 1 || reroutes[0].AltRouteID != "BERGES-6" {
+		t.Errorf("reroutes = %+v, want QUAI-2 via BERGES-6", reroutes)
+	}
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	// maxRouteSnapM is how far from the road network from and to may be.
+	maxRouteSnapM            = 1000.0
+	defaultRouteAlternatives = 3
+	maxRouteAlternatives     = 5
+	// routePenalty multiplies the cost of the links of the routes already
+	// found, so that each new search is pushed onto other roads.
+	routePenalty = 1.5
+	// maxRouteSlowdown drops alternatives slower than this multiple of the
+	// fastest route.
+	maxRouteSlowdown = 1.5
+	// maxDepartAhead bounds depart_at: predictions do not reach further.
+	maxDepartAhead = 2 * time.Hour
+	// routeCacheTTL is how long route queries share the predictions and
+	// restrictions they loaded.
+	routeCacheTTL = 30 * time.Second
+)
+
+var routeQueries = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "cityflow_rerouter_route_queries_total",
+	Help: "Total number of origin-destination route queries, by HTTP status.",
+}, []string{"status"})
+
+type routeEndpoint struct {
+	Lat    float64 `json:"lat"`
+	Lng    float64 `json:"lng"`
+	RoadID string  `json:"road_id"`
+	SnapM  float64 `json:"snap_m"`
+}
+
+// routeOption is one ranked route. CongestionExposure is the predicted
+// congestion score averaged over the driving time, CongestedMin the time
+// spent on roads predicted above the congestion threshold.
+type routeOption struct {
+	Rank               int       `json:"rank"`
+	Roads              []string  `json:"roads"`
+	LengthM            float64   `json:"length_m"`
+	ETAMin             float64   `json:"eta_min"`
+	ArriveAt           time.Time `json:"arrive_at"`
+	CongestionExposure float64   `json:"congestion_exposure"`
+	CongestedMin       float64   `json:"congested_min"`
+	CO2Kg              float64   `json:"co2_kg"`
+}
+
+type routesResponse struct {
+	From     routeEndpoint `json:"from"`
+	To       routeEndpoint `json:"to"`
+	DepartAt time.Time     `json:"depart_at"`
+	Vehicle  string        `json:"vehicle"`
+	Routes   []routeOption `json:"routes"`
+}
+
+// routeCache shares what load returns between route queries for
+// routeCacheTTL.
+type routeCache[T any] struct {
+	load func(ctx context.Context, now time.Time) (T, error)
+
+	mu       sync.Mutex
+	value    T
+	loadedAt time.Time
+}
+
+func (c *routeCache[T]) get(ctx context.Context, now time.Time) (T, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.loadedAt.IsZero() && now.Sub(c.loadedAt) < routeCacheTTL {
+		return c.value, nil
+	}
+	v, err := c.load(ctx, now)
+	if err != nil {
+		return v, err
+	}
+	c.value, c.loadedAt = v, now
+	return v, nil
+}
+
+// routeHandler serves GET /routes: the fastest routes between two points,
+// driven on the predictions and restrictions for the time each road is
+// reached. vehicle=hgv also keeps the routes off hgv_banned roads.
+type routeHandler struct {
+	graph        func(ctx context.Context, now time.Time) roadGraph
+	forecasts    func(ctx context.Context, now time.Time) (roadForecasts, error)
+	restrictions func(ctx context.Context, now time.Time) (roadRestrictions, error)
+	threshold    float64
+	fleet        fleetMix
+	now          func() time.Time
+}
+
+func (h *routeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	status, body := h.serve(r)
+	routeQueries.WithLabelValues(strconv.Itoa(status)).Inc()
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("route query: write response: %v", err)
+	}
+}
+
+func routeError(format string, args ...any) map[string]string {
+	return map[string]string{"error": fmt.Sprintf(format, args...)}
+}
+
+func (h *routeHandler) serve(r *http.Request) (int, any) {
+	if r.Method != http.MethodGet {
+		return http.StatusMethodNotAllowed, routeError("method not allowed")
+	}
+	q := r.URL.Query()
+	from, err := parseLatLng(q.Get("from"))
+	if err != nil {
+		return http.StatusBadRequest, routeError("from: %v", err)
+	}
+	to, err := parseLatLng(q.Get("to"))
+	if err != nil {
+		return http.StatusBadRequest, routeError("to: %v", err)
+	}
+	k := defaultRouteAlternatives
+	if s := q.Get("alternatives"); s != "" {
+		if k, err = strconv.Atoi(s); err != nil || k < 1 || k > maxRouteAlternatives {
+			return http.StatusBadRequest, routeError("alternatives must be between 1 and %d", maxRouteAlternatives)
+		}
+	}
+
+	vehicle := q.Get("vehicle")
+	switch vehicle {
+	case "":
+		vehicle = "car"
+	case "car", "hgv":
+	default:
+		return http.StatusBadRequest, routeError("vehicle must be car or hgv")
+	}
+
+	now := h.now().UTC()
+	depart := now
+	if s := q.Get("depart_at"); s != "" {
+		if depart, err = time.Parse(time.RFC3339, s); err != nil {
+			return http.StatusBadRequest, routeError("depart_at must be an RFC 3339 timestamp")
+		}
+		depart = depart.UTC()
+		if depart.Before(now.Add(-5*time.Minute)) || depart.After(now.Add(maxDepartAhead)) {
+			return http.StatusBadRequest, routeError("depart_at must be between now and %s ahead", maxDepartAhead)
+		}
+	}
+
+	g := h.graph(r.Context(), now)
+	if g.links == 0 {
+		return http.StatusServiceUnavailable, routeError("road graph is empty")
+	}
+	forecasts, err := h.forecasts(r.Context(), now)
+	if err != nil {
+		log.Printf("route query: load predictions: %v", err)
+		return http.StatusServiceUnavailable, routeError("predictions unavailable")
+	}
+	restrictions, err := h.restrictions(r.Context(), now)
+	if err != nil {
+		log.Printf("route query: load road restrictions, routing without them: %v", err)
+	}
+	rules := roadRules{Restrictions: restrictions, Depart: depart, Heavy: vehicle == "hgv"}
+
+	origin, originM, ok := g.snap(from, func(road string) bool { return len(g.downstream[road]) > 0 && !rules.closed(road, 0) })
+	if !ok {
+		return http.StatusNotFound, routeError("no road within %.0f m of from", maxRouteSnapM)
+	}
+	dest, destM, ok := g.snap(to, func(road string) bool { return len(g.upstream[road]) > 0 && !rules.closed(road, 0) })
+	if !ok {
+		return http.StatusNotFound, routeError("no road within %.0f m of to", maxRouteSnapM)
+	}
+	if origin == dest {
+		return http.StatusBadRequest, routeError("from and to are on the same road")
+	}
+
+	routes := g.rankedRoutes(origin, dest, forecasts, rules, h.fleet, math.Max(0, depart.Sub(now).Minutes()), h.threshold, k)
+	if len(routes) == 0 {
+		return http.StatusNotFound, routeError("no route from %s to %s", origin, dest)
+	}
+	for i := range routes {
+		routes[i].ArriveAt = depart.Add(time.Duration(routes[i].ETAMin * float64(time.Minute))).Truncate(time.Second)
+	}
+	from.RoadID, from.SnapM = origin, math.Round(originM)
+	to.RoadID, to.SnapM = dest, math.Round(destM)
+	return http.StatusOK, routesResponse{From: from, To: to, DepartAt: depart, Vehicle: vehicle, Routes: routes}
+}
+
+// parseLatLng reads a "lat,lng" query value.
+func parseLatLng(s string) (routeEndpoint, error) {
+	latS, lngS, ok := strings.Cut(s, ",")
+	if !ok {
+		return routeEndpoint{}, fmt.Errorf("expected lat,lng")
+	}
+	lat, err1 := strconv.ParseFloat(strings.TrimSpace(latS), 64)
+	lng, err2 := strconv.ParseFloat(strings.TrimSpace(lngS), 64)
+	if err1 != nil || err2 != nil || lat < -90 || lat > 90 || lng < -180 || lng > 180 {
+		return routeEndpoint{}, fmt.Errorf("invalid coordinates %q", s)
+	}
+	return routeEndpoint{Lat: lat, Lng: lng}, nil
+}
+
+// snap returns the road nearest to p among those accepted by ok, with its
+// distance, if one lies within maxRouteSnapM.
+func (g roadGraph) snap(p routeEndpoint, ok func(road string) bool) (string, float64, bool) {
+	pt := roadPoint{Lat: p.Lat, Lng: p.Lng}
+	best, bestM := "", math.Inf(1)
+	for road, shape := range g.shapes {
+		if !ok(road) {
+			continue
+		}
+		if d := distanceToLineM(pt, shape); d < bestM || (d == bestM && road < best) {
+			best, bestM = road, d
+		}
+	}
+	return best, bestM, best != "" && bestM <= maxRouteSnapM
+}
+
+// rankedRoutes returns up to k distinct routes from origin to dest, departing
+// offsetMin minutes from now, fastest first, never entering roads rules close.
+// Each search after the first penalises the links of the routes already
+// found; routes are then timed again without the penalty, and those slower
+// than maxRouteSlowdown times the fastest are dropped.
+func (g roadGraph) rankedRoutes(origin, dest string, forecasts roadForecasts, rules roadRules, fleet fleetMix, offsetMin, threshold float64, k int) []routeOption {
+	targets := map[string]bool{dest: true}
+	used := make(map[[2]string]int)
+	cost := func(from, to string, atMin float64) float64 {
+		c := g.travelMin(from, to, forecasts, offsetMin+atMin) * rules.slowdown(to, atMin)
+		return c * math.Pow(routePenalty, float64(used[[2]string{from, to}]))
+	}
+	never := func(string, float64) bool { return false }
+
+	var routes []routeOption
+	seen := make(map[string]bool)
+	// A few extra searches make up for those that return a route already seen.
+	for i := 0; i < 2*k && len(routes) < k; i++ {
+		p, ok := g.shortestPath(origin, targets, cost, never, rules.closed, math.Inf(1))
+		if !ok {
+			break
+		}
+		for j := 1; j < len(p.Roads); j++ {
+			used[[2]string{p.Roads[j-1], p.Roads[j]}]++
+		}
+		key := strings.Join(p.Roads, "\x00")
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		routes = append(routes, g.evaluateRoute(p.Roads, forecasts, rules, fleet, offsetMin, threshold))
+	}
+
+	sort.SliceStable(routes, func(i, j int) bool { return routes[i].ETAMin < routes[j].ETAMin })
+	routes = slices.DeleteFunc(routes, func(r routeOption) bool {
+		return r.ETAMin > routes[0].ETAMin*maxRouteSlowdown
+	})
+	for i := range routes {
+		routes[i].Rank = i + 1
+	}
+	return routes
+}
+
+// evaluateRoute drives roads from the end of the first one, offsetMin minutes
+// from now, judging each road on its prediction and lane closures for the
+// time it is entered. CO2 is for one vehicle of fleet.
+func (g roadGraph) evaluateRoute(roads []string, forecasts roadForecasts, rules roadRules, fleet fleetMix, offsetMin, threshold float64) routeOption {
+	r := routeOption{Roads: roads}
+	var minutes, exposure, co2 float64
+	for i := 1; i < len(roads); i++ {
+		score := forecasts.score(roads[i], offsetMin+minutes)
+		speed := g.predictedSpeed(roads[i], score) / rules.slowdown(roads[i], minutes)
+		lengthM := g.linkLength(roads[i-1], roads[i])
+		dt := lengthM / 1000 / speed * 60
+
+		r.LengthM += lengthM
+		exposure += score * dt
+		if score > threshold {
+			r.CongestedMin += dt
+		}
+		co2 += lengthM / 1000 * fleet.gramsPerKm(speed)
+		minutes += dt
+	}
+	if minutes > 0 {
+		r.CongestionExposure = exposure / minutes
+	}
+	r.LengthM = math.Round(r.LengthM)
+	r.ETAMin = math.Round(minutes*100) / 100
+	r.CongestionExposure = math.Round(r.CongestionExposure*1000) / 1000
+	r.CongestedMin = math.Round(r.CongestedMin*100) / 100
+	r.CO2Kg = math.Round(co2) / 1000
+	return r
+}
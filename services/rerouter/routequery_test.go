@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// routeTestGraph is detourGraph laid out west to east along 48.85 N, with a
+// slower short cut.
+func routeTestGraph() roadGraph {
+	g := detourGraph()
+	g.freeFlow["PONT-6"] = 25
+	g.shapes = map[string][][2]float64{
+		"QUAI-1":   {{2.340, 48.850}, {2.345, 48.850}},
+		"QUAI-2":   {{2.345, 48.850}, {2.350, 48.850}},
+		"QUAI-3":   {{2.350, 48.850}, {2.355, 48.850}},
+		"RIVOLI-4": {{2.346, 48.852}},
+		"RIVOLI-5": {{2.349, 48.852}},
+		"PONT-6":   {{2.3475, 48.849}},
+	}
+	return g
+}
+
+func TestRankedRoutes(t *testing.T) {
+	g := routeTestGraph()
+
+	routes := g.rankedRoutes("QUAI-1", "QUAI-3", nil, roadRules{}, nil, 0, 0.5, 3)
+	if len(routes) != 2 {
+		t.Fatalf("got %d routes, want 2 (the RIVOLI detour is too slow): %+v", len(routes), routes)
+	}
+	if !reflect.DeepEqual(routes[0].Roads, []string{"QUAI-1", "PONT-6", "QUAI-3"}) || routes[0].Rank != 1 {
+		t.Errorf("fastest = %+v, want the short cut", routes[0])
+	}
+	if !reflect.DeepEqual(routes[1].Roads, []string{"QUAI-1", "QUAI-2", "QUAI-3"}) || routes[1].Rank != 2 {
+		t.Errorf("second = %+v, want the quays", routes[1])
+	}
+	if r := routes[0]; r.LengthM != 400 || r.CongestionExposure != unmonitoredScore || r.CongestedMin != 0 || r.CO2Kg <= 0 {
+		t.Errorf("short cut = %+v", r)
+	}
+
+	// Jammed in 5 minutes, PONT-6 is still clear for a departure now.
+	f := forecastsAt(5, map[string]RoadPrediction{"PONT-6": {RoadID: "PONT-6", CongestionScore: 0.9}})
+	f["PONT-6"][0] = RoadPrediction{RoadID: "PONT-6", CongestionScore: 0}
+	if routes := g.rankedRoutes("QUAI-1", "QUAI-3", f, roadRules{}, nil, 0, 0.5, 3); routes[0].Roads[1] != "PONT-6" {
+		t.Errorf("departing now: %+v, want the short cut", routes[0])
+	}
+	routes = g.rankedRoutes("QUAI-1", "QUAI-3", f, roadRules{}, nil, 10, 0.5, 3)
+	if routes[0].Roads[1] != "QUAI-2" {
+		t.Errorf("departing in 10 min: %+v, want the quays", routes[0])
+	}
+	for _, r := range routes {
+		if r.Roads[1] == "PONT-6" && (r.CongestedMin == 0 || r.CongestionExposure < 0.5) {
+			t.Errorf("jammed short cut = %+v", r)
+		}
+	}
+}
+
+func TestRouteHandler(t *testing.T) {
+	now := time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)
+	h := &routeHandler{
+		graph:        func(context.Context, time.Time) roadGraph { return routeTestGraph() },
+		forecasts:    func(context.Context, time.Time) (roadForecasts, error) { return nil, nil },
+		restrictions: func(context.Context, time.Time) (roadRestrictions, error) { return nil, nil },
+		threshold:    0.5,
+		now:          func() time.Time { return now },
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/routes?from=48.8501,2.341&to=48.8499,2.354&alternatives=1", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var resp routesResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.From.RoadID != "QUAI-1" || resp.To.RoadID != "QUAI-3" || resp.From.SnapM > 20 {
+		t.Errorf("endpoints = %+v -> %+v", resp.From, resp.To)
+	}
+	if len(resp.Routes) != 1 || !resp.DepartAt.Equal(now) || !resp.Routes[0].ArriveAt.After(now) {
+		t.Errorf("response = %+v", resp)
+	}
+
+	for query, want := range map[string]int{
+		"?from=48.85,2.341":                                                      http.StatusBadRequest,
+		"?from=48.85,2.341&to=91,2.354":                                          http.StatusBadRequest,
+		"?from=48.85,2.341&to=48.85,2.354&depart_at=tomorrow":                    http.StatusBadRequest,
+		"?from=48.85,2.341&to=48.85,2.354&depart_at=2026-03-02T11:00:00Z":        http.StatusBadRequest,
+		"?from=48.85,2.347&to=48.85,2.348":                                       http.StatusBadRequest, // same road
+		"?from=48.95,2.341&to=48.85,2.354":                                       http.StatusNotFound,
+		"?from=48.85,2.341&to=48.85,2.354&depart_at=2026-03-02T09:00:00%2B01:00": http.StatusOK,
+		"?from=48.85,2.341&to=48.85,2.354&vehicle=bus":                           http.StatusBadRequest,
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/routes"+query, nil))
+		if rec.Code != want {
+			t.Errorf("%s: status = %d, want %d: %s", query, rec.Code, want, rec.Body)
+		}
+	}
+}